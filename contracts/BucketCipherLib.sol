@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: MIT
+// Copyright 2022 PROOF Holdings Inc
+pragma solidity >=0.8.16 <0.9.0;
+
+import {Compressed} from "solidify-contracts/Compressed.sol";
+
+/**
+ * @notice Utility library to decrypt buckets that were encrypted before
+ * deployment to be revealed later (commit-reveal).
+ * @dev Encrypted data is laid out as `nonce (32 bytes) | ciphertext`, where
+ * the ciphertext is the compressed bucket data XORed with the keystream
+ * `keccak256(abi.encodePacked(secret, nonce, uint256(0))) |
+ * keccak256(abi.encodePacked(secret, nonce, uint256(1))) | ...`.
+ * See also `storage.EncryptedBucket` in the Go toolchain.
+ */
+library BucketCipherLib {
+    /**
+     * @notice Thrown if encrypted data is too short to contain a nonce.
+     */
+    error InvalidCiphertext();
+
+    /**
+     * @notice Returns the commitment to a secret, i.e. its keccak256 hash.
+     * @dev Matches `storage.RevealSecret.Commitment` in the Go toolchain.
+     */
+    function commitment(bytes32 secret) internal pure returns (bytes32) {
+        return keccak256(abi.encodePacked(secret));
+    }
+
+    /**
+     * @notice Decrypts encrypted compressed data with a given secret.
+     * @dev Decrypting with the wrong secret results in garbage, which will
+     * most likely fail to inflate.
+     */
+    function decrypt(Compressed memory data, bytes32 secret)
+        internal
+        pure
+        returns (Compressed memory)
+    {
+        bytes memory encrypted = data.data;
+        if (encrypted.length < 0x20) {
+            revert InvalidCiphertext();
+        }
+
+        uint256 len = encrypted.length - 0x20;
+        bytes memory plain = new bytes(len);
+        assembly {
+            let src := add(encrypted, 0x40)
+            let dst := add(plain, 0x20)
+
+            // Free memory after `plain` is used to hash (secret, nonce, i).
+            let buf := mload(0x40)
+            mstore(buf, secret)
+            mstore(add(buf, 0x20), mload(add(encrypted, 0x20)))
+
+            for { let off := 0 } lt(off, len) { off := add(off, 0x20) } {
+                mstore(add(buf, 0x40), shr(5, off))
+                mstore(
+                    add(dst, off), xor(mload(add(src, off)), keccak256(buf, 0x60))
+                )
+            }
+
+            // Zeroing the padding after the last partial word.
+            mstore(add(dst, len), 0)
+        }
+
+        return Compressed({uncompressedSize: data.uncompressedSize, data: plain});
+    }
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: MIT
+// Copyright 2022 PROOF Holdings Inc
+pragma solidity >=0.8.16 <0.9.0;
+
+/**
+ * @notice Utility library to decode the format descriptors of buckets.
+ * @dev Descriptors are encoded big-endian, starting at the most significant
+ * byte, as
+ * | version | flavour | codec | labelBits | offsetBits | fieldSize (2 bytes) |
+ * See also `storage.BucketFormat` in the Go toolchain.
+ */
+library BucketFormatLib {
+    /**
+     * @notice The version of the storage format supported by this library.
+     */
+    uint8 internal constant LIBRARY_VERSION = 1;
+
+    /**
+     * @notice Buckets with a user-defined indexing scheme.
+     */
+    uint8 internal constant FLAVOUR_CUSTOM = 0;
+
+    /**
+     * @notice Buckets compatible with `IndexedBucketLib`.
+     */
+    uint8 internal constant FLAVOUR_INDEXED = 1;
+
+    /**
+     * @notice Buckets compatible with `LabelledBucketLib`.
+     */
+    uint8 internal constant FLAVOUR_LABELLED = 2;
+
+    /**
+     * @notice Storages containing buckets of different formats.
+     */
+    uint8 internal constant FLAVOUR_MIXED = 0xff;
+
+    /**
+     * @notice DEFLATE compressed data, see `InflateLibWrapper`.
+     */
+    uint8 internal constant CODEC_DEFLATE = 0;
+
+    /**
+     * @notice DEFLATE compressed data that is encrypted until revealed, see
+     * `BucketCipherLib`.
+     */
+    uint8 internal constant CODEC_DEFLATE_ENCRYPTED = 1;
+
+    /**
+     * @notice Returns the storage format version.
+     */
+    function version(bytes32 format) internal pure returns (uint8) {
+        return uint8(uint256(format) >> 248);
+    }
+
+    /**
+     * @notice Returns the bucket flavour.
+     */
+    function flavour(bytes32 format) internal pure returns (uint8) {
+        return uint8(uint256(format) >> 240);
+    }
+
+    /**
+     * @notice Returns the codec used to compress the bucket data.
+     */
+    function codec(bytes32 format) internal pure returns (uint8) {
+        return uint8(uint256(format) >> 232);
+    }
+
+    /**
+     * @notice Returns the width of the field labels in labelled buckets.
+     */
+    function labelBits(bytes32 format) internal pure returns (uint8) {
+        return uint8(uint256(format) >> 224);
+    }
+
+    /**
+     * @notice Returns the width of the offsets in the index header of indexed
+     * buckets.
+     */
+    function offsetBits(bytes32 format) internal pure returns (uint8) {
+        return uint8(uint256(format) >> 216);
+    }
+
+    /**
+     * @notice Returns the size of each field for flavours with fixed-size
+     * fields.
+     */
+    function fieldSize(bytes32 format) internal pure returns (uint16) {
+        return uint16(uint256(format) >> 200);
+    }
+}
@@ -9,6 +9,10 @@ import {
 } from "solidify-contracts/InflateLibWrapper.sol";
 import {IndexedBucketLib} from "solidify-contracts/IndexedBucketLib.sol";
 import {LabelledBucketLib} from "solidify-contracts/LabelledBucketLib.sol";
+import {BucketFormatLib} from "solidify-contracts/BucketFormatLib.sol";
+import {BucketCipherLib} from "solidify-contracts/BucketCipherLib.sol";
+import {ERC165Checker} from
+    "openzeppelin-contracts/utils/introspection/ERC165Checker.sol";
 
 /**
  * @notice Coordinates to identify a bucket inside a storage bundle.
@@ -35,9 +39,50 @@ struct FieldCoordinates {
  */
 library BucketStorageLib {
     using InflateLibWrapper for Compressed;
+    using BucketCipherLib for Compressed;
+
+    /**
+     * @notice Thrown if the hash of a loaded bucket does not match the
+     * expected one.
+     */
+    error BucketHashMismatch(
+        BucketCoordinates coordinates, bytes32 got, bytes32 want
+    );
+
+    /**
+     * @notice Thrown if the recomputed root of a storage does not match the
+     * expected one.
+     */
+    error StorageRootMismatch(bytes32 got, bytes32 want);
+
+    /**
+     * @notice Thrown if a bucket or storage format is not compatible with the
+     * one expected by the caller or this library.
+     */
+    error IncompatibleFormat(bytes32 format);
+
+    /**
+     * @notice Thrown if a contract does not advertise support for
+     * `IBucketStorage` via ERC-165.
+     */
+    error UnsupportedStorage(address store);
+
+    /**
+     * @notice Thrown if an encrypted bucket is loaded by a checked loader
+     * without revealing its secret, see `loadCompressedRevealed`.
+     */
+    error EncryptedBucket(BucketCoordinates coordinates);
+
+    /**
+     * @notice Thrown if a revealed secret does not match the expected
+     * commitment.
+     */
+    error InvalidRevealSecret(bytes32 got, bytes32 want);
 
     /**
      * @notice Retrieves uncompressed bucket data from a bundle.
+     * @dev The bucket format is not checked, encrypted buckets have to be
+     * loaded via `loadUncompressedRevealed` instead.
      */
     function loadUncompressed(
         IBucketStorage[] storage bundle,
@@ -56,6 +101,260 @@ library BucketStorageLib {
         return bundle[coordinates.storageId].getBucket(coordinates.bucketId);
     }
 
+    /**
+     * @notice Retrieves uncompressed bucket data from a bundle, decrypting it
+     * if the bucket is encrypted.
+     * @dev See `loadCompressedRevealed`.
+     */
+    function loadUncompressedRevealed(
+        IBucketStorage[] storage bundle,
+        BucketCoordinates memory coordinates,
+        bytes32 secret,
+        bytes32 commitment
+    ) internal view returns (bytes memory) {
+        return loadCompressedRevealed(bundle, coordinates, secret, commitment)
+            .inflate();
+    }
+
+    /**
+     * @notice Retrieves compressed bucket data from a bundle, decrypting it if
+     * the bucket is encrypted.
+     * @dev Buckets of other codecs are returned as is, such that revealed and
+     * unencrypted storages can be accessed alike. Reverts if the secret does
+     * not match the commitment, since decrypting with a wrong secret would
+     * yield garbage instead of failing.
+     * @param secret The revealed secret, see `BucketCipherLib`.
+     * @param commitment The commitment to the secret the buckets were
+     * encrypted with, as returned by `storage.RevealCommitmentOf` in the Go
+     * toolchain.
+     */
+    function loadCompressedRevealed(
+        IBucketStorage[] storage bundle,
+        BucketCoordinates memory coordinates,
+        bytes32 secret,
+        bytes32 commitment
+    ) internal view returns (Compressed memory data) {
+        IBucketStorage store = bundle[coordinates.storageId];
+        data = store.getBucket(coordinates.bucketId);
+
+        if (_isEncrypted(store, coordinates.bucketId)) {
+            bytes32 got = BucketCipherLib.commitment(secret);
+            if (got != commitment) {
+                revert InvalidRevealSecret(got, commitment);
+            }
+            data = data.decrypt(secret);
+        }
+    }
+
+    /**
+     * @notice Retrieves uncompressed bucket data from a bundle after verifying
+     * the integrity of its compressed data.
+     * @dev Reverts if the data does not match the expected hash.
+     * @param expectedHash The keccak256 hash of the compressed bucket data as
+     * computed by `storage.BucketHash` in the Go toolchain.
+     */
+    function loadUncompressedVerified(
+        IBucketStorage[] storage bundle,
+        BucketCoordinates memory coordinates,
+        bytes32 expectedHash
+    ) internal view returns (bytes memory) {
+        return
+            loadCompressedVerified(bundle, coordinates, expectedHash).inflate();
+    }
+
+    /**
+     * @notice Retrieves compressed bucket data from a bundle and verifies its
+     * integrity.
+     * @dev Reverts if the data does not match the expected hash.
+     */
+    function loadCompressedVerified(
+        IBucketStorage[] storage bundle,
+        BucketCoordinates memory coordinates,
+        bytes32 expectedHash
+    ) internal view returns (Compressed memory data) {
+        data = loadCompressed(bundle, coordinates);
+
+        bytes32 hash = keccak256(data.data);
+        if (hash != expectedHash) {
+            revert BucketHashMismatch(coordinates, hash, expectedHash);
+        }
+    }
+
+    /**
+     * @notice Retrieves uncompressed bucket data from a bundle after checking
+     * that the bucket has the expected flavour.
+     * @dev Reverts if the bucket format is incompatible or if the bucket is
+     * encrypted.
+     * @param flavour The expected bucket flavour, see `BucketFormatLib`.
+     */
+    function loadUncompressedChecked(
+        IBucketStorage[] storage bundle,
+        BucketCoordinates memory coordinates,
+        uint8 flavour
+    ) internal view returns (bytes memory) {
+        bytes32 format =
+            bundle[coordinates.storageId].bucketFormat(coordinates.bucketId);
+        checkFormat(format, flavour);
+        if (
+            BucketFormatLib.codec(format)
+                == BucketFormatLib.CODEC_DEFLATE_ENCRYPTED
+        ) {
+            revert EncryptedBucket(coordinates);
+        }
+        return loadCompressed(bundle, coordinates).inflate();
+    }
+
+    /**
+     * @notice Checks that a storage supports `IBucketStorage` and that all of
+     * its buckets are of a given flavour.
+     * @dev Reverts if the storage is unsupported or its format incompatible.
+     */
+    function checkStorage(IBucketStorage store, uint8 flavour) internal view {
+        if (
+            !ERC165Checker.supportsInterface(
+                address(store), type(IBucketStorage).interfaceId
+            )
+        ) {
+            revert UnsupportedStorage(address(store));
+        }
+        checkFormat(store.storageFormat(), flavour);
+    }
+
+    /**
+     * @notice Checks that a format descriptor can be decoded by this library
+     * and is of a given flavour.
+     * @dev Reverts if the version, flavour or codec is incompatible. Encrypted
+     * buckets are compatible but have to be loaded with their secret, see
+     * `loadCompressedRevealed`.
+     */
+    function checkFormat(bytes32 format, uint8 flavour) internal pure {
+        uint8 codec = BucketFormatLib.codec(format);
+        if (
+            BucketFormatLib.version(format) != BucketFormatLib.LIBRARY_VERSION
+                || BucketFormatLib.flavour(format) != flavour
+                || (
+                    codec != BucketFormatLib.CODEC_DEFLATE
+                        && codec != BucketFormatLib.CODEC_DEFLATE_ENCRYPTED
+                )
+        ) {
+            revert IncompatibleFormat(format);
+        }
+    }
+
+    /**
+     * @notice Verifies the integrity of all buckets in a storage against an
+     * expected root.
+     * @dev Recomputes the root from the stored bucket data instead of relying
+     * on the values reported by the storage. Given the gas costs, this is
+     * intended to be used off-chain, e.g. via `eth_call`.
+     * @param expectedRoot The storage root as computed by `storage.StorageRoot`
+     * in the Go toolchain.
+     */
+    function verifyStorage(IBucketStorage store, bytes32 expectedRoot)
+        internal
+        view
+    {
+        uint256 num = store.numBuckets();
+        bytes32[] memory hashes = new bytes32[](num);
+        for (uint256 i; i < num; ++i) {
+            hashes[i] = keccak256(store.getBucket(i).data);
+        }
+
+        bytes32 root = keccak256(abi.encodePacked(hashes));
+        if (root != expectedRoot) {
+            revert StorageRootMismatch(root, expectedRoot);
+        }
+    }
+
+    /**
+     * @notice Retrieves multiple fields from a bundle.
+     * @dev The coordinates are grouped by storage such that each storage is
+     * queried only once via `getBuckets` and each bucket is fetched and
+     * inflated only once, even if several fields are located in it. The bucket
+     * formats are not checked, so this must not be used with encrypted buckets.
+     * @param bundle The bundle of bucket storages
+     * @param coordinates The coordinates of the fields of interest
+     * @param getField Extracts a field from uncompressed bucket data. Since the
+     * same data might be used to retrieve several fields, this must not modify
+     * the buffer (e.g. `IndexedBucketLib.copyField`).
+     * @return fields The fields in the order of the given `coordinates`.
+     */
+    function loadFields(
+        IBucketStorage[] storage bundle,
+        FieldCoordinates[] memory coordinates,
+        function(bytes memory, uint256) internal pure returns (bytes memory)
+            getField
+    ) internal view returns (bytes[] memory fields) {
+        uint256 num = coordinates.length;
+        fields = new bytes[](num);
+
+        // Tracks which fields have already been retrieved.
+        bool[] memory done = new bool[](num);
+        // The position of each field's bucket in the `getBuckets` query of its
+        // storage.
+        uint256[] memory queryIdx = new uint256[](num);
+
+        for (uint256 i; i < num; ++i) {
+            if (done[i]) {
+                continue;
+            }
+            uint256 storageId = coordinates[i].bucket.storageId;
+
+            // Collect the unique buckets that are needed from this storage.
+            uint256[] memory bucketIds = new uint256[](num - i);
+            uint256 numBuckets;
+            for (uint256 j = i; j < num; ++j) {
+                if (done[j] || coordinates[j].bucket.storageId != storageId) {
+                    continue;
+                }
+
+                uint256 bucketId = coordinates[j].bucket.bucketId;
+                uint256 k;
+                for (; k < numBuckets; ++k) {
+                    if (bucketIds[k] == bucketId) {
+                        break;
+                    }
+                }
+                if (k == numBuckets) {
+                    bucketIds[numBuckets++] = bucketId;
+                }
+                queryIdx[j] = k;
+            }
+
+            // Trimming the array to the number of unique buckets.
+            assembly {
+                mstore(bucketIds, numBuckets)
+            }
+
+            Compressed[] memory buckets =
+                bundle[storageId].getBuckets(bucketIds);
+            bytes[] memory uncompressed = new bytes[](numBuckets);
+            for (uint256 k; k < numBuckets; ++k) {
+                uncompressed[k] = buckets[k].inflate();
+            }
+
+            for (uint256 j = i; j < num; ++j) {
+                if (done[j] || coordinates[j].bucket.storageId != storageId) {
+                    continue;
+                }
+                fields[j] =
+                    getField(uncompressed[queryIdx[j]], coordinates[j].fieldId);
+                done[j] = true;
+            }
+        }
+    }
+
+    /**
+     * @notice Retrieves multiple fields from a bundle of indexed buckets.
+     * @dev See also `loadFields`.
+     */
+    function loadIndexedFields(
+        IBucketStorage[] storage bundle,
+        FieldCoordinates[] memory coordinates
+    ) internal view returns (bytes[] memory) {
+        return loadFields(bundle, coordinates, IndexedBucketLib.copyField);
+    }
+
     /**
      * @notice Computes the total number of fields in a bucket storage bundle.
      */
@@ -139,6 +438,18 @@ library BucketStorageLib {
         });
     }
 
+    /**
+     * @notice Returns whether a bucket is encrypted, see `BucketCipherLib`.
+     */
+    function _isEncrypted(IBucketStorage store, uint256 bucketId)
+        private
+        view
+        returns (bool)
+    {
+        return BucketFormatLib.codec(store.bucketFormat(bucketId))
+            == BucketFormatLib.CODEC_DEFLATE_ENCRYPTED;
+    }
+
     /**
      * @notice Computes the absolute field index of a (group, index) pair.
      * @dev The absolute field index is computed by sequentially iterating
@@ -3,12 +3,15 @@
 pragma solidity >=0.8.16 <0.9.0;
 
 import {Compressed} from "solidify-contracts/Compressed.sol";
+import {IERC165} from "openzeppelin-contracts/utils/introspection/IERC165.sol";
 
 /**
  * @notice BucketStorage is used to store a list of compressed buckets in
  * contract code.
+ * @dev Implementations are expected to support ERC-165 interface detection for
+ * `type(IBucketStorage).interfaceId`.
  */
-interface IBucketStorage {
+interface IBucketStorage is IERC165 {
     /**
      * @notice Thrown if a non-existant bucket should be accessed.
      */
@@ -24,6 +27,45 @@ interface IBucketStorage {
         pure
         returns (Compressed memory);
 
+    /**
+     * @notice Returns the compressed buckets with given indices.
+     * @param bucketIndices The indices of the buckets in the storage.
+     * @dev Reverts if any index is out-of-range.
+     */
+    function getBuckets(uint256[] calldata bucketIndices)
+        external
+        pure
+        returns (Compressed[] memory);
+
+    /**
+     * @notice Returns the keccak256 hash of the compressed data of the bucket
+     * with given index.
+     * @param bucketIndex The index of the bucket in the storage.
+     * @dev Reverts if the index is out-of-range.
+     */
+    function bucketHash(uint256 bucketIndex) external pure returns (bytes32);
+
+    /**
+     * @notice Returns the root hash of the storage.
+     * @dev Computed as the keccak256 hash of all tightly packed bucket hashes.
+     */
+    function storageRoot() external pure returns (bytes32);
+
+    /**
+     * @notice Returns the format descriptor of the bucket with given index.
+     * @param bucketIndex The index of the bucket in the storage.
+     * @dev See `BucketFormatLib` for the encoding.
+     * @dev Reverts if the index is out-of-range.
+     */
+    function bucketFormat(uint256 bucketIndex) external pure returns (bytes32);
+
+    /**
+     * @notice Returns the format descriptor shared by all buckets in the
+     * storage.
+     * @dev See `BucketFormatLib` for the encoding.
+     */
+    function storageFormat() external pure returns (bytes32);
+
     function numBuckets() external pure returns (uint256);
 
     function numFields() external pure returns (uint256);
@@ -33,6 +33,43 @@ library IndexedBucketLib {
         internal
         pure
         returns (bytes memory)
+    {
+        (uint256 loc, uint256 length) = _locateField(data, fieldIdx);
+
+        // To save gas, we update the pointer and size in memory instead of
+        // allocating new space and copying the content over.
+        assembly {
+            data := add(data, loc)
+            mstore(data, length)
+        }
+        return data;
+    }
+
+    /**
+     * @notice Retrieves a copy of the field with a given index.
+     * @dev In contrast to `getField`, this leaves the bucket data untouched,
+     * allowing multiple fields to be retrieved from the same buffer.
+     * @param data The decompressed bucket data.
+     * @param fieldIdx The index of the field that should be retrieved.
+     */
+    function copyField(bytes memory data, uint256 fieldIdx)
+        internal
+        pure
+        returns (bytes memory)
+    {
+        (uint256 loc, uint256 length) = _locateField(data, fieldIdx);
+        return data.slice(loc, length);
+    }
+
+    /**
+     * @notice Computes the location and length of the field with a given
+     * index.
+     * @dev Reverts if the index is out-of-bounds.
+     */
+    function _locateField(bytes memory data, uint256 fieldIdx)
+        private
+        pure
+        returns (uint256 loc, uint256 length)
     {
         // Since each index takes 2 bytes of storage, the number of fields can
         // be determined from the the location of the first field right after
@@ -43,9 +80,8 @@ library IndexedBucketLib {
         }
 
         // The offset in the array at which the field of interest starts
-        uint256 loc = data.getUint16(fieldIdx * 2);
+        loc = data.getUint16(fieldIdx * 2);
 
-        uint256 length;
         if (fieldIdx + 1 < numFields) {
             // The lenght of a field can be determined from the difference of
             // its starting offset to the one of the following field.
@@ -55,13 +91,5 @@ library IndexedBucketLib {
             // from the full length of the array instead.
             length = data.length - loc;
         }
-
-        // To save gas, we update the pointer and size in memory instead of
-        // allocating new space and copying the content over.
-        assembly {
-            data := add(data, loc)
-            mstore(data, length)
-        }
-        return data;
     }
 }
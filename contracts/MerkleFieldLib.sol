@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+// Copyright 2022 PROOF Holdings Inc
+pragma solidity >=0.8.16 <0.9.0;
+
+/**
+ * @notice Utility library to verify fields supplied in calldata against the
+ * Merkle root over all fields of a bundle, such that only the root has to be
+ * stored on-chain.
+ * @dev Leaves are double-hashed, i.e. `keccak256(bytes.concat(keccak256(
+ * abi.encodePacked(uint256(group), uint256(index), data))))`, to rule out
+ * second preimages with inner nodes. Inner nodes hash sorted pairs, and nodes
+ * without a sibling are promoted to the next level unchanged. This is
+ * compatible with OpenZeppelin's `MerkleProof` and matches
+ * `storage.FieldsMerkleTree` in the Go toolchain.
+ */
+library MerkleFieldLib {
+    /**
+     * @notice Computes the leaf of a field with a given index in a given group.
+     */
+    function leaf(uint256 group, uint256 index, bytes calldata data)
+        internal
+        pure
+        returns (bytes32)
+    {
+        return keccak256(
+            bytes.concat(keccak256(abi.encodePacked(group, index, data)))
+        );
+    }
+
+    /**
+     * @notice Computes the root of the tree from a leaf and its proof.
+     */
+    function processProof(bytes32 node, bytes32[] calldata proof)
+        internal
+        pure
+        returns (bytes32)
+    {
+        for (uint256 i; i < proof.length; ++i) {
+            bytes32 sibling = proof[i];
+            node = node < sibling
+                ? keccak256(abi.encodePacked(node, sibling))
+                : keccak256(abi.encodePacked(sibling, node));
+        }
+        return node;
+    }
+
+    /**
+     * @notice Checks whether a field with a given index in a given group is
+     * part of the tree with the given root.
+     */
+    function verify(
+        bytes32 root,
+        uint256 group,
+        uint256 index,
+        bytes calldata data,
+        bytes32[] calldata proof
+    ) internal pure returns (bool) {
+        return processProof(leaf(group, index, data), proof) == root;
+    }
+}
@@ -8,7 +8,10 @@ import {LayerStorageDeployer} from
     "moonbirds-inchain/gen/LayerStorageDeployer.sol";
 import {TraitStorageDeployer} from
     "moonbirds-inchain/gen/TraitStorageDeployer.sol";
-import {AssetStorageManager} from "moonbirds-inchain/AssetStorageManager.sol";
+import {LayerStorageManager} from
+    "moonbirds-inchain/gen/LayerStorageManager.sol";
+import {TraitStorageManager} from
+    "moonbirds-inchain/gen/TraitStorageManager.sol";
 import {Assembler} from "moonbirds-inchain/Assembler.sol";
 
 import {FeaturesStorageDeployer} from
@@ -17,19 +20,22 @@ import {FeaturesStorageManager} from
     "moonbirds-inchain/FeaturesStorageManager.sol";
 
 contract DeployHelper {
-    AssetStorageManager public storageManager;
+    LayerStorageManager public layerStorageManager;
+    TraitStorageManager public traitStorageManager;
     Assembler public assembler;
     FeaturesStorageManager public proofFeaturesRegistry;
 
     function deployAssembler() public {
-        if (address(storageManager) == address(0)) {
-            storageManager = new AssetStorageManager(
-                LayerStorageDeployer.deployAsStatic(),
-                TraitStorageDeployer.deployAsStatic()
-            );
+        if (address(layerStorageManager) == address(0)) {
+            layerStorageManager =
+                new LayerStorageManager(LayerStorageDeployer.deployAsStatic());
+        }
+        if (address(traitStorageManager) == address(0)) {
+            traitStorageManager =
+                new TraitStorageManager(TraitStorageDeployer.deployAsStatic());
         }
         if (address(assembler) == address(0)) {
-            assembler = new Assembler(storageManager);
+            assembler = new Assembler(layerStorageManager, traitStorageManager);
         }
     }
 
@@ -62,32 +62,33 @@ func getFeatures(assetsDir string) ([]types.FeatureGroup, []types.Token, error)
 	return fTypes, tokens, nil
 }
 
-func processFeatures(fTypes []types.FeatureGroup, tokens []types.Token, mt *merkletree.MerkleTree, outDir string) ([]string, error) {
+func processFeatures(fTypes []types.FeatureGroup, tokens []types.Token, mt *merkletree.MerkleTree, outDir string) ([]string, *storage.DeploymentCost, error) {
 	buckets, err := aggregators.GroupIntoLabelledBuckets(tokens, maxFeaturesBucketSize)
 	if err != nil {
-		return nil, fmt.Errorf("aggregators.GroupIntoLabelledBuckets(%T, %d): %w", tokens, maxFeaturesBucketSize, err)
+		return nil, nil, fmt.Errorf("aggregators.GroupIntoLabelledBuckets(%T, %d): %w", tokens, maxFeaturesBucketSize, err)
 	}
 
-	stores, err := aggregators.GroupIntoStorages(buckets, maxFeaturesStorageSize, -1, "Features")
+	stores, err := aggregators.GroupIntoStorages(buckets, maxFeaturesStorageSize, -1, "Features", nil)
 	if err != nil {
-		return nil, fmt.Errorf("aggregators.GroupIntoStorages(%T, %v, %v, %q): %w", buckets, maxFeaturesStorageSize, -1, "Features", err)
+		return nil, nil, fmt.Errorf("aggregators.GroupIntoStorages(%T, %v, %v, %q): %w", buckets, maxFeaturesStorageSize, -1, "Features", err)
 	}
 
-	if err := PrintStorageStats(stores); err != nil {
-		return nil, fmt.Errorf("PrintStorageStats(%T): %w", stores, err)
+	cost, err := PrintStorageStats("Features", stores)
+	if err != nil {
+		return nil, nil, fmt.Errorf("PrintStorageStats(%q, %T): %w", "Features", stores, err)
 	}
 
 	forgeFeaturesJSON := filepath.Join(outDir, "features.json")
-	if err := storage.WriteFeaturesJSONToFile(fTypes, tokens, forgeFeaturesJSON); err != nil {
-		return nil, fmt.Errorf("storage.WriteAllFeaturesJSON(%T, %T, %q): %w", fTypes, tokens, forgeFeaturesJSON, err)
+	if err := storage.WriteFeaturesJSONToFile(fTypes, tokens, nil, forgeFeaturesJSON); err != nil {
+		return nil, nil, fmt.Errorf("storage.WriteAllFeaturesJSON(%T, %T, %q): %w", fTypes, tokens, forgeFeaturesJSON, err)
 	}
 
-	fs, err := storage.WriteFeaturesContracts(fTypes, stores, mt, outDir)
+	fs, err := storage.WriteFeaturesContracts(fTypes, stores, mt, nil, outDir)
 	if err != nil {
-		return nil, fmt.Errorf("storage.WriteFeaturesContracts(%T, %T, %T, %q): %w", fTypes, stores, mt, outDir, err)
+		return nil, nil, fmt.Errorf("storage.WriteFeaturesContracts(%T, %T, %T, %q): %w", fTypes, stores, mt, outDir, err)
 	}
 
-	return fs, nil
+	return fs, cost, nil
 }
 
 func computeMerkleTree(tokens []types.Token) (*merkletree.MerkleTree, error) {
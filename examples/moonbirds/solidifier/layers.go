@@ -32,6 +32,14 @@ func (l layerGroup) NumFields() int {
 	return len(l.ims)
 }
 
+func (l layerGroup) Fields() []storage.Field {
+	fs := make([]storage.Field, len(l.ims))
+	for i, im := range l.ims {
+		fs[i] = im
+	}
+	return fs
+}
+
 func getLayers(gs []types.FeatureGroup, assetsDir string) ([]layerGroup, error) {
 	layerDir := filepath.Join(assetsDir, "moonbirds-assets", "traits")
 
@@ -143,43 +151,100 @@ func blend(bg, fg image.Image) image.Image {
 	return i
 }
 
-func packLayers(layers []layerGroup) ([]*aggregators.BucketStorage, error) {
+func packLayers(layers []layerGroup, fTypes []types.FeatureGroup, tokens []types.Token, coAccess bool, secret *storage.RevealSecret) (*aggregators.GroupedBundle[layerGroup], error) {
 	sort.Slice(layers, func(i, j int) bool {
 		return layers[i].name < layers[j].name
 	})
 
-	var images []*types.Image
-	for _, l := range layers {
-		images = append(images, l.ims...)
+	cfg := aggregators.GroupedBundleConfig{
+		MaxBucketSize:        maxBucketSize,
+		MaxStorageSize:       maxStorageSize,
+		MaxBucketsPerStorage: maxBucketsPerStorage,
+		Encryption:           secret,
+	}
+	if coAccess {
+		accs, err := layerAccesses(fTypes, tokens, layers)
+		if err != nil {
+			return nil, fmt.Errorf("layerAccesses(…): %w", err)
+		}
+		cfg.CoAccess = &aggregators.CoAccess{Accesses: accs}
 	}
 
-	buckets, err := aggregators.GroupIntoIndexedBuckets(images, maxBucketSize)
+	bundle, err := aggregators.NewGroupedBundle("Layer", layers, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("aggregators.GroupIntoIndexedBuckets(%T, %d): %w", images, maxBucketSize, err)
+		return nil, fmt.Errorf("aggregators.NewGroupedBundle(%q, %T, %+v): %w", "Layer", layers, cfg, err)
 	}
 
-	stores, err := aggregators.GroupIntoStorages(buckets, maxStorageSize, maxBucketsPerStorage, "Layer")
-	if err != nil {
-		return nil, fmt.Errorf("aggregators.GroupIntoStorages(%T, %v, %v, %q): %w", buckets, maxStorageSize, maxBucketsPerStorage, "Layer", err)
+	return bundle, nil
+}
+
+// layerAccesses returns the layers loaded by the Assembler to render each
+// token. The PROOF background is not considered as it is opt-in.
+func layerAccesses(fTypes []types.FeatureGroup, tokens []types.Token, layers []layerGroup) ([][]aggregators.FieldRef, error) {
+	idx := make(map[string]int)
+	for i, l := range layers {
+		idx[l.name] = i
 	}
 
-	return stores, nil
+	return aggregators.TokenAccesses(tokens, func(t types.Token) ([]aggregators.FieldRef, error) {
+		if len(t.Features) != len(fTypes) {
+			return nil, fmt.Errorf("token %d has %d features; want %d", t.TokenID, len(t.Features), len(fTypes))
+		}
+
+		var refs []aggregators.FieldRef
+		for i, v := range t.Features {
+			typ := fTypes[i].Type
+			if v == 0 {
+				continue
+			}
+			if typ == "Background" {
+				// Backgrounds below 8 are solid colours.
+				if v >= 8 {
+					refs = append(refs, aggregators.FieldRef{Group: idx["Gradients"], Index: int(v) - 8})
+				}
+				continue
+			}
+
+			g, ok := idx[typ]
+			if !ok {
+				return nil, fmt.Errorf("no layers of type %q", typ)
+			}
+			refs = append(refs, aggregators.FieldRef{Group: g, Index: int(v) - 1})
+		}
+		return refs, nil
+	})
 }
 
-func processLayers(fTypes []types.FeatureGroup, assetsDir, outDir string) ([]string, error) {
+func processLayers(fTypes []types.FeatureGroup, tokens []types.Token, assetsDir, outDir string, coAccess bool, secret *storage.RevealSecret) ([]string, *storage.DeploymentCost, error) {
 	layers, err := getLayers(fTypes, assetsDir)
 	if err != nil {
-		return nil, fmt.Errorf("getLayers(%T): %w", fTypes, err)
+		return nil, nil, fmt.Errorf("getLayers(%T): %w", fTypes, err)
+	}
+
+	bundle, err := packLayers(layers, fTypes, tokens, coAccess, secret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("packLayers(%T): %w", layers, err)
 	}
 
-	stores, err := packLayers(layers)
+	cost, err := PrintStorageStats("Layer", bundle.Storages())
 	if err != nil {
-		return nil, fmt.Errorf("packLayers(%T): %w", layers, err)
+		return nil, nil, fmt.Errorf("PrintStorageStats(%q, %T): %w", "Layer", bundle.Storages(), err)
 	}
 
-	if err = PrintStorageStats(stores); err != nil {
-		return nil, fmt.Errorf("PrintStorageStats(%T): %w", stores, err)
+	accs, err := layerAccesses(fTypes, tokens, bundle.Groups())
+	if err != nil {
+		return nil, nil, fmt.Errorf("layerAccesses(…): %w", err)
+	}
+	stats, err := bundle.InflateStats(accs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%T.InflateStats(…): %w", bundle, err)
+	}
+	fmt.Printf("Layer buckets inflated per token: %.2f mean, %d max\n\n", stats.Mean, stats.Max)
+
+	fnames, err := bundle.WriteContracts(outDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%T.WriteContracts(%q): %w", bundle, outDir, err)
 	}
 
-	return storage.WriteGroupStorage("Layer", layers, stores, outDir)
+	return fnames, cost, nil
 }
@@ -3,16 +3,27 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/proofxyz/solidify/go/storage"
+	"go.uber.org/multierr"
 )
 
 type config struct {
 	outDir, assetsDir string
 	writeProofs       bool
+	coAccessLayers    bool
+	revealSecretFile  string
+	forgeFmt          bool
+	costReport        string
+	gasPriceGwei      uint64
 }
 
 func main() {
@@ -20,6 +31,11 @@ func main() {
 	flag.StringVar(&c.outDir, "out", "", "The output directory for the generated contract")
 	flag.StringVar(&c.assetsDir, "in", "", "The input directory containing the moonbirds assets")
 	flag.BoolVar(&c.writeProofs, "writeProofs", false, "Flag to enable Merkle proof generation.")
+	flag.BoolVar(&c.coAccessLayers, "coAccessLayers", false, "Flag to pack layers that are frequently rendered together into the same buckets instead of packing them alphabetically.")
+	flag.StringVar(&c.revealSecretFile, "revealSecret", "", "If set, the path of the hex-encoded secret with which the layers are encrypted until revealed. A new secret is generated if the file does not exist.")
+	flag.BoolVar(&c.forgeFmt, "forgeFmt", false, "Flag to additionally format the generated contracts with `forge fmt`.")
+	flag.StringVar(&c.costReport, "costReport", "", "If set, the path to write a JSON report of the estimated deployment costs to.")
+	flag.Uint64Var(&c.gasPriceGwei, "gasPrice", 20, "The gas price in gwei used to convert the estimated deployment gas to ETH.")
 	flag.Parse()
 
 	if err := c.run(); err != nil {
@@ -46,28 +62,85 @@ func (c config) run() error {
 	}
 
 	var generatedFiles []string
+	costs := &storage.ProjectCost{
+		GasPrice: new(big.Int).Mul(new(big.Int).SetUint64(c.gasPriceGwei), storage.Gwei),
+	}
+	var secret *storage.RevealSecret
+	if c.revealSecretFile != "" {
+		s, err := loadRevealSecret(c.revealSecretFile)
+		if err != nil {
+			return fmt.Errorf("loadRevealSecret(%q): %w", c.revealSecretFile, err)
+		}
+		secret = &s
+		fmt.Printf("Encrypting layers; reveal commitment: %v\n\n", s.Commitment())
+	}
 
-	if fns, err := processLayers(fTypes, c.assetsDir, c.outDir); err != nil {
+	if fns, cost, err := processLayers(fTypes, tokens, c.assetsDir, c.outDir, c.coAccessLayers, secret); err != nil {
 		return fmt.Errorf("processLayers(): %w", err)
 	} else {
 		generatedFiles = append(generatedFiles, fns...)
+		costs.Bundles = append(costs.Bundles, cost)
 	}
 
-	if fns, err := processTraits(fTypes, c.outDir); err != nil {
+	if fns, cost, err := processTraits(fTypes, c.outDir); err != nil {
 		return fmt.Errorf("processTraits(): %w", err)
 	} else {
 		generatedFiles = append(generatedFiles, fns...)
+		costs.Bundles = append(costs.Bundles, cost)
 	}
 
-	if fns, err := processFeatures(fTypes, tokens, mt, c.outDir); err != nil {
+	if fns, cost, err := processFeatures(fTypes, tokens, mt, c.outDir); err != nil {
 		return fmt.Errorf("processFeatures(): %w", err)
 	} else {
 		generatedFiles = append(generatedFiles, fns...)
+		costs.Bundles = append(costs.Bundles, cost)
+	}
+
+	fmt.Printf("Estimated deployment gas of all bundles: %d (%s ETH at %d gwei)\n", costs.Gas(), storage.FormatETH(costs.Wei()), c.gasPriceGwei)
+	if c.costReport != "" {
+		if err := writeCostReport(costs, c.costReport); err != nil {
+			return fmt.Errorf("writeCostReport(…, %q): %w", c.costReport, err)
+		}
 	}
 
-	if err := storage.FormatSol(generatedFiles); err != nil {
-		return fmt.Errorf("FormatSol(%v): %w", generatedFiles, err)
+	if c.forgeFmt {
+		if err := storage.FormatSol(generatedFiles); err != nil {
+			return fmt.Errorf("FormatSol(%v): %w", generatedFiles, err)
+		}
 	}
 
 	return nil
 }
+
+func writeCostReport(costs *storage.ProjectCost, path string) (retErr error) {
+	w, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("os.Create(%q): %w", path, err)
+	}
+	defer func() {
+		retErr = multierr.Combine(retErr, w.Close())
+	}()
+
+	return costs.WriteJSON(w)
+}
+
+// loadRevealSecret reads the hex-encoded secret from a given file or generates
+// and stores a new one if the file does not exist.
+func loadRevealSecret(path string) (storage.RevealSecret, error) {
+	buf, err := os.ReadFile(path)
+	if err == nil {
+		return storage.ParseRevealSecret(strings.TrimSpace(string(buf)))
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return storage.RevealSecret{}, fmt.Errorf("os.ReadFile(%q): %w", path, err)
+	}
+
+	s, err := storage.GenerateRevealSecret()
+	if err != nil {
+		return storage.RevealSecret{}, fmt.Errorf("storage.GenerateRevealSecret(): %w", err)
+	}
+	if err := os.WriteFile(path, []byte(s.Hex()+"\n"), 0600); err != nil {
+		return storage.RevealSecret{}, fmt.Errorf("os.WriteFile(%q, …): %w", path, err)
+	}
+	return s, nil
+}
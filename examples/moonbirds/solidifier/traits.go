@@ -23,6 +23,18 @@ func (t traitGroup) NumFields() int {
 	return len(t.values)
 }
 
+func (t traitGroup) FieldType() storage.FieldType {
+	return storage.FieldTypeString
+}
+
+func (t traitGroup) Fields() []storage.Field {
+	fs := make([]storage.Field, len(t.values))
+	for i, v := range t.values {
+		fs[i] = v
+	}
+	return fs
+}
+
 func getTraits(gs []types.FeatureGroup) []traitGroup {
 	traits := make([]traitGroup, len(gs))
 
@@ -61,43 +73,42 @@ func getTraits(gs []types.FeatureGroup) []traitGroup {
 	return traits
 }
 
-func packTraits(traits []traitGroup) ([]*aggregators.BucketStorage, error) {
+func packTraits(traits []traitGroup) (*aggregators.GroupedBundle[traitGroup], error) {
 	sort.Slice(traits, func(i, j int) bool {
 		return traits[i].name < traits[j].name
 	})
 
-	store := aggregators.NewBucketStorage("TraitBucketStorage0")
-	for _, trait := range traits {
-		b := new(aggregators.IndexedBucket)
-
-		for _, v := range trait.values {
-			err := b.AddField(v)
-			if err != nil {
-				return nil, fmt.Errorf("%T.AddField(%v): %w", b, v, err)
-			}
-		}
-
-		store.AddBucket(b)
+	// Every trait type gets its own bucket in a single storage.
+	cfg := aggregators.GroupedBundleConfig{
+		MaxBucketSize:        -1,
+		MaxStorageSize:       -1,
+		MaxBucketsPerStorage: -1,
+		BucketPerGroup:       true,
+	}
+	bundle, err := aggregators.NewGroupedBundle("Trait", traits, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("aggregators.NewGroupedBundle(%q, %T, %+v): %w", "Trait", traits, cfg, err)
 	}
 
-	return []*aggregators.BucketStorage{store}, nil
+	return bundle, nil
 }
 
-func processTraits(fTypes []types.FeatureGroup, outDir string) ([]string, error) {
+func processTraits(fTypes []types.FeatureGroup, outDir string) ([]string, *storage.DeploymentCost, error) {
 	traits := getTraits(fTypes)
-	stores, err := packTraits(traits)
+	bundle, err := packTraits(traits)
 	if err != nil {
-		return nil, fmt.Errorf("packTraits(%v): %w", traits, err)
+		return nil, nil, fmt.Errorf("packTraits(%v): %w", traits, err)
 	}
 
-	if err := PrintStorageStats(stores); err != nil {
-		return nil, fmt.Errorf("PrintStorageStats(%T): %w", stores, err)
+	cost, err := PrintStorageStats("Trait", bundle.Storages())
+	if err != nil {
+		return nil, nil, fmt.Errorf("PrintStorageStats(%q, %T): %w", "Trait", bundle.Storages(), err)
 	}
 
-	fnames, err := storage.WriteGroupStorage("Trait", traits, stores, outDir)
+	fnames, err := bundle.WriteContracts(outDir)
 	if err != nil {
-		return nil, fmt.Errorf("storage.WriteGroupStorage(%q, %T, %T, %q): %w", "Trait", traits, stores, outDir, err)
+		return nil, nil, fmt.Errorf("%T.WriteContracts(%q): %w", bundle, outDir, err)
 	}
 
-	return fnames, nil
+	return fnames, cost, nil
 }
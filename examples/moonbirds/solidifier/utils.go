@@ -6,16 +6,24 @@ import (
 	"github.com/proofxyz/solidify/go/storage"
 )
 
-func PrintStorageStats[S storage.BucketStorage](stores []S) error {
+// PrintStorageStats prints the size and estimated deployment gas of each
+// storage of a bundle and returns the estimated cost of the bundle.
+func PrintStorageStats[S storage.BucketStorage](name string, stores []S) (*storage.DeploymentCost, error) {
+	cost, err := storage.EstimateDeploymentCost(name, stores, nil)
+	if err != nil {
+		return nil, fmt.Errorf("storage.EstimateDeploymentCost(%q, %T, nil): %w", name, stores, err)
+	}
+
 	var totalBytes int
-	for _, s := range stores {
+	for i, s := range stores {
 		size, err := s.Size()
 		if err != nil {
-			return fmt.Errorf("%T.Size(): %w", s, err)
+			return nil, fmt.Errorf("%T.Size(): %w", s, err)
 		}
 		totalBytes += size
-		fmt.Printf("%s: %d B\n", s.Name(), size)
+		fmt.Printf("%s: %d B, %d gas\n", s.Name(), size, cost.Storages[i].Gas())
 	}
-	fmt.Printf("Total size: %d B\n\n", totalBytes)
-	return nil
+	fmt.Printf("Total size: %d B\n", totalBytes)
+	fmt.Printf("Estimated deployment gas (incl. deployer): %d\n\n", cost.Gas())
+	return cost, nil
 }
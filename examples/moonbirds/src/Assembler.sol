@@ -8,9 +8,11 @@ import {Mutators} from "moonbirds-inchain/types/Mutators.sol";
 import {Image, Rectangle} from "ethier/utils/Image.sol";
 import {RawData} from "ethier/utils/RawData.sol";
 
-import {AssetStorageManager} from "moonbirds-inchain/AssetStorageManager.sol";
+import {LayerStorageManager} from
+    "moonbirds-inchain/gen/LayerStorageManager.sol";
+import {TraitStorageManager} from
+    "moonbirds-inchain/gen/TraitStorageManager.sol";
 
-import {TraitType} from "moonbirds-inchain/gen/TraitStorageMapping.sol";
 import {LayerType} from "moonbirds-inchain/gen/LayerStorageMapping.sol";
 import {Features, FeaturesLib} from "moonbirds-inchain/gen/Features.sol";
 
@@ -30,9 +32,14 @@ contract Assembler {
     // =========================================================================
 
     /**
-     * @notice The handler providing access to stored image layer + trait data.
+     * @notice The handler providing access to stored image layer data.
      */
-    AssetStorageManager public immutable assetStorageManger;
+    LayerStorageManager public immutable layerStorageManager;
+
+    /**
+     * @notice The handler providing access to stored trait data.
+     */
+    TraitStorageManager public immutable traitStorageManager;
 
     /**
      * @notice The native resolution of Moonbird images (42x42).
@@ -42,8 +49,12 @@ contract Assembler {
     // =========================================================================
     //                           Constructor
     // =========================================================================
-    constructor(AssetStorageManager assetStorageManger_) {
-        assetStorageManger = assetStorageManger_;
+    constructor(
+        LayerStorageManager layerStorageManager_,
+        TraitStorageManager traitStorageManager_
+    ) {
+        layerStorageManager = layerStorageManager_;
+        traitStorageManager = traitStorageManager_;
     }
 
     /**
@@ -84,21 +95,18 @@ contract Assembler {
         if (f.background > 0) {
             buffer.addAttribute(
                 "Background",
-                assetStorageManger.loadTrait(
-                    TraitType.Background, f.background - 1
-                )
+                traitStorageManager.loadBackground(f.background - 1)
             );
         }
 
         if (f.beak > 0) {
             buffer.addAttribute(
-                "Beak", assetStorageManger.loadTrait(TraitType.Beak, f.beak - 1)
+                "Beak", traitStorageManager.loadBeak(f.beak - 1)
             );
         }
 
         if (f.body > 0) {
-            bytes memory body =
-                bytes(assetStorageManger.loadTrait(TraitType.Body, f.body - 1));
+            bytes memory body = bytes(traitStorageManager.loadBody(f.body - 1));
 
             // The feather attribute is stored with the body trait, e.g.
             // "Emperor - Pink". We need to split this for the body and feather
@@ -124,30 +132,25 @@ contract Assembler {
 
         if (f.eyes > 0) {
             buffer.addAttribute(
-                "Eyes", assetStorageManger.loadTrait(TraitType.Eyes, f.eyes - 1)
+                "Eyes", traitStorageManager.loadEyes(f.eyes - 1)
             );
         }
 
         if (f.eyewear > 0) {
             buffer.addAttribute(
-                "Eyewear",
-                assetStorageManger.loadTrait(TraitType.Eyewear, f.eyewear - 1)
+                "Eyewear", traitStorageManager.loadEyewear(f.eyewear - 1)
             );
         }
 
         if (f.headwear > 0) {
             buffer.addAttribute(
-                "Headwear",
-                assetStorageManger.loadTrait(TraitType.Headwear, f.headwear - 1)
+                "Headwear", traitStorageManager.loadHeadwear(f.headwear - 1)
             );
         }
 
         if (f.outerwear > 0) {
             buffer.addAttribute(
-                "Outerwear",
-                assetStorageManger.loadTrait(
-                    TraitType.Outerwear, f.outerwear - 1
-                )
+                "Outerwear", traitStorageManager.loadOuterwear(f.outerwear - 1)
             );
         }
 
@@ -169,7 +172,7 @@ contract Assembler {
         // Load the PROOF background
         if (useProofBackground) {
             // Ignore the alpha info since we know that it will be zero.
-            (bytes memory bgrPixelsProof,) = assetStorageManger.loadLayer(
+            (bytes memory bgrPixelsProof,) = layerStorageManager.loadLayer(
                 LayerType.Special, 0
             ).popByteFront();
 
@@ -200,7 +203,7 @@ contract Assembler {
 
         // Load background gradient
         // Ignore the alpha info. See above
-        (bytes memory bgrPixels,) = assetStorageManger.loadLayer(
+        (bytes memory bgrPixels,) = layerStorageManager.loadLayer(
             LayerType.Gradients, f.background - 8
         ).popByteFront();
 
@@ -222,7 +225,7 @@ contract Assembler {
             return canvas;
         }
 
-        (bytes memory data, bytes1 info) = assetStorageManger.loadLayer(
+        (bytes memory data, bytes1 info) = layerStorageManager.loadLayer(
             layerType, layerValue - 1
         ).popByteFront();
 
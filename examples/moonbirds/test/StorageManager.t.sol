@@ -6,9 +6,8 @@ import "forge-std/Test.sol";
 import "forge-std/console2.sol";
 import "./TestLib.sol";
 
-import {AssetStorageManager} from "moonbirds-inchain/AssetStorageManager.sol";
-import {LayerStorageDeployer} from
-    "moonbirds-inchain/gen/LayerStorageDeployer.sol";
+import {TraitStorageManager} from
+    "moonbirds-inchain/gen/TraitStorageManager.sol";
 import {TraitStorageDeployer} from
     "moonbirds-inchain/gen/TraitStorageDeployer.sol";
 
@@ -17,18 +16,16 @@ import {TraitType} from "moonbirds-inchain/gen/TraitStorageMapping.sol";
 contract StorageManagerTest is Test {
     using TestLib for Vm;
 
-    AssetStorageManager public manager;
+    TraitStorageManager public manager;
 
     function setUp() public {
-        manager = new AssetStorageManager(
-            LayerStorageDeployer.deployAsStatic(),
-            TraitStorageDeployer.deployAsStatic()
-        );
+        manager = new TraitStorageManager(TraitStorageDeployer.deployAsStatic());
     }
 
     function testTraitsFromList() public {
         assertEq(
             manager.loadTrait(TraitType.Background, 8), "Enlightened Purple"
         );
+        assertEq(manager.loadBackground(8), "Enlightened Purple");
     }
 }
@@ -5,6 +5,7 @@
 package aggregators
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/proofxyz/solidify/go/storage"
@@ -60,35 +61,100 @@ func (s *BucketStorage) Size() (int, error) {
 }
 
 // GroupIntoStorages groups buckets into storages by limiting the max number of
-// buckets in and total size of each storage.
-func GroupIntoStorages[B storage.Bucket](buckets []B, maxStorageSize, maxBuckets int, baseName string) ([]*BucketStorage, error) {
-	var stores []*BucketStorage
-	storeBuf := new(BucketStorage)
-
-	pushStorage := func(b *BucketStorage) {
-		b.name = fmt.Sprintf("%sBucketStorage%d", baseName, len(stores))
-		stores = append(stores, b)
+// buckets in and total size of each storage. The storages are named according
+// to the given config (see storage.GeneratorConfig.StorageName), which may be
+// nil to use the defaults.
+//
+// Independent of maxStorageSize, a storage is never extended beyond the code
+// size limits of the config's target chain (see
+// storage.GeneratorConfig.TargetProfile), as determined by the bytecode
+// assembled by storage.StorageBytecode. An error wrapping
+// storage.ErrCodeSizeExceeded is returned if a single bucket exceeds them.
+// Since the size of the solc-compiled storages differs slightly, this is only
+// an estimate (see storage.ValidateStorageSizes).
+func GroupIntoStorages[B storage.Bucket](buckets []B, maxStorageSize, maxBuckets int, baseName string, cfg *storage.GeneratorConfig) ([]*BucketStorage, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("%T.Validate(): %w", cfg, err)
+	}
+	profile := cfg.TargetProfile()
+
+	var (
+		stores []*BucketStorage
+		buf    []storage.Bucket
+		// enc mirrors buf with the data of each bucket encoded only once,
+		// such that the bytecode of the growing storage can be assembled for
+		// every appended bucket without re-encoding the previous ones.
+		enc  []storage.Bucket
+		size int
+	)
+
+	pushStorage := func() {
+		stores = append(stores, &BucketStorage{
+			name:    cfg.StorageName(baseName, len(stores)),
+			buckets: buf,
+		})
+		buf, enc, size = nil, nil, 0
 	}
 
-	for _, b := range buckets {
-		storeBuf.buckets = append(storeBuf.buckets, b)
-
-		s, err := storeBuf.Size()
+	for i, b := range buckets {
+		d, err := b.Data()
+		if err != nil {
+			return nil, fmt.Errorf("bucket %d: %T.Data(): %w", i, b, err)
+		}
+		e := &encodedBucket{Bucket: b, data: d, format: storage.BucketFormatOf(b)}
+		buf = append(buf, b)
+		enc = append(enc, e)
+		size += len(d)
+
+		err = validateSize(enc, cfg.StorageName(baseName, len(stores)), profile)
+		if n := len(buf) - 1; n > 0 && errors.Is(err, storage.ErrCodeSizeExceeded) {
+			buf, enc, size = buf[:n:n], enc[:n:n], size-len(d)
+			pushStorage()
+			buf, enc, size = []storage.Bucket{b}, []storage.Bucket{e}, len(d)
+			err = validateSize(enc, cfg.StorageName(baseName, len(stores)), profile)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("%T.Size(): %w", storeBuf, err)
+			return nil, fmt.Errorf("bucket %d: %w", i, err)
 		}
 
-		if (maxStorageSize >= 0 && s > maxStorageSize) ||
+		if (maxStorageSize >= 0 && size > maxStorageSize) ||
 			(maxBuckets >= 0 &&
-				len(storeBuf.buckets) >= maxBuckets) {
-			pushStorage(storeBuf)
-			storeBuf = new(BucketStorage)
+				len(buf) >= maxBuckets) {
+			pushStorage()
 		}
 	}
 
-	if len(storeBuf.buckets) > 0 {
-		pushStorage(storeBuf)
+	if len(buf) > 0 {
+		pushStorage()
 	}
 
 	return stores, nil
 }
+
+// encodedBucket is a storage.Bucket with cached data.
+type encodedBucket struct {
+	storage.Bucket
+	data   []byte
+	format storage.BucketFormat
+}
+
+// Data returns the cached data.
+func (b *encodedBucket) Data() ([]byte, error) {
+	return b.data, nil
+}
+
+// Format returns the format of the original bucket.
+func (b *encodedBucket) Format() storage.BucketFormat {
+	return b.format
+}
+
+// validateSize checks the bytecode of a storage with given buckets, which is
+// yet to be named, against the size limits of a profile.
+func validateSize(buckets []storage.Bucket, name string, p *storage.ChainProfile) error {
+	s := &BucketStorage{name: name, buckets: buckets}
+	code, err := storage.StorageBytecode(s)
+	if err != nil {
+		return fmt.Errorf("storage.StorageBytecode(%T): %w", s, err)
+	}
+	return p.ValidateSize(name, code)
+}
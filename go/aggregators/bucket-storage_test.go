@@ -0,0 +1,97 @@
+package aggregators
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/proofxyz/solidify/go/storage"
+)
+
+// countingBucket counts the calls to Data() of the wrapped bucket.
+type countingBucket struct {
+	storage.Bucket
+	calls *int
+}
+
+func (b countingBucket) Data() ([]byte, error) {
+	*b.calls++
+	return b.Bucket.Data()
+}
+
+func TestGroupIntoStoragesEncodesOnce(t *testing.T) {
+	indexed, err := GroupIntoIndexedBuckets(testFields(50, 100), 300)
+	if err != nil {
+		t.Fatalf("GroupIntoIndexedBuckets(…) error %v", err)
+	}
+	calls := make([]int, len(indexed))
+	var buckets []countingBucket
+	for i, b := range indexed {
+		buckets = append(buckets, countingBucket{Bucket: b, calls: &calls[i]})
+	}
+
+	stores, err := GroupIntoStorages(buckets, 3000, -1, "Test", nil)
+	if err != nil {
+		t.Fatalf("GroupIntoStorages(…) error %v", err)
+	}
+	if len(stores) < 2 {
+		t.Errorf("GroupIntoStorages(…) got %d storages; want split into multiple", len(stores))
+	}
+	for i, n := range calls {
+		if n != 1 {
+			t.Errorf("GroupIntoStorages(…) called Data() of bucket %d %d times; want once", i, n)
+		}
+	}
+}
+
+func TestGroupIntoStoragesTargetProfile(t *testing.T) {
+	buckets, err := GroupIntoIndexedBuckets(testFields(20, 200), 500)
+	if err != nil {
+		t.Fatalf("GroupIntoIndexedBuckets(…) error %v", err)
+	}
+
+	target := *storage.ProfileMainnet
+	target.Name = "small"
+	target.MaxCodeSize = 2000
+	cfg := &storage.GeneratorConfig{Target: &target}
+
+	stores, err := GroupIntoStorages(buckets, -1, -1, "Test", cfg)
+	if err != nil {
+		t.Fatalf("GroupIntoStorages(…, [target %q]) error %v", target.Name, err)
+	}
+	if len(stores) < 2 {
+		t.Errorf("GroupIntoStorages(…, [target %q]) got %d storages; want split into multiple", target.Name, len(stores))
+	}
+
+	var got []storage.Bucket
+	for i, s := range stores {
+		if want := cfg.StorageName("Test", i); s.Name() != want {
+			t.Errorf("storage %d named %q; want %q", i, s.Name(), want)
+		}
+		code, err := storage.StorageBytecode(s)
+		if err != nil {
+			t.Fatalf("storage.StorageBytecode(%q) error %v", s.Name(), err)
+		}
+		if n := len(code.Deployed); n > target.MaxCodeSize {
+			t.Errorf("storage %q has %d bytes of code; want <= %d", s.Name(), n, target.MaxCodeSize)
+		}
+		got = append(got, s.Buckets()...)
+	}
+
+	var want []storage.Bucket
+	for _, b := range buckets {
+		want = append(want, b)
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(IndexedBucket{})); diff != "" {
+		t.Errorf("buckets of all storages diff (-want +got):\n%s", diff)
+	}
+
+	t.Run("bucket exceeding limit", func(t *testing.T) {
+		tiny := target
+		tiny.MaxCodeSize = 100
+		_, err := GroupIntoStorages(buckets, -1, -1, "Test", &storage.GeneratorConfig{Target: &tiny})
+		if !errors.Is(err, storage.ErrCodeSizeExceeded) {
+			t.Errorf("GroupIntoStorages(…, [target with max code size %d]) error %v; want %v", tiny.MaxCodeSize, err, storage.ErrCodeSizeExceeded)
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package aggregators
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/proofxyz/solidify/go/storage"
+)
+
+// A FieldRef identifies a field in a GroupedBundle by the index of its group
+// and its index in that group.
+type FieldRef struct {
+	Group, Index int
+}
+
+// CoAccessObjective selects the number of bucket inflations that co-access
+// packing minimises.
+type CoAccessObjective int
+
+// Possible CoAccessObjective values.
+const (
+	// MinimizeMeanInflates minimises the expected number of buckets inflated
+	// per access, assuming that all accesses are equally likely.
+	MinimizeMeanInflates CoAccessObjective = iota
+	// MinimizeMaxInflates minimises the number of buckets inflated by the most
+	// expensive accesses first and the expected number second.
+	MinimizeMaxInflates
+)
+
+// CoAccess configures co-access-aware packing of a GroupedBundle, which places
+// fields that are frequently retrieved together into the same bucket instead
+// of packing them in the order of the groups.
+type CoAccess struct {
+	// Accesses lists the fields retrieved together, e.g. one entry per token
+	// listing the fields required to render it (see TokenAccesses).
+	Accesses [][]FieldRef
+	// Objective defaults to MinimizeMeanInflates.
+	Objective CoAccessObjective
+}
+
+// TokenAccesses maps each token to the fields it accesses, for use as
+// CoAccess.Accesses.
+func TokenAccesses[T any](tokens []T, fields func(T) ([]FieldRef, error)) ([][]FieldRef, error) {
+	accs := make([][]FieldRef, len(tokens))
+	for i, t := range tokens {
+		refs, err := fields(t)
+		if err != nil {
+			return nil, fmt.Errorf("token %d: %w", i, err)
+		}
+		accs[i] = refs
+	}
+	return accs, nil
+}
+
+// InflateStats summarises the number of distinct buckets inflated per access.
+type InflateStats struct {
+	Mean float64
+	Max  int
+}
+
+// InflateStats computes the number of buckets inflated by each of the given
+// accesses, e.g. to compare sequential and co-access packing.
+func (b *GroupedBundle[G]) InflateStats(accesses [][]FieldRef) (InflateStats, error) {
+	var (
+		st    InflateStats
+		total int
+	)
+	for i, acc := range accesses {
+		buckets := make(map[[2]int]bool)
+		for _, r := range acc {
+			l, err := b.Locate(r.Group, r.Index)
+			if err != nil {
+				return InflateStats{}, fmt.Errorf("access %d: %w", i, err)
+			}
+			buckets[[2]int{l.StorageID, l.BucketID}] = true
+		}
+		total += len(buckets)
+		if len(buckets) > st.Max {
+			st.Max = len(buckets)
+		}
+	}
+	if len(accesses) > 0 {
+		st.Mean = float64(total) / float64(len(accesses))
+	}
+	return st, nil
+}
+
+// coAccessCluster is a set of fields destined for the same bucket.
+type coAccessCluster struct {
+	// fields are the flat indices of the fields in the cluster.
+	fields []int
+	size   int
+}
+
+// packByCoAccess groups fields into IndexedBuckets such that fields retrieved
+// together share buckets. It returns the buckets and the position of each
+// field, indexed by group and field index in that group.
+//
+// Starting with one cluster per field, the pair of clusters whose merger
+// saves the most inflations, subject to maxBucketSize, is merged greedily
+// until no merger yields further savings. Since mergers never increase the
+// number of inflations, the remaining clusters are then combined first-fit to
+// reduce the number of buckets. Every merger recounts the savings of all
+// accesses, so the runtime is O(m·a·k²) for m mergers, a accesses and at most
+// k fields per access.
+func packByCoAccess[G FieldsGroup](groups []G, co CoAccess, maxBucketSize int) ([]*IndexedBucket, [][]bucketPosition, error) {
+	var (
+		offsets = make([]int, len(groups))
+		fields  []storage.Field
+		refs    []FieldRef
+	)
+	for g, grp := range groups {
+		offsets[g] = len(fields)
+		for i, f := range grp.Fields() {
+			fields = append(fields, f)
+			refs = append(refs, FieldRef{Group: g, Index: i})
+		}
+	}
+
+	clusters := make([]*coAccessCluster, len(fields))
+	for i, f := range fields {
+		d, err := f.Encode()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%T.Encode(): %w", f, err)
+		}
+		// Accounting for the index header of IndexedBucket.
+		clusters[i] = &coAccessCluster{fields: []int{i}, size: len(d) + 2}
+	}
+
+	accesses := make([][]int, len(co.Accesses))
+	for i, acc := range co.Accesses {
+		for _, r := range acc {
+			if r.Group < 0 || r.Group >= len(groups) || r.Index < 0 || r.Index >= groups[r.Group].NumFields() {
+				return nil, nil, fmt.Errorf("access %d: field %+v out of range", i, r)
+			}
+			accesses[i] = append(accesses[i], offsets[r.Group]+r.Index)
+		}
+	}
+
+	// clusterOf maps each field to the index of its cluster in clusters, of
+	// which merged ones are set to nil.
+	clusterOf := make([]int, len(fields))
+	for i := range clusterOf {
+		clusterOf[i] = i
+	}
+	fits := func(a, b int) bool {
+		return maxBucketSize < 0 || clusters[a].size+clusters[b].size <= maxBucketSize
+	}
+	merge := func(a, b int) {
+		clusters[a].fields = append(clusters[a].fields, clusters[b].fields...)
+		clusters[a].size += clusters[b].size
+		for _, f := range clusters[b].fields {
+			clusterOf[f] = a
+		}
+		clusters[b] = nil
+	}
+
+	type savings struct{ worst, total int }
+	for {
+		touched := make([][]int, len(accesses))
+		var maxTouched int
+		for i, acc := range accesses {
+			seen := make(map[int]bool)
+			for _, f := range acc {
+				if c := clusterOf[f]; !seen[c] {
+					seen[c] = true
+					touched[i] = append(touched[i], c)
+				}
+			}
+			if n := len(touched[i]); n > maxTouched {
+				maxTouched = n
+			}
+		}
+
+		pairs := make(map[[2]int]*savings)
+		for _, cs := range touched {
+			for x, a := range cs {
+				for _, b := range cs[x+1:] {
+					p := [2]int{a, b}
+					if a > b {
+						p = [2]int{b, a}
+					}
+					if !fits(p[0], p[1]) {
+						continue
+					}
+					s, ok := pairs[p]
+					if !ok {
+						s = new(savings)
+						pairs[p] = s
+					}
+					s.total++
+					if len(cs) == maxTouched {
+						s.worst++
+					}
+				}
+			}
+		}
+
+		var (
+			best     [2]int
+			bestSave *savings
+		)
+		better := func(s *savings, p [2]int) bool {
+			if bestSave == nil {
+				return true
+			}
+			if co.Objective == MinimizeMaxInflates && s.worst != bestSave.worst {
+				return s.worst > bestSave.worst
+			}
+			if s.total != bestSave.total {
+				return s.total > bestSave.total
+			}
+			return p[0] < best[0] || (p[0] == best[0] && p[1] < best[1])
+		}
+		for p, s := range pairs {
+			if better(s, p) {
+				best, bestSave = p, s
+			}
+		}
+		if bestSave == nil {
+			break
+		}
+		merge(best[0], best[1])
+	}
+
+	// Combining the remaining clusters first-fit in the order of their first
+	// fields.
+	var remaining []int
+	for i, c := range clusters {
+		if c != nil {
+			remaining = append(remaining, i)
+		}
+	}
+	var bins []int
+	for _, c := range remaining {
+		placed := false
+		for _, b := range bins {
+			if fits(b, c) {
+				merge(b, c)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			bins = append(bins, c)
+		}
+	}
+
+	buckets := make([]*IndexedBucket, len(bins))
+	pos := make([][]bucketPosition, len(groups))
+	for g, grp := range groups {
+		pos[g] = make([]bucketPosition, grp.NumFields())
+	}
+	for i, b := range bins {
+		fs := clusters[b].fields
+		sort.Ints(fs)
+
+		buckets[i] = new(IndexedBucket)
+		for j, f := range fs {
+			if err := buckets[i].AddField(fields[f]); err != nil {
+				return nil, nil, fmt.Errorf("%T.AddField(%v): %w", buckets[i], fields[f], err)
+			}
+			r := refs[f]
+			pos[r.Group][r.Index] = bucketPosition{bucket: i, field: j}
+		}
+	}
+
+	return buckets, pos, nil
+}
+
+// bucketPosition is the position of a field in a slice of buckets.
+type bucketPosition struct {
+	bucket, field int
+}
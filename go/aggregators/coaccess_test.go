@@ -0,0 +1,185 @@
+package aggregators
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/types"
+)
+
+// coAccessGroups returns two groups of three incompressible fields each.
+func coAccessGroups() []testGroup {
+	fs := testFields(6, 100)
+	return []testGroup{
+		{name: "A", values: fs[:3]},
+		{name: "B", values: fs[3:]},
+	}
+}
+
+func TestCoAccessPacking(t *testing.T) {
+	groups := coAccessGroups()
+
+	// Every token uses one field of each group, which are never adjacent in
+	// the sequential packing.
+	type token struct{ a, b int }
+	tokens := []token{{0, 2}, {1, 0}, {2, 1}, {0, 2}}
+	accesses, err := TokenAccesses(tokens, func(t token) ([]FieldRef, error) {
+		return []FieldRef{{Group: 0, Index: t.a}, {Group: 1, Index: t.b}}, nil
+	})
+	if err != nil {
+		t.Fatalf("TokenAccesses(…) error %v", err)
+	}
+
+	// Fits exactly two fields including the index header.
+	const maxBucketSize = 2 * (100 + 2)
+	sequential, err := NewGroupedBundle("Test", groups, GroupedBundleConfig{
+		MaxBucketSize:        maxBucketSize - 1,
+		MaxStorageSize:       -1,
+		MaxBucketsPerStorage: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewGroupedBundle(…, [sequential]) error %v", err)
+	}
+	seqStats, err := sequential.InflateStats(accesses)
+	if err != nil {
+		t.Fatalf("%T.InflateStats(…) error %v", sequential, err)
+	}
+	if diff := cmp.Diff(InflateStats{Mean: 2, Max: 2}, seqStats); diff != "" {
+		t.Fatalf("sequential %T.InflateStats(…) diff (-want +got):\n%s", sequential, diff)
+	}
+
+	for _, obj := range []CoAccessObjective{MinimizeMeanInflates, MinimizeMaxInflates} {
+		cfg := GroupedBundleConfig{
+			MaxBucketSize:        maxBucketSize,
+			MaxStorageSize:       -1,
+			MaxBucketsPerStorage: 2,
+			CoAccess:             &CoAccess{Accesses: accesses, Objective: obj},
+			Generator:            &storage.GeneratorConfig{Output: new(storage.MemFS)},
+		}
+		b, err := NewGroupedBundle("Test", groups, cfg)
+		if err != nil {
+			t.Fatalf("NewGroupedBundle(…, [co-access objective %d]) error %v", obj, err)
+		}
+
+		got, err := b.InflateStats(accesses)
+		if err != nil {
+			t.Fatalf("%T.InflateStats(…) error %v", b, err)
+		}
+		if diff := cmp.Diff(InflateStats{Mean: 1, Max: 1}, got); diff != "" {
+			t.Errorf("co-access (objective %d) %T.InflateStats(…) diff (-want +got):\n%s", obj, b, diff)
+		}
+
+		// Every location must point to the respective field.
+		for g, grp := range groups {
+			for i, v := range grp.values {
+				l, err := b.Locate(g, i)
+				if err != nil {
+					t.Fatalf("%T.Locate(%d, %d) error %v", b, g, i, err)
+				}
+				bucket := b.Storages()[l.StorageID].Buckets()[l.BucketID].(*IndexedBucket)
+				if got := bucket.Fields()[l.FieldID].(types.StringField); got != v {
+					t.Errorf("%T.Locate(%d, %d) = %+v pointing to a different field", b, g, i, l)
+				}
+			}
+		}
+
+		fnames, err := b.WriteContracts("gen")
+		if err != nil {
+			t.Fatalf("%T.WriteContracts() error %v", b, err)
+		}
+		mapping := cfg.Generator.Output.(*storage.MemFS).Files()["gen/TestStorageMapping.sol"]
+		if !bytes.Contains(mapping, []byte("function _fieldEntry(")) {
+			t.Errorf("%T.WriteContracts() wrote %v; want table storage mapping, got:\n%s", b, fnames, mapping)
+		}
+	}
+}
+
+func TestCoAccessObjectives(t *testing.T) {
+	// Fields x, y, z, w and v in a single group, with buckets fitting two of
+	// them. Most accesses retrieve x and y, but a single one retrieves all
+	// fields but y.
+	groups := []testGroup{{name: "A", values: testFields(5, 100)}}
+	const x, y, z, w, v = 0, 1, 2, 3, 4
+	accesses := [][]FieldRef{
+		{{Index: x}, {Index: y}},
+		{{Index: x}, {Index: y}},
+		{{Index: x}, {Index: y}},
+		{{Index: x}, {Index: z}, {Index: w}, {Index: v}},
+	}
+
+	tests := []struct {
+		objective CoAccessObjective
+		want      InflateStats
+	}{
+		{
+			// Pairing x and y serves the frequent accesses at the expense
+			// of the rare one, which has to inflate {x, y}, {z, w} and {v}.
+			objective: MinimizeMeanInflates,
+			want:      InflateStats{Mean: 1.5, Max: 3},
+		},
+		{
+			// Pairing x with z and w with v limits every access to two
+			// buckets.
+			objective: MinimizeMaxInflates,
+			want:      InflateStats{Mean: 2, Max: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		b, err := NewGroupedBundle("Test", groups, GroupedBundleConfig{
+			MaxBucketSize:        2 * (100 + 2),
+			MaxStorageSize:       -1,
+			MaxBucketsPerStorage: -1,
+			CoAccess:             &CoAccess{Accesses: accesses, Objective: tt.objective},
+		})
+		if err != nil {
+			t.Fatalf("NewGroupedBundle(…, [co-access objective %d]) error %v", tt.objective, err)
+		}
+		got, err := b.InflateStats(accesses)
+		if err != nil {
+			t.Fatalf("%T.InflateStats(…) error %v", b, err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("co-access (objective %d) %T.InflateStats(…) diff (-want +got):\n%s", tt.objective, b, diff)
+		}
+	}
+}
+
+func TestCoAccessPackingErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          GroupedBundleConfig
+		wantContains string
+	}{
+		{
+			name: "BucketPerGroup",
+			cfg: GroupedBundleConfig{
+				MaxBucketSize:  -1,
+				MaxStorageSize: -1,
+				BucketPerGroup: true,
+				CoAccess:       &CoAccess{},
+			},
+			wantContains: "incompatible",
+		},
+		{
+			name: "Field out of range",
+			cfg: GroupedBundleConfig{
+				MaxBucketSize:  -1,
+				MaxStorageSize: -1,
+				CoAccess:       &CoAccess{Accesses: [][]FieldRef{{{Group: 1, Index: 3}}}},
+			},
+			wantContains: "out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGroupedBundle("Test", coAccessGroups(), tt.cfg); err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("NewGroupedBundle(…) got err %v; want containing %q", err, tt.wantContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package aggregators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/proofxyz/solidify/go/storage"
+)
+
+// FieldsGroup is a storage.FieldsGroup that also exposes its fields.
+type FieldsGroup interface {
+	storage.FieldsGroup
+	Fields() []storage.Field
+}
+
+// GroupedBundleConfig controls how a GroupedBundle packs its fields.
+// Negative values disable the respective limit.
+type GroupedBundleConfig struct {
+	// MaxBucketSize limits the uncompressed size of each IndexedBucket (see
+	// also GroupIntoIndexedBuckets).
+	MaxBucketSize int
+	// MaxStorageSize limits the total size of each BucketStorage (see also
+	// GroupIntoStorages).
+	MaxStorageSize int
+	// MaxBucketsPerStorage limits the number of buckets in each BucketStorage
+	// (see also GroupIntoStorages).
+	MaxBucketsPerStorage int
+	// BucketPerGroup starts a new bucket for every group, such that fields of
+	// different groups never share a bucket.
+	BucketPerGroup bool
+	// TableMapping writes a table storage mapping instead of a sequential one
+	// (see also storage.WriteTableStorageMapping).
+	TableMapping bool
+	// CoAccess, if not nil, packs fields that are frequently accessed together
+	// into the same buckets instead of packing them in order. This is
+	// incompatible with BucketPerGroup and implies TableMapping, which
+	// reflects the chosen placement.
+	CoAccess *CoAccess
+	// Encryption, if not nil, encrypts the data of all buckets with the given
+	// secret, such that the fields can only be read once it is revealed via
+	// the generated storage manager (see storage.EncryptedBucket).
+	Encryption *storage.RevealSecret
+	// Generator controls the conventions of the generated contracts. If nil,
+	// the defaults are used.
+	Generator *storage.GeneratorConfig
+}
+
+// GroupedBundle packs groups of fields into IndexedBuckets and BucketStorages
+// and writes the corresponding contracts from the same packing plan. This
+// guarantees that the generated storage mapping always matches the order in
+// which fields were packed.
+type GroupedBundle[G FieldsGroup] struct {
+	name   string
+	cfg    GroupedBundleConfig
+	groups []G
+	stores []*BucketStorage
+	locs   [][]storage.FieldLocation
+}
+
+// NewGroupedBundle packs the fields of the given groups in order, unless
+// configured otherwise by cfg.CoAccess. The order of the groups also
+// determines the order of the types in the generated storage mapping.
+func NewGroupedBundle[G FieldsGroup](name string, groups []G, cfg GroupedBundleConfig) (*GroupedBundle[G], error) {
+	b := &GroupedBundle[G]{
+		name:   name,
+		cfg:    cfg,
+		groups: make([]G, len(groups)),
+	}
+	// Copying the groups to prevent changes of the caller's slice (e.g.
+	// sorting) from affecting the alignment with the packed fields.
+	copy(b.groups, groups)
+
+	for _, g := range b.groups {
+		if got, want := len(g.Fields()), g.NumFields(); got != want {
+			return nil, fmt.Errorf("group %q exposes %d fields but reports %d", g.Name(), got, want)
+		}
+	}
+	if cfg.CoAccess != nil {
+		if cfg.BucketPerGroup {
+			return nil, errors.New("co-access packing is incompatible with BucketPerGroup")
+		}
+		return b.packByCoAccess()
+	}
+
+	maxBucketSize := cfg.MaxBucketSize
+	if maxBucketSize < 0 {
+		maxBucketSize = math.MaxInt
+	}
+
+	var (
+		buckets []*IndexedBucket
+		pending []storage.Field
+	)
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		bs, err := GroupIntoIndexedBuckets(pending, maxBucketSize)
+		if err != nil {
+			return fmt.Errorf("GroupIntoIndexedBuckets([fields], %d): %w", maxBucketSize, err)
+		}
+		buckets = append(buckets, bs...)
+		pending = nil
+		return nil
+	}
+
+	for _, g := range b.groups {
+		pending = append(pending, g.Fields()...)
+
+		if cfg.BucketPerGroup {
+			if err := flush(); err != nil {
+				return nil, fmt.Errorf("packing group %q: %w", g.Name(), err)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	if err := b.groupIntoStorages(buckets); err != nil {
+		return nil, err
+	}
+
+	locs, err := storage.SequentialFieldLocations(b.groups, b.stores)
+	if err != nil {
+		return nil, fmt.Errorf("storage.SequentialFieldLocations(…): %w", err)
+	}
+	b.locs = locs
+
+	return b, nil
+}
+
+// packByCoAccess packs the fields according to b.cfg.CoAccess.
+func (b *GroupedBundle[G]) packByCoAccess() (*GroupedBundle[G], error) {
+	buckets, pos, err := packByCoAccess(b.groups, *b.cfg.CoAccess, b.cfg.MaxBucketSize)
+	if err != nil {
+		return nil, fmt.Errorf("co-access packing: %w", err)
+	}
+	if err := b.groupIntoStorages(buckets); err != nil {
+		return nil, err
+	}
+
+	// GroupIntoStorages retains the order of the buckets.
+	var bucketLocs []storage.FieldLocation
+	for s, st := range b.stores {
+		for i := range st.Buckets() {
+			bucketLocs = append(bucketLocs, storage.FieldLocation{StorageID: s, BucketID: i})
+		}
+	}
+
+	b.locs = make([][]storage.FieldLocation, len(pos))
+	for g, ps := range pos {
+		b.locs[g] = make([]storage.FieldLocation, len(ps))
+		for i, p := range ps {
+			l := bucketLocs[p.bucket]
+			l.FieldID = p.field
+			b.locs[g][i] = l
+		}
+	}
+
+	return b, nil
+}
+
+// groupIntoStorages groups the buckets into b.stores, encrypting them if
+// configured.
+func (b *GroupedBundle[G]) groupIntoStorages(buckets []*IndexedBucket) error {
+	cfg := b.cfg
+	bs := make([]storage.Bucket, len(buckets))
+	for i, bucket := range buckets {
+		bs[i] = bucket
+		if cfg.Encryption != nil {
+			bs[i] = storage.EncryptBucket(bucket, *cfg.Encryption)
+		}
+	}
+
+	stores, err := GroupIntoStorages(bs, cfg.MaxStorageSize, cfg.MaxBucketsPerStorage, b.name, cfg.Generator)
+	if err != nil {
+		return fmt.Errorf("GroupIntoStorages([buckets], %d, %d, %q, [config]): %w", cfg.MaxStorageSize, cfg.MaxBucketsPerStorage, b.name, err)
+	}
+	b.stores = stores
+	return nil
+}
+
+// Name returns the name of the bundle.
+func (b *GroupedBundle[G]) Name() string {
+	return b.name
+}
+
+// Groups returns the groups in the order in which they were packed.
+func (b *GroupedBundle[G]) Groups() []G {
+	return b.groups
+}
+
+// FieldsGroups returns the groups as storage.FieldsGroups, e.g. to share the
+// bundle's storages with other bundles (see NewSharedBundle).
+func (b *GroupedBundle[G]) FieldsGroups() []storage.FieldsGroup {
+	gs := make([]storage.FieldsGroup, len(b.groups))
+	for i, g := range b.groups {
+		gs[i] = g
+	}
+	return gs
+}
+
+// Storages returns the packed BucketStorages.
+func (b *GroupedBundle[G]) Storages() []*BucketStorage {
+	return b.stores
+}
+
+// Locate returns the location of the field with a given index in a given
+// group.
+func (b *GroupedBundle[G]) Locate(group, index int) (storage.FieldLocation, error) {
+	if group < 0 || group >= len(b.locs) {
+		return storage.FieldLocation{}, fmt.Errorf("group %d out of range [0, %d)", group, len(b.locs))
+	}
+	if index < 0 || index >= len(b.locs[group]) {
+		return storage.FieldLocation{}, fmt.Errorf("index %d out of range [0, %d) for group %q", index, len(b.locs[group]), b.groups[group].Name())
+	}
+	return b.locs[group][index], nil
+}
+
+// WriteContracts writes all contracts of the bundle to a given output
+// directory. Returns the paths of the written files.
+func (b *GroupedBundle[G]) WriteContracts(outputDir string) ([]string, error) {
+	if b.cfg.CoAccess != nil {
+		return storage.WriteTableGroupStorageFromLocations(b.name, b.groups, b.stores, b.locs, b.cfg.Generator, outputDir)
+	}
+	if b.cfg.TableMapping {
+		return storage.WriteTableGroupStorage(b.name, b.groups, b.stores, b.cfg.Generator, outputDir)
+	}
+	return storage.WriteGroupStorage(b.name, b.groups, b.stores, b.cfg.Generator, outputDir)
+}
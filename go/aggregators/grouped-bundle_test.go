@@ -0,0 +1,166 @@
+package aggregators
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/types"
+)
+
+type testGroup struct {
+	name   string
+	values []types.StringField
+}
+
+func (g testGroup) Name() string {
+	return g.name
+}
+
+func (g testGroup) NumFields() int {
+	return len(g.values)
+}
+
+func (g testGroup) Fields() []storage.Field {
+	fs := make([]storage.Field, len(g.values))
+	for i, v := range g.values {
+		fs[i] = v
+	}
+	return fs
+}
+
+func loc(s, b, f int) storage.FieldLocation {
+	return storage.FieldLocation{StorageID: s, BucketID: b, FieldID: f}
+}
+
+func TestGroupedBundle(t *testing.T) {
+	groups := []testGroup{
+		{name: "FOO", values: []types.StringField{"foo0", "foo1"}},
+		{name: "BAR", values: []types.StringField{"bar0", "bar1", "bar2"}},
+		{name: "QUX", values: []types.StringField{"qux0"}},
+	}
+
+	tests := []struct {
+		name string
+		cfg  GroupedBundleConfig
+		want [][]storage.FieldLocation
+	}{
+		{
+			name: "Unlimited",
+			cfg:  GroupedBundleConfig{MaxBucketSize: -1, MaxStorageSize: -1, MaxBucketsPerStorage: -1},
+			want: [][]storage.FieldLocation{
+				{loc(0, 0, 0), loc(0, 0, 1)},
+				{loc(0, 0, 2), loc(0, 0, 3), loc(0, 0, 4)},
+				{loc(0, 0, 5)},
+			},
+		},
+		{
+			name: "Bucket per group",
+			cfg:  GroupedBundleConfig{MaxBucketSize: -1, MaxStorageSize: -1, MaxBucketsPerStorage: 2, BucketPerGroup: true},
+			want: [][]storage.FieldLocation{
+				{loc(0, 0, 0), loc(0, 0, 1)},
+				{loc(0, 1, 0), loc(0, 1, 1), loc(0, 1, 2)},
+				{loc(1, 0, 0)},
+			},
+		},
+		{
+			name: "Limited bucket size",
+			// Each field takes 4 bytes + 2 bytes header
+			cfg: GroupedBundleConfig{MaxBucketSize: 12, MaxStorageSize: -1, MaxBucketsPerStorage: -1},
+			want: [][]storage.FieldLocation{
+				{loc(0, 0, 0), loc(0, 0, 1)},
+				{loc(0, 0, 2), loc(0, 1, 0), loc(0, 1, 1)},
+				{loc(0, 1, 2)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewGroupedBundle("Test", groups, tt.cfg)
+			if err != nil {
+				t.Fatalf("NewGroupedBundle(%q, [groups], %+v) error %v", "Test", tt.cfg, err)
+			}
+
+			var got [][]storage.FieldLocation
+			for i, g := range b.Groups() {
+				var locs []storage.FieldLocation
+				for j := 0; j < g.NumFields(); j++ {
+					l, err := b.Locate(i, j)
+					if err != nil {
+						t.Fatalf("%T.Locate(%d, %d) error %v", b, i, j, err)
+					}
+					locs = append(locs, l)
+				}
+				got = append(got, locs)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("NewGroupedBundle(%q, [groups], %+v) locations diff (-want +got):\n%v", "Test", tt.cfg, diff)
+			}
+		})
+	}
+}
+
+func TestGroupedBundleIsolatedFromCallerOrder(t *testing.T) {
+	groups := []testGroup{
+		{name: "FOO", values: []types.StringField{"foo0"}},
+		{name: "BAR", values: []types.StringField{"bar0", "bar1"}},
+	}
+
+	b, err := NewGroupedBundle("Test", groups, GroupedBundleConfig{MaxBucketSize: -1, MaxStorageSize: -1, MaxBucketsPerStorage: -1})
+	if err != nil {
+		t.Fatalf("NewGroupedBundle(…) error %v", err)
+	}
+
+	groups[0], groups[1] = groups[1], groups[0]
+
+	if got := b.Groups()[0].Name(); got != "FOO" {
+		t.Errorf("%T.Groups()[0].Name() = %q after reordering caller slice, want %q", b, got, "FOO")
+	}
+}
+
+func TestGroupedBundleEncryption(t *testing.T) {
+	groups := []testGroup{
+		{name: "FOO", values: []types.StringField{"foo0", "foo1"}},
+		{name: "BAR", values: []types.StringField{"bar0"}},
+	}
+	secret := storage.RevealSecret{42}
+	cfg := GroupedBundleConfig{
+		MaxBucketSize:        -1,
+		MaxStorageSize:       -1,
+		MaxBucketsPerStorage: -1,
+		BucketPerGroup:       true,
+		Encryption:           &secret,
+		Generator:            &storage.GeneratorConfig{Output: new(storage.MemFS)},
+	}
+	b, err := NewGroupedBundle("Test", groups, cfg)
+	if err != nil {
+		t.Fatalf("NewGroupedBundle(…) error %v", err)
+	}
+
+	for g, grp := range groups {
+		for i, v := range grp.values {
+			l, err := b.Locate(g, i)
+			if err != nil {
+				t.Fatalf("%T.Locate(%d, %d) error %v", b, g, i, err)
+			}
+			e, ok := b.Storages()[l.StorageID].Buckets()[l.BucketID].(*storage.EncryptedBucket)
+			if !ok {
+				t.Fatalf("bucket of field (%d, %d) is not encrypted", g, i)
+			}
+			if got := e.Unwrap().(*IndexedBucket).Fields()[l.FieldID].(types.StringField); got != v {
+				t.Errorf("%T.Locate(%d, %d) = %+v pointing to %q; want %q", b, g, i, l, got, v)
+			}
+		}
+	}
+
+	if _, err := b.WriteContracts("gen"); err != nil {
+		t.Fatalf("%T.WriteContracts() error %v", b, err)
+	}
+	manager := cfg.Generator.Output.(*storage.MemFS).Files()["gen/TestStorageManager.sol"]
+	if !bytes.Contains(manager, []byte(secret.Commitment().Hex())) {
+		t.Errorf("%T.WriteContracts() wrote manager without commitment %v:\n%s", b, secret.Commitment(), manager)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
 
 	"github.com/proofxyz/solidify/go/deflate"
 	"github.com/proofxyz/solidify/go/storage"
@@ -44,18 +45,35 @@ func (b *IndexedBucket) NumFields() int {
 	return len(b.fields)
 }
 
+// Fields returns the fields in the bucket in the order they were added
+func (b *IndexedBucket) Fields() []storage.Field {
+	return append([]storage.Field(nil), b.fields...)
+}
+
+// Format returns the format descriptor of the bucket.
+func (b *IndexedBucket) Format() storage.BucketFormat {
+	return storage.BucketFormat{
+		Flavour:    storage.FlavourIndexed,
+		Codec:      storage.CodecDeflate,
+		OffsetBits: 16,
+	}
+}
+
 // Data returns the encoded and compressed data blob of the bucket
 func (b *IndexedBucket) Data() ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
 	buf.Grow(len(b.fields)*2 + len(b.payload))
 
 	// Build index header
-	t := uint16(len(b.fields)) * 2
-	for _, v := range b.fieldSizes {
-		if err := binary.Write(buf, binary.BigEndian, t); err != nil {
+	t := len(b.fields) * 2
+	for i, v := range b.fieldSizes {
+		if t > math.MaxUint16 {
+			return nil, fmt.Errorf("offset %d of field %d exceeds the uint16 index header", t, i)
+		}
+		if err := binary.Write(buf, binary.BigEndian, uint16(t)); err != nil {
 			return nil, fmt.Errorf("binary.Write(%T, %v, %v): %w", buf, binary.BigEndian, t, err)
 		}
-		t += v
+		t += int(v)
 	}
 
 	// Append data
@@ -60,6 +60,25 @@ func (b *LabelledBucket) NumFields() int {
 	return len(b.fields)
 }
 
+// Fields returns the fields in the bucket in the order they were added
+func (b *LabelledBucket) Fields() []storage.Field {
+	fs := make([]storage.Field, len(b.fields))
+	for i, f := range b.fields {
+		fs[i] = f
+	}
+	return fs
+}
+
+// Format returns the format descriptor of the bucket.
+func (b *LabelledBucket) Format() storage.BucketFormat {
+	return storage.BucketFormat{
+		Flavour:   storage.FlavourLabelled,
+		Codec:     storage.CodecDeflate,
+		LabelBits: 16,
+		FieldSize: uint16(b.fieldSize),
+	}
+}
+
 // Data returns the encoded and compressed data blob of the bucket
 func (b *LabelledBucket) Data() ([]byte, error) {
 	d, err := deflate.Deflate(bytes.NewReader(b.raw.Bytes()))
@@ -0,0 +1,71 @@
+package aggregators
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/proofxyz/solidify/go/storage"
+)
+
+// MerkleBundle is the calldata counterpart to GroupedBundle. Instead of
+// packing the fields of the groups into BucketStorages, only the Merkle root
+// over all fields is stored on-chain and callers supply the fields together
+// with their proofs in calldata (see storage.FieldsMerkleTree).
+type MerkleBundle[G FieldsGroup] struct {
+	name   string
+	groups []G
+	tree   *storage.FieldsMerkleTree
+	cfg    *storage.GeneratorConfig
+}
+
+// NewMerkleBundle computes the Merkle tree over the fields of the given
+// groups. The order of the groups determines the order of the types in the
+// generated verifier. A nil cfg uses the default conventions.
+func NewMerkleBundle[G FieldsGroup](name string, groups []G, cfg *storage.GeneratorConfig) (*MerkleBundle[G], error) {
+	b := &MerkleBundle[G]{
+		name:   name,
+		groups: make([]G, len(groups)),
+		cfg:    cfg,
+	}
+	copy(b.groups, groups)
+
+	fields := make([][]storage.Field, len(b.groups))
+	for i, g := range b.groups {
+		fields[i] = g.Fields()
+	}
+
+	t, err := storage.NewFieldsMerkleTree(b.groups, fields)
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewFieldsMerkleTree(…): %w", err)
+	}
+	b.tree = t
+
+	return b, nil
+}
+
+// Name returns the name of the bundle.
+func (b *MerkleBundle[G]) Name() string {
+	return b.name
+}
+
+// Groups returns the groups in the order of the generated type enum.
+func (b *MerkleBundle[G]) Groups() []G {
+	return b.groups
+}
+
+// Root returns the Merkle root over all fields.
+func (b *MerkleBundle[G]) Root() common.Hash {
+	return b.tree.Root()
+}
+
+// Proof returns the proof for the field with a given index in a given group.
+func (b *MerkleBundle[G]) Proof(group, index int) ([]common.Hash, error) {
+	return b.tree.Proof(group, index)
+}
+
+// WriteContracts writes the verifier library and the proofs of all fields to
+// a given output directory (see storage.WriteMerkleGroupStorage). Returns the
+// paths of the written contracts.
+func (b *MerkleBundle[G]) WriteContracts(outputDir string) ([]string, error) {
+	return storage.WriteMerkleGroupStorage(b.name, b.groups, b.tree, b.cfg, outputDir)
+}
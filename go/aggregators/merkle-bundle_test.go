@@ -0,0 +1,43 @@
+package aggregators
+
+import (
+	"testing"
+
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/types"
+)
+
+func TestMerkleBundle(t *testing.T) {
+	groups := []testGroup{
+		{name: "FOO", values: []types.StringField{"foo0", "foo1"}},
+		{name: "BAR", values: []types.StringField{"bar0", "bar1", "bar2"}},
+	}
+	cfg := &storage.GeneratorConfig{Output: new(storage.MemFS)}
+	b, err := NewMerkleBundle("Trait", groups, cfg)
+	if err != nil {
+		t.Fatalf("NewMerkleBundle(%q, …) error %v", "Trait", err)
+	}
+
+	for g, grp := range groups {
+		for i, v := range grp.values {
+			proof, err := b.Proof(g, i)
+			if err != nil {
+				t.Fatalf("%T.Proof(%d, %d) error %v", b, g, i, err)
+			}
+			d, _ := v.Encode()
+			if !storage.VerifyMerkleFieldProof(b.Root(), g, i, d, proof) {
+				t.Errorf("storage.VerifyMerkleFieldProof(%T.Root(), %d, %d, %q, %T.Proof(…)) got false; want true", b, g, i, v, b)
+			}
+		}
+	}
+
+	if _, err := b.WriteContracts("gen"); err != nil {
+		t.Fatalf("%T.WriteContracts() error %v", b, err)
+	}
+	files := cfg.Output.(*storage.MemFS).Files()
+	for _, p := range []string{"gen/TraitFieldVerifier.sol", "gen/TraitProofs.json"} {
+		if _, ok := files[p]; !ok {
+			t.Errorf("%T.WriteContracts() did not write %q", b, p)
+		}
+	}
+}
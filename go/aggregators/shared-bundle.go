@@ -0,0 +1,151 @@
+package aggregators
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/proofxyz/solidify/go/storage"
+)
+
+// A SharableBundle is a packed bundle whose buckets can be placed into
+// storages shared with other bundles, as implemented by GroupedBundle.
+type SharableBundle interface {
+	Name() string
+	FieldsGroups() []storage.FieldsGroup
+	Storages() []*BucketStorage
+	Locate(group, index int) (storage.FieldLocation, error)
+}
+
+// SharedBundleConfig controls how a SharedBundle groups buckets into
+// storages. Negative values disable the respective limit.
+type SharedBundleConfig struct {
+	// MaxStorageSize limits the total size of each BucketStorage (see also
+	// GroupIntoStorages).
+	MaxStorageSize int
+	// MaxBucketsPerStorage limits the number of buckets in each BucketStorage
+	// (see also GroupIntoStorages).
+	MaxBucketsPerStorage int
+	// Generator controls the conventions of the generated contracts. If nil,
+	// the defaults are used.
+	Generator *storage.GeneratorConfig
+}
+
+// SharedBundle packs the buckets of several logical bundles into shared
+// BucketStorages, such that small bundles don't each carry the overhead of a
+// separate storage contract. The buckets themselves are retained, hence so is
+// the lookup gas of each field.
+type SharedBundle struct {
+	name     string
+	cfg      SharedBundleConfig
+	stores   []*BucketStorage
+	mappings []storage.SharedMapping
+}
+
+// NewSharedBundle groups the buckets of the given bundles into storages in the
+// order of the bundles and their buckets, and relocates all fields
+// accordingly. The bundle names have to be unique.
+func NewSharedBundle(name string, bundles []SharableBundle, cfg SharedBundleConfig) (*SharedBundle, error) {
+	if len(bundles) == 0 {
+		return nil, errors.New("no bundles to share storages")
+	}
+
+	var (
+		buckets []storage.Bucket
+		// offsets holds the index of the first bucket of each of the bundle's
+		// storages in buckets.
+		offsets = make([][]int, len(bundles))
+		seen    = make(map[string]bool)
+	)
+	for i, b := range bundles {
+		if seen[b.Name()] {
+			return nil, fmt.Errorf("duplicate bundle %q", b.Name())
+		}
+		seen[b.Name()] = true
+
+		for _, s := range b.Storages() {
+			offsets[i] = append(offsets[i], len(buckets))
+			buckets = append(buckets, s.Buckets()...)
+		}
+	}
+
+	stores, err := GroupIntoStorages(buckets, cfg.MaxStorageSize, cfg.MaxBucketsPerStorage, name, cfg.Generator)
+	if err != nil {
+		return nil, fmt.Errorf("GroupIntoStorages([buckets], %d, %d, %q, [config]): %w", cfg.MaxStorageSize, cfg.MaxBucketsPerStorage, name, err)
+	}
+
+	// GroupIntoStorages retains the order of the buckets.
+	var bucketLocs []storage.FieldLocation
+	for s, st := range stores {
+		for i := range st.Buckets() {
+			bucketLocs = append(bucketLocs, storage.FieldLocation{StorageID: s, BucketID: i})
+		}
+	}
+
+	sb := &SharedBundle{
+		name:     name,
+		cfg:      cfg,
+		stores:   stores,
+		mappings: make([]storage.SharedMapping, len(bundles)),
+	}
+	for i, b := range bundles {
+		groups := b.FieldsGroups()
+		m := storage.SharedMapping{
+			Name:      b.Name(),
+			Groups:    groups,
+			Locations: make([][]storage.FieldLocation, len(groups)),
+		}
+		for g, grp := range groups {
+			m.Locations[g] = make([]storage.FieldLocation, grp.NumFields())
+			for j := range m.Locations[g] {
+				l, err := b.Locate(g, j)
+				if err != nil {
+					return nil, fmt.Errorf("%T(%q).Locate(%d, %d): %w", b, b.Name(), g, j, err)
+				}
+				if l.StorageID < 0 || l.StorageID >= len(offsets[i]) || l.BucketID < 0 || l.BucketID >= len(b.Storages()[l.StorageID].Buckets()) {
+					return nil, fmt.Errorf("bundle %q located field %d of group %q at %+v outside of its storages", b.Name(), j, grp.Name(), l)
+				}
+				shared := bucketLocs[offsets[i][l.StorageID]+l.BucketID]
+				shared.FieldID = l.FieldID
+				m.Locations[g][j] = shared
+			}
+		}
+		sb.mappings[i] = m
+	}
+
+	return sb, nil
+}
+
+// Name returns the name of the shared bundle, which determines the names of
+// the storages and their deployer.
+func (b *SharedBundle) Name() string {
+	return b.name
+}
+
+// Storages returns the shared BucketStorages.
+func (b *SharedBundle) Storages() []*BucketStorage {
+	return b.stores
+}
+
+// Locate returns the location in the shared storages of the field with a
+// given index in a given group of the bundle with a given index.
+func (b *SharedBundle) Locate(bundle, group, index int) (storage.FieldLocation, error) {
+	if bundle < 0 || bundle >= len(b.mappings) {
+		return storage.FieldLocation{}, fmt.Errorf("bundle %d out of range [0, %d)", bundle, len(b.mappings))
+	}
+	m := b.mappings[bundle]
+	if group < 0 || group >= len(m.Locations) {
+		return storage.FieldLocation{}, fmt.Errorf("group %d out of range [0, %d) for bundle %q", group, len(m.Locations), m.Name)
+	}
+	if index < 0 || index >= len(m.Locations[group]) {
+		return storage.FieldLocation{}, fmt.Errorf("index %d out of range [0, %d) for group %q of bundle %q", index, len(m.Locations[group]), m.Groups[group].Name(), m.Name)
+	}
+	return m.Locations[group][index], nil
+}
+
+// WriteContracts writes the shared storages and their deployer as well as the
+// storage mapping and manager of every bundle to a given output directory
+// (see storage.WriteSharedGroupStorage). Returns the paths of the written
+// files.
+func (b *SharedBundle) WriteContracts(outputDir string) ([]string, error) {
+	return storage.WriteSharedGroupStorage(b.name, b.mappings, b.stores, b.cfg.Generator, outputDir)
+}
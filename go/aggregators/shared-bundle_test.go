@@ -0,0 +1,108 @@
+package aggregators
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/types"
+)
+
+func TestSharedBundle(t *testing.T) {
+	layers, err := NewGroupedBundle("Layer", []testGroup{
+		{name: "FOO", values: []types.StringField{"foo0", "foo1"}},
+		{name: "BAR", values: []types.StringField{"bar0", "bar1", "bar2"}},
+	}, GroupedBundleConfig{MaxBucketSize: -1, MaxStorageSize: -1, MaxBucketsPerStorage: 1, BucketPerGroup: true})
+	if err != nil {
+		t.Fatalf("NewGroupedBundle(%q, …) error %v", "Layer", err)
+	}
+	traits, err := NewGroupedBundle("Trait", []testGroup{
+		{name: "QUX", values: []types.StringField{"qux0"}},
+	}, GroupedBundleConfig{MaxBucketSize: -1, MaxStorageSize: -1, MaxBucketsPerStorage: -1})
+	if err != nil {
+		t.Fatalf("NewGroupedBundle(%q, …) error %v", "Trait", err)
+	}
+	if got := len(layers.Storages()) + len(traits.Storages()); got != 3 {
+		t.Fatalf("separate bundles have %d storages; want 3", got)
+	}
+
+	cfg := SharedBundleConfig{
+		MaxStorageSize:       -1,
+		MaxBucketsPerStorage: -1,
+		Generator:            &storage.GeneratorConfig{Output: new(storage.MemFS)},
+	}
+	shared, err := NewSharedBundle("Shared", []SharableBundle{layers, traits}, cfg)
+	if err != nil {
+		t.Fatalf("NewSharedBundle(…) error %v", err)
+	}
+	if got := len(shared.Storages()); got != 1 {
+		t.Errorf("%T.Storages() got %d storages; want 1", shared, got)
+	}
+
+	// Every location must point to the same field as in the separate bundle.
+	for i, b := range []*GroupedBundle[testGroup]{layers, traits} {
+		for g, grp := range b.Groups() {
+			for j, v := range grp.values {
+				l, err := shared.Locate(i, g, j)
+				if err != nil {
+					t.Fatalf("%T.Locate(%d, %d, %d) error %v", shared, i, g, j, err)
+				}
+				bucket := shared.Storages()[l.StorageID].Buckets()[l.BucketID].(*IndexedBucket)
+				if got := bucket.Fields()[l.FieldID].(types.StringField); got != v {
+					t.Errorf("%T.Locate(%d, %d, %d) = %+v pointing to %q; want %q", shared, i, g, j, l, got, v)
+				}
+			}
+		}
+	}
+
+	if _, err := shared.WriteContracts("gen"); err != nil {
+		t.Fatalf("%T.WriteContracts() error %v", shared, err)
+	}
+	files := cfg.Generator.Output.(*storage.MemFS).Files()
+	for _, p := range []string{
+		"gen/SharedStorageDeployer.sol",
+		"gen/storage/SharedBucketStorage0.sol",
+		"gen/LayerStorageMapping.sol",
+		"gen/LayerStorageManager.sol",
+		"gen/TraitStorageMapping.sol",
+		"gen/TraitStorageManager.sol",
+	} {
+		if _, ok := files[p]; !ok {
+			t.Errorf("%T.WriteContracts() did not write %q", shared, p)
+		}
+	}
+	if _, ok := files["gen/storage/TraitBucketStorage0.sol"]; ok {
+		t.Errorf("%T.WriteContracts() wrote separate storage of bundle %q", shared, "Trait")
+	}
+}
+
+func TestSharedBundleErrors(t *testing.T) {
+	b, err := NewGroupedBundle("Test", coAccessGroups(), GroupedBundleConfig{MaxBucketSize: -1, MaxStorageSize: -1, MaxBucketsPerStorage: -1})
+	if err != nil {
+		t.Fatalf("NewGroupedBundle(…) error %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		bundles      []SharableBundle
+		wantContains string
+	}{
+		{
+			name:         "no bundles",
+			wantContains: "no bundles",
+		},
+		{
+			name:         "duplicate bundle",
+			bundles:      []SharableBundle{b, b},
+			wantContains: `duplicate bundle "Test"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSharedBundle("Shared", tt.bundles, SharedBundleConfig{MaxStorageSize: -1, MaxBucketsPerStorage: -1}); err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("NewSharedBundle(…) got err %v; want containing %q", err, tt.wantContains)
+			}
+		})
+	}
+}
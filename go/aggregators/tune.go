@@ -0,0 +1,370 @@
+package aggregators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/proofxyz/solidify/go/storage"
+)
+
+// A LookupGasModel estimates the gas of retrieving a single field from a bucket
+// with the given compressed and uncompressed sizes.
+type LookupGasModel func(compressedSize, uncompressedSize int) uint64
+
+// DefaultLookupGasModel is a coarse linear model of an external `getBucket()`
+// call followed by inflation via inflate-sol, which dominates the cost of
+// field extraction. Absolute numbers should be taken with a grain of salt and
+// can be calibrated against the reports of the gasprofile package; the model
+// is however sufficient to rank configurations.
+func DefaultLookupGasModel(compressedSize, uncompressedSize int) uint64 {
+	const (
+		// callGas covers the cold external call, dispatch and ABI decoding.
+		callGas = 5000
+		// inflateGasPerByte is the average gas of inflating a single byte.
+		inflateGasPerByte = 80
+	)
+	words := uint64(compressedSize+31) / 32
+	// Copying the bucket from code and returning it incurs memory expansion
+	// in both the storage and the caller.
+	memory := 2 * (3*words + words*words/512)
+	return callGas + 3*words + memory + inflateGasPerByte*uint64(uncompressedSize)
+}
+
+// DecryptGas estimates the additional gas of decrypting an encrypted bucket
+// with a given compressed size, including the nonce, via `BucketCipherLib` and
+// loading the revealed secret from storage.
+func DecryptGas(compressedSize int) uint64 {
+	const (
+		// secretGas covers the cold SLOAD of the revealed secret.
+		secretGas = 2100
+		// decryptGasPerWord covers hashing the keystream and XORing a word.
+		decryptGasPerWord = 70
+	)
+	words := uint64(compressedSize+31) / 32
+	return secretGas + decryptGasPerWord*words + 3*words + words*words/512
+}
+
+// TuningSpace defines the parameters swept by Tune. Empty fields fall back to
+// the defaults documented on each.
+type TuningSpace struct {
+	// Flavours are the bucket flavours to consider. Defaults to
+	// FlavourIndexed, plus FlavourLabelled if all fields implement
+	// storage.LabelledField and have the same encoded size.
+	Flavours []storage.BucketFlavour
+	// BucketSizes are the limits of the uncompressed size of each bucket.
+	// Defaults to 1000, 2000, 4000, 8000 and 16000.
+	BucketSizes []int
+	// Codecs are the bucket codecs to consider. Defaults to CodecDeflate.
+	// CodecDeflateEncrypted accounts for the nonce of every bucket and for
+	// decryption in the lookup gas (see DecryptGas).
+	Codecs []storage.Codec
+	// StorageSizes are the limits of the compressed size of each storage.
+	// Defaults to 8000, 12000, 18000 and 24000.
+	StorageSizes []int
+	// MaxBucketsPerStorage are the limits of the number of buckets in each
+	// storage, where negative values disable the limit. Defaults to -1.
+	MaxBucketsPerStorage []int
+	// Gas estimates the lookup gas. Defaults to DefaultLookupGasModel.
+	Gas LookupGasModel
+	// Target is the chain whose code size limits determine the feasibility of
+	// a combination. Defaults to storage.ProfileMainnet.
+	Target *storage.ChainProfile
+}
+
+// withDefaults returns a copy of the space with empty fields set to their
+// defaults.
+func (s TuningSpace) withDefaults(labelled bool) TuningSpace {
+	if len(s.Flavours) == 0 {
+		s.Flavours = []storage.BucketFlavour{storage.FlavourIndexed}
+		if labelled {
+			s.Flavours = append(s.Flavours, storage.FlavourLabelled)
+		}
+	}
+	if len(s.BucketSizes) == 0 {
+		s.BucketSizes = []int{1000, 2000, 4000, 8000, 16000}
+	}
+	if len(s.Codecs) == 0 {
+		s.Codecs = []storage.Codec{storage.CodecDeflate}
+	}
+	if len(s.StorageSizes) == 0 {
+		s.StorageSizes = []int{8000, 12000, 18000, 24000}
+	}
+	if len(s.MaxBucketsPerStorage) == 0 {
+		s.MaxBucketsPerStorage = []int{-1}
+	}
+	if s.Gas == nil {
+		s.Gas = DefaultLookupGasModel
+	}
+	if s.Target == nil {
+		s.Target = storage.ProfileMainnet
+	}
+	return s
+}
+
+// A Tuning is a combination of packing parameters.
+type Tuning struct {
+	Flavour              storage.BucketFlavour
+	Codec                storage.Codec
+	MaxBucketSize        int
+	MaxStorageSize       int
+	MaxBucketsPerStorage int
+}
+
+// TuningScore quantifies the costs of a Tuning, all of which are to be
+// minimised.
+type TuningScore struct {
+	// DeployedBytes is the total size of the storage contracts as assembled
+	// by storage.StorageBytecode.
+	DeployedBytes int
+	// Contracts is the number of storage contracts.
+	Contracts int
+	// WorstLookupGas is the estimated gas of looking up a field in the most
+	// expensive bucket.
+	WorstLookupGas uint64
+}
+
+// dominates returns whether s is at least as good as o in all objectives and
+// strictly better in at least one.
+func (s TuningScore) dominates(o TuningScore) bool {
+	le := s.DeployedBytes <= o.DeployedBytes && s.Contracts <= o.Contracts && s.WorstLookupGas <= o.WorstLookupGas
+	return le && s != o
+}
+
+// A TuningResult is a scored Tuning.
+type TuningResult struct {
+	Tuning
+	Score TuningScore
+}
+
+// TuningReport is the outcome of Tune.
+type TuningReport struct {
+	// Evaluated contains all feasible combinations in the order of the sweep.
+	Evaluated []TuningResult
+	// Pareto contains the results that are not dominated by any other one, in
+	// the order of the sweep.
+	Pareto []TuningResult
+	// Recommended is the Pareto optimal result with the smallest sum of
+	// objectives, each relative to its best value in the Pareto set.
+	Recommended TuningResult
+}
+
+// Tune packs the fields with every combination of parameters in space and
+// scores the resulting storages. Combinations resulting in a storage contract
+// exceeding the code size limits of the target chain are infeasible and
+// omitted from the report.
+//
+// The recommended MaxBucketSize, MaxStorageSize and MaxBucketsPerStorage can
+// be passed directly to GroupIntoIndexedBuckets (or GroupIntoLabelledBuckets)
+// and GroupIntoStorages.
+func Tune[F storage.Field](fields []F, space TuningSpace) (*TuningReport, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("no fields to tune")
+	}
+
+	labelled, lerr := asLabelledFields(fields)
+	space = space.withDefaults(lerr == nil)
+	cfg := &storage.GeneratorConfig{Target: space.Target}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := new(TuningReport)
+	for _, fl := range space.Flavours {
+		if fl == storage.FlavourLabelled && lerr != nil {
+			return nil, fmt.Errorf("labelled flavour: %w", lerr)
+		}
+		for _, codec := range space.Codecs {
+			for _, bucketSize := range space.BucketSizes {
+				buckets, err := groupBuckets(fields, labelled, fl, bucketSize)
+				if err != nil {
+					return nil, err
+				}
+				if buckets, err = withCodec(buckets, codec); err != nil {
+					return nil, err
+				}
+
+				for _, storageSize := range space.StorageSizes {
+					for _, maxBuckets := range space.MaxBucketsPerStorage {
+						t := Tuning{
+							Flavour:              fl,
+							Codec:                codec,
+							MaxBucketSize:        bucketSize,
+							MaxStorageSize:       storageSize,
+							MaxBucketsPerStorage: maxBuckets,
+						}
+						score, ok, err := scoreTuning(buckets, t, space.Gas, cfg)
+						if err != nil {
+							return nil, fmt.Errorf("scoring %+v: %w", t, err)
+						}
+						if ok {
+							r.Evaluated = append(r.Evaluated, TuningResult{Tuning: t, Score: *score})
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(r.Evaluated) == 0 {
+		return nil, fmt.Errorf("no feasible tuning; all combinations result in storages exceeding the code size limits of %s", space.Target.Name)
+	}
+
+	for i, a := range r.Evaluated {
+		dominated := false
+		for j, b := range r.Evaluated {
+			if i != j && b.Score.dominates(a.Score) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			r.Pareto = append(r.Pareto, a)
+		}
+	}
+	r.Recommended = recommend(r.Pareto)
+
+	return r, nil
+}
+
+// asLabelledFields converts the fields to storage.LabelledFields if they all
+// implement the interface and have the same encoded size.
+func asLabelledFields[F storage.Field](fields []F) ([]storage.LabelledField, error) {
+	var (
+		lfs  []storage.LabelledField
+		size = -1
+	)
+	for _, f := range fields {
+		lf, ok := storage.Field(f).(storage.LabelledField)
+		if !ok {
+			return nil, fmt.Errorf("field %T is not a storage.LabelledField", f)
+		}
+		d, err := f.Encode()
+		if err != nil {
+			return nil, fmt.Errorf("%T.Encode(): %w", f, err)
+		}
+		if size >= 0 && len(d) != size {
+			return nil, fmt.Errorf("fields of different sizes %d and %d", size, len(d))
+		}
+		size = len(d)
+		lfs = append(lfs, lf)
+	}
+	return lfs, nil
+}
+
+// groupBuckets packs the fields into buckets of a given flavour.
+func groupBuckets[F storage.Field](fields []F, labelled []storage.LabelledField, fl storage.BucketFlavour, maxBucketSize int) ([]storage.Bucket, error) {
+	var buckets []storage.Bucket
+	switch fl {
+	case storage.FlavourIndexed:
+		bs, err := GroupIntoIndexedBuckets(fields, maxBucketSize)
+		if err != nil {
+			return nil, fmt.Errorf("GroupIntoIndexedBuckets([fields], %d): %w", maxBucketSize, err)
+		}
+		for _, b := range bs {
+			buckets = append(buckets, b)
+		}
+	case storage.FlavourLabelled:
+		bs, err := GroupIntoLabelledBuckets(labelled, maxBucketSize)
+		if err != nil {
+			return nil, fmt.Errorf("GroupIntoLabelledBuckets([fields], %d): %w", maxBucketSize, err)
+		}
+		for _, b := range bs {
+			buckets = append(buckets, b)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported flavour %d", fl)
+	}
+	return buckets, nil
+}
+
+// withCodec converts buckets with CodecDeflate to a given codec. Encrypted
+// buckets use a fixed secret since the secret does not affect their sizes.
+func withCodec(buckets []storage.Bucket, codec storage.Codec) ([]storage.Bucket, error) {
+	switch codec {
+	case storage.CodecDeflate:
+		return buckets, nil
+	case storage.CodecDeflateEncrypted:
+		enc := make([]storage.Bucket, len(buckets))
+		for i, b := range buckets {
+			enc[i] = storage.EncryptBucket(b, storage.RevealSecret{})
+		}
+		return enc, nil
+	default:
+		return nil, fmt.Errorf("unsupported codec %d", codec)
+	}
+}
+
+// scoreTuning groups the buckets into storages and scores the result. It
+// returns false if the tuning is infeasible.
+func scoreTuning(buckets []storage.Bucket, t Tuning, gas LookupGasModel, cfg *storage.GeneratorConfig) (*TuningScore, bool, error) {
+	stores, err := GroupIntoStorages(buckets, t.MaxStorageSize, t.MaxBucketsPerStorage, "Tune", cfg)
+	if errors.Is(err, storage.ErrCodeSizeExceeded) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	s := &TuningScore{Contracts: len(stores)}
+	for _, st := range stores {
+		code, err := storage.StorageBytecode(st)
+		if err != nil {
+			return nil, false, err
+		}
+		s.DeployedBytes += len(code.Deployed)
+
+		for _, b := range st.Buckets() {
+			d, err := b.Data()
+			if err != nil {
+				return nil, false, fmt.Errorf("%T.Data(): %w", b, err)
+			}
+			g := gas(len(d), b.UncompressedSize())
+			if t.Codec == storage.CodecDeflateEncrypted {
+				g += DecryptGas(len(d))
+			}
+			if g > s.WorstLookupGas {
+				s.WorstLookupGas = g
+			}
+		}
+	}
+	return s, true, nil
+}
+
+// recommend returns the result with the smallest sum of relative objectives.
+func recommend(rs []TuningResult) TuningResult {
+	best := TuningScore{DeployedBytes: math.MaxInt, Contracts: math.MaxInt, WorstLookupGas: math.MaxUint64}
+	for _, r := range rs {
+		s := r.Score
+		if s.DeployedBytes < best.DeployedBytes {
+			best.DeployedBytes = s.DeployedBytes
+		}
+		if s.Contracts < best.Contracts {
+			best.Contracts = s.Contracts
+		}
+		if s.WorstLookupGas < best.WorstLookupGas {
+			best.WorstLookupGas = s.WorstLookupGas
+		}
+	}
+
+	ratio := func(v, min float64) float64 {
+		if min == 0 {
+			return 1
+		}
+		return v / min
+	}
+
+	var (
+		rec  TuningResult
+		cost = math.Inf(1)
+	)
+	for _, r := range rs {
+		s := r.Score
+		c := ratio(float64(s.DeployedBytes), float64(best.DeployedBytes)) +
+			ratio(float64(s.Contracts), float64(best.Contracts)) +
+			ratio(float64(s.WorstLookupGas), float64(best.WorstLookupGas))
+		if c < cost {
+			rec, cost = r, c
+		}
+	}
+	return rec
+}
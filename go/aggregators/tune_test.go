@@ -0,0 +1,179 @@
+package aggregators
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/types"
+)
+
+type testLabelledField struct {
+	label uint16
+	data  []byte
+}
+
+func (f testLabelledField) Encode() ([]byte, error) { return f.data, nil }
+func (f testLabelledField) Label() uint16           { return f.label }
+
+// testFields returns fields of random, and therefore incompressible, data.
+func testFields(n, size int) []types.StringField {
+	rng := rand.New(rand.NewSource(42))
+	fs := make([]types.StringField, n)
+	for i := range fs {
+		b := make([]byte, size)
+		rng.Read(b)
+		fs[i] = types.StringField(b)
+	}
+	return fs
+}
+
+func TestTune(t *testing.T) {
+	fields := testFields(60, 200)
+	space := TuningSpace{
+		BucketSizes:  []int{500, 2000, 8000},
+		StorageSizes: []int{4000, 20000},
+	}
+
+	r, err := Tune(fields, space)
+	if err != nil {
+		t.Fatalf("Tune(…) error %v", err)
+	}
+
+	var got []Tuning
+	for _, e := range r.Evaluated {
+		got = append(got, e.Tuning)
+	}
+	var want []Tuning
+	for _, b := range space.BucketSizes {
+		for _, s := range space.StorageSizes {
+			want = append(want, Tuning{Flavour: storage.FlavourIndexed, Codec: storage.CodecDeflate, MaxBucketSize: b, MaxStorageSize: s, MaxBucketsPerStorage: -1})
+		}
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Tune(…) evaluated tunings diff (-want +got):\n%s", diff)
+	}
+
+	score := func(bucketSize, storageSize int) TuningScore {
+		for _, e := range r.Evaluated {
+			if e.MaxBucketSize == bucketSize && e.MaxStorageSize == storageSize {
+				return e.Score
+			}
+		}
+		t.Fatalf("no result for bucket size %d and storage size %d", bucketSize, storageSize)
+		return TuningScore{}
+	}
+	if small, large := score(500, 20000), score(8000, 20000); small.WorstLookupGas >= large.WorstLookupGas {
+		t.Errorf("worst lookup gas with small buckets %d not less than with large ones %d", small.WorstLookupGas, large.WorstLookupGas)
+	}
+	if small, large := score(2000, 4000), score(2000, 20000); small.Contracts <= large.Contracts {
+		t.Errorf("number of contracts with small storages %d not greater than with large ones %d", small.Contracts, large.Contracts)
+	}
+
+	for _, p := range r.Pareto {
+		for _, e := range r.Evaluated {
+			if e.Score.dominates(p.Score) {
+				t.Errorf("Pareto result %+v is dominated by %+v", p, e)
+			}
+		}
+	}
+	found := false
+	for _, p := range r.Pareto {
+		found = found || p == r.Recommended
+	}
+	if !found {
+		t.Errorf("Recommended %+v is not in the Pareto set", r.Recommended)
+	}
+}
+
+func TestTuneLabelled(t *testing.T) {
+	var fields []testLabelledField
+	for i, f := range testFields(50, 20) {
+		fields = append(fields, testLabelledField{label: uint16(i), data: []byte(f)})
+	}
+
+	r, err := Tune(fields, TuningSpace{BucketSizes: []int{300}, StorageSizes: []int{20000}})
+	if err != nil {
+		t.Fatalf("Tune(…) error %v", err)
+	}
+
+	var got []storage.BucketFlavour
+	for _, e := range r.Evaluated {
+		got = append(got, e.Flavour)
+	}
+	if diff := cmp.Diff([]storage.BucketFlavour{storage.FlavourIndexed, storage.FlavourLabelled}, got); diff != "" {
+		t.Errorf("Tune([labelled fields]) flavours diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestTuneEncrypted(t *testing.T) {
+	fields := testFields(30, 100)
+	r, err := Tune(fields, TuningSpace{
+		BucketSizes:  []int{1000},
+		StorageSizes: []int{20000},
+		Codecs:       []storage.Codec{storage.CodecDeflate, storage.CodecDeflateEncrypted},
+	})
+	if err != nil {
+		t.Fatalf("Tune(…) error %v", err)
+	}
+	if got, want := len(r.Evaluated), 2; got != want {
+		t.Fatalf("Tune(…) got %d evaluated tunings; want %d", got, want)
+	}
+
+	plain, enc := r.Evaluated[0], r.Evaluated[1]
+	if plain.Codec != storage.CodecDeflate || enc.Codec != storage.CodecDeflateEncrypted {
+		t.Fatalf("Tune(…) got codecs %d and %d; want %d and %d", plain.Codec, enc.Codec, storage.CodecDeflate, storage.CodecDeflateEncrypted)
+	}
+	if enc.Score.DeployedBytes <= plain.Score.DeployedBytes {
+		t.Errorf("Tune(…) encrypted deployed bytes %d not greater than unencrypted %d", enc.Score.DeployedBytes, plain.Score.DeployedBytes)
+	}
+	if enc.Score.WorstLookupGas <= plain.Score.WorstLookupGas {
+		t.Errorf("Tune(…) encrypted worst lookup gas %d not greater than unencrypted %d", enc.Score.WorstLookupGas, plain.Score.WorstLookupGas)
+	}
+	// Encryption is only ever more expensive.
+	if r.Recommended.Codec != storage.CodecDeflate {
+		t.Errorf("Tune(…) recommended %+v; want unencrypted", r.Recommended)
+	}
+}
+
+func TestTuneErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		fields       []types.StringField
+		space        TuningSpace
+		wantContains string
+	}{
+		{
+			name:         "No fields",
+			wantContains: "no fields",
+		},
+		{
+			name:         "Unlabelled fields",
+			fields:       testFields(2, 10),
+			space:        TuningSpace{Flavours: []storage.BucketFlavour{storage.FlavourLabelled}},
+			wantContains: "is not a storage.LabelledField",
+		},
+		{
+			name:         "Unsupported codec",
+			fields:       testFields(2, 10),
+			space:        TuningSpace{Codecs: []storage.Codec{42}},
+			wantContains: "unsupported codec 42",
+		},
+		{
+			name:         "Infeasible",
+			fields:       testFields(1, 30000),
+			wantContains: "no feasible tuning",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Tune(tt.fields, tt.space)
+			if err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("Tune(…) got err %v; want containing %q", err, tt.wantContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package evmasm provides a minimal assembler for EVM bytecode with support
+// for labels and embedded data.
+package evmasm
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// labelBytes is the width of pushed label offsets, limiting programs to 64KiB.
+const labelBytes = 2
+
+// A Program is a sequence of EVM instructions and data. Labels can be
+// referenced before they are defined. The zero value is an empty program.
+type Program struct {
+	code   []byte
+	labels map[string]int
+	refs   []ref
+	err    error
+}
+
+// A ref is a pushed label offset that is resolved during assembly.
+type ref struct {
+	pos   int
+	label string
+}
+
+// Op appends opcodes without immediate arguments.
+func (p *Program) Op(ops ...vm.OpCode) {
+	for _, op := range ops {
+		if op.IsPush() {
+			p.fail(fmt.Errorf("%v requires an immediate argument; use Push()", op))
+			return
+		}
+		p.code = append(p.code, byte(op))
+	}
+}
+
+// Push appends the shortest PUSH instruction for a given value, using at least
+// PUSH1 to remain compatible with chains that don't support PUSH0.
+func (p *Program) Push(v uint64) {
+	p.PushBig(new(big.Int).SetUint64(v))
+}
+
+// PushBig is equivalent to Push for values of up to 256 bits.
+func (p *Program) PushBig(v *big.Int) {
+	if v.Sign() < 0 || v.BitLen() > 256 {
+		p.fail(fmt.Errorf("value %v out of range for PUSH", v))
+		return
+	}
+	b := v.Bytes()
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	p.pushBytes(b)
+}
+
+// PushBytes appends a PUSH instruction with a given immediate argument of 1 to
+// 32 bytes, retaining leading zeros.
+func (p *Program) PushBytes(b []byte) {
+	if len(b) == 0 || len(b) > 32 {
+		p.fail(fmt.Errorf("invalid PUSH width %d", len(b)))
+		return
+	}
+	p.pushBytes(b)
+}
+
+func (p *Program) pushBytes(b []byte) {
+	p.code = append(p.code, byte(vm.PUSH1)+byte(len(b)-1))
+	p.code = append(p.code, b...)
+}
+
+// PushLabel appends a PUSH instruction for the offset of a label, which may be
+// defined later.
+func (p *Program) PushLabel(label string) {
+	p.code = append(p.code, byte(vm.PUSH1)+labelBytes-1)
+	p.refs = append(p.refs, ref{pos: len(p.code), label: label})
+	p.code = append(p.code, make([]byte, labelBytes)...)
+}
+
+// Jump appends an unconditional jump to a label.
+func (p *Program) Jump(label string) {
+	p.PushLabel(label)
+	p.Op(vm.JUMP)
+}
+
+// JumpIf appends a jump to a label that is taken if the top of the stack is
+// non-zero.
+func (p *Program) JumpIf(label string) {
+	p.PushLabel(label)
+	p.Op(vm.JUMPI)
+}
+
+// Jumpdest defines a label at the current offset, followed by a JUMPDEST.
+func (p *Program) Jumpdest(label string) {
+	p.Label(label)
+	p.Op(vm.JUMPDEST)
+}
+
+// Label defines a label at the current offset, e.g. to reference data.
+func (p *Program) Label(label string) {
+	if p.labels == nil {
+		p.labels = make(map[string]int)
+	}
+	if _, ok := p.labels[label]; ok {
+		p.fail(fmt.Errorf("duplicate label %q", label))
+		return
+	}
+	p.labels[label] = len(p.code)
+}
+
+// Data appends raw data, which must not be reached by execution.
+func (p *Program) Data(b []byte) {
+	p.code = append(p.code, b...)
+}
+
+// Len returns the current length of the program in bytes.
+func (p *Program) Len() int {
+	return len(p.code)
+}
+
+func (p *Program) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// Assemble resolves all labels and returns the bytecode. It returns the first
+// error encountered while building the program, if any.
+func (p *Program) Assemble() ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	code := append([]byte(nil), p.code...)
+	for _, r := range p.refs {
+		off, ok := p.labels[r.label]
+		if !ok {
+			return nil, fmt.Errorf("undefined label %q", r.label)
+		}
+		if off >= 1<<(8*labelBytes) {
+			return nil, fmt.Errorf("offset %d of label %q exceeds %d bytes", off, r.label, labelBytes)
+		}
+		for i := 0; i < labelBytes; i++ {
+			code[r.pos+i] = byte(off >> (8 * (labelBytes - 1 - i)))
+		}
+	}
+	return code, nil
+}
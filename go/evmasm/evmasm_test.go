@@ -0,0 +1,107 @@
+package evmasm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(*Program)
+		want  string
+	}{
+		{
+			name: "Shortest push",
+			build: func(p *Program) {
+				p.Push(0)
+				p.Push(0xff)
+				p.Push(0x100)
+				p.PushBytes([]byte{0, 1})
+			},
+			want: "6000" + "60ff" + "610100" + "610001",
+		},
+		{
+			name: "Forward and backward labels",
+			build: func(p *Program) {
+				p.Jump("end")
+				p.Jumpdest("start")
+				p.PushLabel("data")
+				p.Jumpdest("end")
+				p.Jump("start")
+				p.Label("data")
+				p.Data([]byte{0xaa})
+			},
+			want: "610008" + "56" + "5b" + "61000d" + "5b" + "610004" + "56" + "aa",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Program
+			tt.build(&p)
+			got, err := p.Assemble()
+			if err != nil {
+				t.Fatalf("Assemble() error %v", err)
+			}
+			if diff := cmp.Diff(tt.want, common.Bytes2Hex(got)); diff != "" {
+				t.Errorf("Assemble() diff (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		build        func(*Program)
+		wantContains string
+	}{
+		{
+			name:         "Undefined label",
+			build:        func(p *Program) { p.Jump("nowhere") },
+			wantContains: `undefined label "nowhere"`,
+		},
+		{
+			name: "Duplicate label",
+			build: func(p *Program) {
+				p.Label("x")
+				p.Label("x")
+			},
+			wantContains: `duplicate label "x"`,
+		},
+		{
+			name:         "Push without argument",
+			build:        func(p *Program) { p.Op(vm.PUSH1) },
+			wantContains: "requires an immediate argument",
+		},
+		{
+			name:         "Push too wide",
+			build:        func(p *Program) { p.PushBytes(make([]byte, 33)) },
+			wantContains: "invalid PUSH width 33",
+		},
+		{
+			name: "Label out of range",
+			build: func(p *Program) {
+				p.PushLabel("far")
+				p.Data(make([]byte, 1<<16))
+				p.Label("far")
+			},
+			wantContains: "exceeds 2 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Program
+			tt.build(&p)
+			if _, err := p.Assemble(); err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("Assemble() got err %v; want containing %q", err, tt.wantContains)
+			}
+		})
+	}
+}
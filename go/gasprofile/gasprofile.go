@@ -0,0 +1,337 @@
+// Package gasprofile measures the gas spent on looking up fields in storage
+// contracts, executed in an in-process EVM, to guide the choice of bucket and
+// storage sizes.
+package gasprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/verify"
+)
+
+// ProbeABI is the ABI of `test/gasprofile/BucketGasProbe.sol`.
+const ProbeABI = `[
+{"type":"function","name":"profileInflate","stateMutability":"view","inputs":[{"name":"store","type":"address"},{"name":"bucketId","type":"uint256"}],"outputs":[{"name":"getBucketGas","type":"uint256"},{"name":"inflateGas","type":"uint256"},{"name":"length","type":"uint256"}]},
+{"type":"function","name":"profileIndexed","stateMutability":"view","inputs":[{"name":"store","type":"address"},{"name":"bucketId","type":"uint256"},{"name":"fieldId","type":"uint256"}],"outputs":[{"name":"getBucketGas","type":"uint256"},{"name":"inflateGas","type":"uint256"},{"name":"extractGas","type":"uint256"},{"name":"length","type":"uint256"}]},
+{"type":"function","name":"profileLabelled","stateMutability":"view","inputs":[{"name":"store","type":"address"},{"name":"bucketId","type":"uint256"},{"name":"label","type":"uint16"},{"name":"fieldLength","type":"uint256"}],"outputs":[{"name":"getBucketGas","type":"uint256"},{"name":"inflateGas","type":"uint256"},{"name":"extractGas","type":"uint256"},{"name":"length","type":"uint256"}]}
+]`
+
+var (
+	probeABI   = mustParseABI(ProbeABI)
+	storageABI = mustParseABI(storage.BucketStorageABI)
+)
+
+func mustParseABI(s string) abi.ABI {
+	a, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// FoundryProbe returns the creation code of `test/gasprofile/BucketGasProbe.sol`
+// as compiled by forge to `<outDir>/BucketGasProbe.sol/BucketGasProbe.json`.
+func FoundryProbe(fsys fs.FS, outDir string) ([]byte, error) {
+	return verify.ReadArtifact(fsys, path.Join(outDir, "BucketGasProbe.sol", "BucketGasProbe.json"))
+}
+
+// DefaultWorstOffenders is the default of Config.WorstOffenders.
+const DefaultWorstOffenders = 5
+
+// Config configures Profile. The zero value is valid.
+type Config struct {
+	// Probe is the creation code of the BucketGasProbe contract (see
+	// FoundryProbe). If nil, only `getBucket()` is measured since inflating
+	// and extracting fields requires the compiled solidify libraries. The same
+	// applies to encrypted buckets regardless of the probe.
+	Probe []byte
+	// WorstOffenders is the number of most expensive lookups listed per
+	// storage. Defaults to DefaultWorstOffenders if not positive.
+	WorstOffenders int
+}
+
+// A Lookup is the gas spent on retrieving a single field.
+type Lookup struct {
+	Storage string `json:"storage"`
+	Bucket  int    `json:"bucket"`
+	Field   int    `json:"field"`
+	// GetBucket is the gas of calling `getBucket()`, including the external
+	// call if measured via the probe.
+	GetBucket uint64 `json:"getBucket"`
+	// Inflate and Extract are only measured via the probe, and Extract only
+	// for indexed and labelled buckets.
+	Inflate uint64 `json:"inflate"`
+	Extract uint64 `json:"extract"`
+}
+
+// Total returns the total gas of the lookup.
+func (l Lookup) Total() uint64 {
+	return l.GetBucket + l.Inflate + l.Extract
+}
+
+// A BucketProfile describes a bucket and the gas of loading it.
+type BucketProfile struct {
+	Bucket           int    `json:"bucket"`
+	NumFields        int    `json:"numFields"`
+	CompressedSize   int    `json:"compressedSize"`
+	UncompressedSize int    `json:"uncompressedSize"`
+	GetBucket        uint64 `json:"getBucket"`
+	Inflate          uint64 `json:"inflate"`
+}
+
+// A StorageProfile summarises the lookups of all fields in a storage.
+type StorageProfile struct {
+	Storage   string `json:"storage"`
+	NumFields int    `json:"numFields"`
+	// Mean and Max are taken over the total gas of all lookups.
+	Mean    uint64          `json:"mean"`
+	Max     uint64          `json:"max"`
+	Buckets []BucketProfile `json:"buckets"`
+	// Worst are the most expensive lookups in decreasing order of total gas.
+	Worst []Lookup `json:"worst"`
+}
+
+// A Report is the gas profile of a bundle of storages.
+type Report struct {
+	// Probed indicates whether inflation and field extraction were measured.
+	Probed   bool             `json:"probed"`
+	Storages []StorageProfile `json:"storages"`
+	// Lookups contains the lookups of all fields in storage order.
+	Lookups []Lookup `json:"lookups"`
+}
+
+// Profile deploys the contract of each storage, as returned by code, into an
+// in-process EVM and measures the lookup of every field in every bucket. See
+// Config.Probe for which steps are measured.
+func Profile[S storage.BucketStorage](stores []S, code verify.CodeSource, cfg Config) (*Report, error) {
+	if cfg.WorstOffenders <= 0 {
+		cfg.WorstOffenders = DefaultWorstOffenders
+	}
+
+	rcfg := new(runtime.Config)
+	r := &Report{Probed: cfg.Probe != nil}
+
+	var probe common.Address
+	if cfg.Probe != nil {
+		var err error
+		if _, probe, _, err = runtime.Create(cfg.Probe, rcfg); err != nil {
+			return nil, fmt.Errorf("deploying probe: %w", err)
+		}
+	}
+
+	for _, s := range stores {
+		creation, err := code(s)
+		if err != nil {
+			return nil, fmt.Errorf("loading code of %q: %w", s.Name(), err)
+		}
+		_, addr, _, err := runtime.Create(creation, rcfg)
+		if err != nil {
+			return nil, fmt.Errorf("deploying %q: %w", s.Name(), err)
+		}
+
+		p := profiler{cfg: rcfg, store: addr, probe: probe, probed: r.Probed}
+		sp := StorageProfile{Storage: s.Name(), NumFields: s.NumFields()}
+		var lookups []Lookup
+		for i, b := range s.Buckets() {
+			bp, ls, err := p.bucket(i, b)
+			if err != nil {
+				return nil, fmt.Errorf("profiling bucket %d of %q: %w", i, s.Name(), err)
+			}
+			for j := range ls {
+				ls[j].Storage = s.Name()
+			}
+			sp.Buckets = append(sp.Buckets, *bp)
+			lookups = append(lookups, ls...)
+		}
+
+		summarise(&sp, lookups, cfg.WorstOffenders)
+		r.Storages = append(r.Storages, sp)
+		r.Lookups = append(r.Lookups, lookups...)
+	}
+
+	return r, nil
+}
+
+// summarise computes the statistics of a storage's lookups.
+func summarise(sp *StorageProfile, lookups []Lookup, worst int) {
+	if len(lookups) == 0 {
+		return
+	}
+
+	var sum uint64
+	for _, l := range lookups {
+		sum += l.Total()
+		if t := l.Total(); t > sp.Max {
+			sp.Max = t
+		}
+	}
+	sp.Mean = sum / uint64(len(lookups))
+
+	sorted := append([]Lookup(nil), lookups...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Total() > sorted[j].Total()
+	})
+	if len(sorted) > worst {
+		sorted = sorted[:worst]
+	}
+	sp.Worst = sorted
+}
+
+// A profiler measures lookups in a single deployed storage.
+type profiler struct {
+	cfg          *runtime.Config
+	store, probe common.Address
+	probed       bool
+}
+
+// call executes a method and returns the unpacked outputs and the execution
+// gas.
+func (p *profiler) call(a abi.ABI, to common.Address, method string, args ...any) ([]any, uint64, error) {
+	in, err := a.Pack(method, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%T.Pack(%q, …): %w", a, method, err)
+	}
+	ret, left, err := runtime.Call(to, in, p.cfg)
+	if err != nil {
+		return nil, 0, fmt.Errorf("calling %s(): %w", method, err)
+	}
+	out, err := a.Unpack(method, ret)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%T.Unpack(%q, …): %w", a, method, err)
+	}
+	return out, p.cfg.GasLimit - left, nil
+}
+
+// bucket profiles a bucket and the lookups of all of its fields.
+func (p *profiler) bucket(idx int, b storage.Bucket) (*BucketProfile, []Lookup, error) {
+	data, err := b.Data()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%T.Data(): %w", b, err)
+	}
+	bp := &BucketProfile{
+		Bucket:           idx,
+		NumFields:        b.NumFields(),
+		CompressedSize:   len(data),
+		UncompressedSize: b.UncompressedSize(),
+	}
+	id := big.NewInt(int64(idx))
+	format := storage.BucketFormatOf(b)
+
+	// The probe can't inflate encrypted buckets without their secret, so only
+	// `getBucket()` is measured for them.
+	if !p.probed || format.Codec == storage.CodecDeflateEncrypted {
+		_, gas, err := p.call(storageABI, p.store, "getBucket", id)
+		if err != nil {
+			return nil, nil, err
+		}
+		bp.GetBucket = gas
+		ls := make([]Lookup, b.NumFields())
+		for j := range ls {
+			ls[j] = Lookup{Bucket: idx, Field: j, GetBucket: gas}
+		}
+		return bp, ls, nil
+	}
+
+	var labels []uint16
+	if lb, ok := b.(storage.LabelledBucket); ok {
+		labels = lb.Labels()
+	}
+
+	var ls []Lookup
+	for j := 0; j < b.NumFields(); j++ {
+		var (
+			out []any
+			err error
+		)
+		l := Lookup{Bucket: idx, Field: j}
+		// The outputs of all probe functions are the measured gas in the
+		// order below, followed by the length of the loaded data.
+		gas := []*uint64{&l.GetBucket, &l.Inflate, &l.Extract}
+		switch {
+		case format.Flavour == storage.FlavourIndexed:
+			out, _, err = p.call(probeABI, p.probe, "profileIndexed", p.store, id, big.NewInt(int64(j)))
+		case format.Flavour == storage.FlavourLabelled && len(labels) == b.NumFields():
+			out, _, err = p.call(probeABI, p.probe, "profileLabelled", p.store, id, labels[j], big.NewInt(int64(format.FieldSize)))
+		default:
+			out, _, err = p.call(probeABI, p.probe, "profileInflate", p.store, id)
+			gas = gas[:2]
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("field %d: %w", j, err)
+		}
+
+		for k, g := range gas {
+			*g = out[k].(*big.Int).Uint64()
+		}
+		ls = append(ls, l)
+	}
+
+	if len(ls) > 0 {
+		bp.GetBucket = ls[0].GetBucket
+		bp.Inflate = ls[0].Inflate
+	} else {
+		out, _, err := p.call(probeABI, p.probe, "profileInflate", p.store, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		bp.GetBucket = out[0].(*big.Int).Uint64()
+		bp.Inflate = out[1].(*big.Int).Uint64()
+	}
+	return bp, ls, nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes a human-readable summary of the report, listing the
+// statistics and worst offenders of each storage.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("# Gas profile\n\n")
+	if !r.Probed {
+		b.WriteString("Only `getBucket()` was measured; inflation and field extraction require the `BucketGasProbe` artifact.\n\n")
+	}
+	b.WriteString("| Storage | Buckets | Fields | Mean | Max |\n")
+	b.WriteString("| --- | ---: | ---: | ---: | ---: |\n")
+	for _, s := range r.Storages {
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %d |\n", s.Storage, len(s.Buckets), s.NumFields, s.Mean, s.Max)
+	}
+
+	for _, s := range r.Storages {
+		fmt.Fprintf(&b, "\n## %s\n\n", s.Storage)
+		b.WriteString("| Bucket | Fields | Compressed | Uncompressed | getBucket | inflate |\n")
+		b.WriteString("| ---: | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, bp := range s.Buckets {
+			fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d |\n", bp.Bucket, bp.NumFields, bp.CompressedSize, bp.UncompressedSize, bp.GetBucket, bp.Inflate)
+		}
+
+		if len(s.Worst) == 0 {
+			continue
+		}
+		b.WriteString("\nWorst offenders:\n\n")
+		b.WriteString("| Bucket | Field | getBucket | inflate | extract | Total |\n")
+		b.WriteString("| ---: | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, l := range s.Worst {
+			fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d |\n", l.Bucket, l.Field, l.GetBucket, l.Inflate, l.Extract, l.Total())
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
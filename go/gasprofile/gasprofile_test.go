@@ -0,0 +1,268 @@
+package gasprofile
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/google/go-cmp/cmp"
+	"github.com/proofxyz/solidify/go/aggregators"
+	"github.com/proofxyz/solidify/go/deflate"
+	"github.com/proofxyz/solidify/go/evmasm"
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/verify"
+)
+
+type fakeField string
+
+func (f fakeField) Encode() ([]byte, error) { return []byte(f), nil }
+
+type fakeLabelledField struct {
+	label uint16
+	data  string
+}
+
+func (f fakeLabelledField) Encode() ([]byte, error) { return []byte(f.data), nil }
+func (f fakeLabelledField) Label() uint16           { return f.label }
+
+// rawBucket is a bucket of custom flavour.
+type rawBucket struct {
+	data      []byte
+	numFields int
+}
+
+func (b rawBucket) Data() ([]byte, error) {
+	c, err := deflate.Deflate(bytes.NewReader(b.data))
+	if err != nil {
+		return nil, err
+	}
+	return c.Data, nil
+}
+func (b rawBucket) UncompressedSize() int { return len(b.data) }
+func (b rawBucket) NumFields() int        { return b.numFields }
+
+func testStorage(t *testing.T) *aggregators.BucketStorage {
+	t.Helper()
+
+	ib := new(aggregators.IndexedBucket)
+	// Random data doesn't compress, resulting in a large bucket.
+	random := make([]byte, 1000)
+	rand.New(rand.NewSource(42)).Read(random)
+
+	for _, f := range []string{"foo", string(random), "baz"} {
+		if err := ib.AddField(fakeField(f)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lb := new(aggregators.LabelledBucket)
+	for _, f := range []fakeLabelledField{{3, "aa"}, {9, "bb"}} {
+		if err := lb.AddField(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := aggregators.NewBucketStorage("TestBucketStorage0")
+	s.AddBucket(ib)
+	s.AddBucket(lb)
+	s.AddBucket(rawBucket{data: []byte("custom"), numFields: 1})
+	return s
+}
+
+func TestProfileDefaultWorstOffenders(t *testing.T) {
+	r, err := Profile([]*aggregators.BucketStorage{testStorage(t)}, verify.Prebuilt, Config{WorstOffenders: -1})
+	if err != nil {
+		t.Fatalf("Profile(…, [WorstOffenders -1]) error %v", err)
+	}
+	if got, want := len(r.Storages[0].Worst), DefaultWorstOffenders; got != want {
+		t.Errorf("Profile(…, [WorstOffenders -1]) got %d worst offenders; want %d", got, want)
+	}
+}
+
+func TestProfileWithoutProbe(t *testing.T) {
+	r, err := Profile([]*aggregators.BucketStorage{testStorage(t)}, verify.Prebuilt, Config{WorstOffenders: 2})
+	if err != nil {
+		t.Fatalf("Profile(…) error %v", err)
+	}
+	if r.Probed {
+		t.Errorf("Profile(…) without probe got Probed = true")
+	}
+	if got, want := len(r.Lookups), 6; got != want {
+		t.Fatalf("Profile(…) got %d lookups; want %d", got, want)
+	}
+
+	s := r.Storages[0]
+	for _, l := range r.Lookups {
+		if l.GetBucket == 0 || l.Inflate != 0 || l.Extract != 0 {
+			t.Errorf("Profile(…) without probe got lookup %+v; want only getBucket gas", l)
+		}
+		if l.GetBucket != s.Buckets[l.Bucket].GetBucket {
+			t.Errorf("Profile(…) lookup %+v differs from gas of bucket %d", l, l.Bucket)
+		}
+	}
+	// The indexed bucket holds the most data and is therefore most expensive
+	// to retrieve.
+	if s.Buckets[0].GetBucket <= s.Buckets[1].GetBucket {
+		t.Errorf("Profile(…) getBucket(0) gas %d not greater than getBucket(1) gas %d", s.Buckets[0].GetBucket, s.Buckets[1].GetBucket)
+	}
+	if s.Max != s.Buckets[0].GetBucket || s.Mean >= s.Max || s.Mean == 0 {
+		t.Errorf("Profile(…) got mean %d and max %d; want 0 < mean < max = %d", s.Mean, s.Max, s.Buckets[0].GetBucket)
+	}
+	if got, want := len(s.Worst), 2; got != want {
+		t.Errorf("Profile(…) got %d worst offenders; want %d", got, want)
+	}
+	for _, l := range s.Worst {
+		if l.Bucket != 0 {
+			t.Errorf("Profile(…) worst offender %+v not in bucket 0", l)
+		}
+	}
+}
+
+// fakeProbe returns the creation code of a contract that returns
+// (calldata[0x24:0x44] + 100, calldata[0x44:0x64] + 10, 1, 4) for any call,
+// i.e. the bucket ID and the field ID or label of the probe functions.
+func fakeProbe(t *testing.T) []byte {
+	t.Helper()
+
+	var rt evmasm.Program
+	for i, off := range []uint64{0x24, 0x44} {
+		rt.Push(off)
+		rt.Op(vm.CALLDATALOAD)
+		rt.Push([]uint64{100, 10}[i])
+		rt.Op(vm.ADD)
+		rt.Push(uint64(0x20 * i))
+		rt.Op(vm.MSTORE)
+	}
+	rt.Push(1)
+	rt.Push(0x40)
+	rt.Op(vm.MSTORE)
+	rt.Push(4)
+	rt.Push(0x60)
+	rt.Op(vm.MSTORE)
+	rt.Push(0x80)
+	rt.Push(0)
+	rt.Op(vm.RETURN)
+	runtimeCode, err := rt.Assemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p evmasm.Program
+	p.Push(uint64(len(runtimeCode)))
+	p.Op(vm.DUP1)
+	p.PushLabel("runtime")
+	p.Push(0)
+	p.Op(vm.CODECOPY)
+	p.Push(0)
+	p.Op(vm.RETURN)
+	p.Label("runtime")
+	p.Data(runtimeCode)
+	code, err := p.Assemble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestProfileWithProbe(t *testing.T) {
+	r, err := Profile([]*aggregators.BucketStorage{testStorage(t)}, verify.Prebuilt, Config{Probe: fakeProbe(t)})
+	if err != nil {
+		t.Fatalf("Profile(…) error %v", err)
+	}
+
+	want := []Lookup{
+		{Bucket: 0, Field: 0, GetBucket: 100, Inflate: 10, Extract: 1},
+		{Bucket: 0, Field: 1, GetBucket: 100, Inflate: 11, Extract: 1},
+		{Bucket: 0, Field: 2, GetBucket: 100, Inflate: 12, Extract: 1},
+		// Labelled buckets are probed by label.
+		{Bucket: 1, Field: 0, GetBucket: 101, Inflate: 13, Extract: 1},
+		{Bucket: 1, Field: 1, GetBucket: 101, Inflate: 19, Extract: 1},
+		// Custom buckets are only inflated; the third word is the length.
+		{Bucket: 2, Field: 0, GetBucket: 102, Inflate: 10},
+	}
+	for i := range want {
+		want[i].Storage = "TestBucketStorage0"
+	}
+	if diff := cmp.Diff(want, r.Lookups); diff != "" {
+		t.Errorf("Profile(…) lookups diff (-want +got):\n%s", diff)
+	}
+
+	s := r.Storages[0]
+	if got, want := s.Max, uint64(121); got != want {
+		t.Errorf("Profile(…) got max %d; want %d", got, want)
+	}
+	if diff := cmp.Diff(want[4], s.Worst[0]); diff != "" {
+		t.Errorf("Profile(…) worst offender diff (-want +got):\n%s", diff)
+	}
+
+	var md bytes.Buffer
+	if err := r.WriteMarkdown(&md); err != nil {
+		t.Fatalf("%T.WriteMarkdown() error %v", r, err)
+	}
+	for _, want := range []string{
+		"| TestBucketStorage0 | 3 | 6 | 114 | 121 |",
+		"## TestBucketStorage0",
+		"| 1 | 1 | 101 | 19 | 1 | 121 |",
+	} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("%T.WriteMarkdown() does not contain %q; got\n%s", r, want, md.String())
+		}
+	}
+
+	var js bytes.Buffer
+	if err := r.WriteJSON(&js); err != nil {
+		t.Fatalf("%T.WriteJSON() error %v", r, err)
+	}
+	if want := `"probed": true`; !strings.Contains(js.String(), want) {
+		t.Errorf("%T.WriteJSON() does not contain %q", r, want)
+	}
+}
+
+func TestProfileEncrypted(t *testing.T) {
+	plain := testStorage(t)
+	enc := aggregators.NewBucketStorage(plain.Name())
+	for _, b := range plain.Buckets() {
+		enc.AddBucket(storage.EncryptBucket(b, storage.RevealSecret{}))
+	}
+	stores := []*aggregators.BucketStorage{enc}
+
+	want, err := Profile(stores, verify.Prebuilt, Config{})
+	if err != nil {
+		t.Fatalf("Profile(…) without probe error %v", err)
+	}
+	got, err := Profile(stores, verify.Prebuilt, Config{Probe: fakeProbe(t)})
+	if err != nil {
+		t.Fatalf("Profile(…) with probe error %v", err)
+	}
+
+	// Encrypted buckets are never passed to the probe.
+	if diff := cmp.Diff(want.Lookups, got.Lookups); diff != "" {
+		t.Errorf("Profile(…) with probe lookups diff (-without +with):\n%s", diff)
+	}
+	for _, l := range got.Lookups {
+		if l.GetBucket == 0 || l.Inflate != 0 || l.Extract != 0 {
+			t.Errorf("Profile(…) got lookup %+v of encrypted bucket; want only getBucket gas", l)
+		}
+	}
+}
+
+func TestFoundryProbe(t *testing.T) {
+	code := fakeProbe(t)
+	fsys := fstest.MapFS{
+		"out/BucketGasProbe.sol/BucketGasProbe.json": {
+			Data: []byte(fmt.Sprintf(`{"bytecode": {"object": "%s"}}`, hexutil.Encode(code))),
+		},
+	}
+
+	got, err := FoundryProbe(fsys, "out")
+	if err != nil {
+		t.Fatalf("FoundryProbe(…) error %v", err)
+	}
+	if !bytes.Equal(got, code) {
+		t.Errorf("FoundryProbe(…) got %#x; want %#x", got, code)
+	}
+}
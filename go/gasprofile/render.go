@@ -0,0 +1,242 @@
+package gasprofile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/proofxyz/solidify/go/aggregators"
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/types"
+)
+
+// DefaultRenderGasLimit is the default of RenderConfig.GasLimit, which equals
+// the default gas cap of `eth_call` in geth (`--rpc.gascap`). Many RPC
+// providers impose lower limits.
+const DefaultRenderGasLimit = 50_000_000
+
+// DefaultWorstTokens is the default of RenderConfig.WorstTokens.
+const DefaultWorstTokens = 10
+
+// A RenderedField is a field retrieved to render a token.
+type RenderedField struct {
+	// Bundle is the name of the bundle containing the field, matching that of
+	// a storage.BundleManifest.
+	Bundle   string
+	Location storage.FieldLocation
+}
+
+// A RenderLocator returns the fields retrieved to render a token, e.g. by
+// mapping its features to groups and resolving them with
+// aggregators.GroupedBundle.Locate.
+type RenderLocator func(types.Token) ([]RenderedField, error)
+
+// RenderConfig configures AnalyzeRenders. The zero value is valid.
+type RenderConfig struct {
+	// Gas estimates the gas of retrieving a field from a bucket. Defaults to
+	// aggregators.DefaultLookupGasModel, which can be calibrated against the
+	// measurements of Profile.
+	Gas aggregators.LookupGasModel
+	// BaseGas is the gas of rendering a token that does not depend on the
+	// retrieved fields, e.g. composing layers and encoding the output.
+	BaseGas uint64
+	// ReuseBuckets indicates that the renderer retrieves and inflates each
+	// bucket at most once per token. Otherwise, every field is assumed to be
+	// loaded separately, as is the case for storage managers.
+	ReuseBuckets bool
+	// GasLimit is the limit above which renders are flagged. Defaults to
+	// DefaultRenderGasLimit.
+	GasLimit uint64
+	// WorstTokens is the number of most expensive tokens listed in the report.
+	// Defaults to DefaultWorstTokens if not positive.
+	WorstTokens int
+}
+
+// A RenderBucket is a bucket retrieved to render a token.
+type RenderBucket struct {
+	Bundle           string `json:"bundle"`
+	Storage          string `json:"storage"`
+	StorageID        int    `json:"storageId"`
+	Bucket           int    `json:"bucket"`
+	CompressedSize   int    `json:"compressedSize"`
+	UncompressedSize int    `json:"uncompressedSize"`
+	// Lookups is the number of fields retrieved from the bucket.
+	Lookups int `json:"lookups"`
+	// Gas is the estimated gas of all lookups.
+	Gas uint64 `json:"gas"`
+}
+
+// A TokenRender is the estimated gas of rendering a token.
+type TokenRender struct {
+	TokenID uint16 `json:"tokenId"`
+	Gas     uint64 `json:"gas"`
+	// Buckets are the buckets retrieved for the token in decreasing order of
+	// gas, i.e. the ones driving the cost first.
+	Buckets []RenderBucket `json:"buckets"`
+}
+
+// A RenderReport summarises the estimated render gas of a set of tokens.
+type RenderReport struct {
+	NumTokens int `json:"numTokens"`
+	// Mean and Max are taken over the gas of all tokens.
+	Mean     uint64 `json:"mean"`
+	Max      uint64 `json:"max"`
+	GasLimit uint64 `json:"gasLimit"`
+	// OverLimit lists the IDs of the tokens exceeding the gas limit.
+	OverLimit []uint16 `json:"overLimit"`
+	// Worst are the most expensive tokens in decreasing order of gas.
+	Worst []TokenRender `json:"worst"`
+}
+
+// AnalyzeRenders estimates the gas of rendering each token from the sizes of
+// the buckets that it retrieves, as recorded in the manifests of the bundles
+// (see storage.NewBundleManifest), allowing tokens that exceed the gas limits
+// of `eth_call` to be found before deployment.
+func AnalyzeRenders(tokens []types.Token, locate RenderLocator, manifests []*storage.BundleManifest, cfg RenderConfig) (*RenderReport, error) {
+	if cfg.Gas == nil {
+		cfg.Gas = aggregators.DefaultLookupGasModel
+	}
+	if cfg.GasLimit == 0 {
+		cfg.GasLimit = DefaultRenderGasLimit
+	}
+	if cfg.WorstTokens <= 0 {
+		cfg.WorstTokens = DefaultWorstTokens
+	}
+
+	bundles := make(map[string]*storage.BundleManifest)
+	for _, m := range manifests {
+		if _, ok := bundles[m.Name]; ok {
+			return nil, fmt.Errorf("duplicate manifest of bundle %q", m.Name)
+		}
+		bundles[m.Name] = m
+	}
+
+	r := &RenderReport{
+		NumTokens: len(tokens),
+		GasLimit:  cfg.GasLimit,
+		OverLimit: []uint16{},
+	}
+	renders := make([]TokenRender, len(tokens))
+	var sum uint64
+	for i, t := range tokens {
+		fields, err := locate(t)
+		if err != nil {
+			return nil, fmt.Errorf("locating fields of token %d: %w", t.TokenID, err)
+		}
+		tr, err := renderToken(t.TokenID, fields, bundles, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("token %d: %w", t.TokenID, err)
+		}
+		renders[i] = *tr
+
+		sum += tr.Gas
+		if tr.Gas > r.Max {
+			r.Max = tr.Gas
+		}
+		if tr.Gas > cfg.GasLimit {
+			r.OverLimit = append(r.OverLimit, t.TokenID)
+		}
+	}
+	if len(tokens) > 0 {
+		r.Mean = sum / uint64(len(tokens))
+	}
+
+	sort.SliceStable(renders, func(i, j int) bool {
+		return renders[i].Gas > renders[j].Gas
+	})
+	if len(renders) > cfg.WorstTokens {
+		renders = renders[:cfg.WorstTokens]
+	}
+	r.Worst = renders
+
+	return r, nil
+}
+
+// renderToken estimates the gas of rendering a single token.
+func renderToken(id uint16, fields []RenderedField, bundles map[string]*storage.BundleManifest, cfg RenderConfig) (*TokenRender, error) {
+	type key struct {
+		bundle          string
+		storage, bucket int
+	}
+	var (
+		order   []key
+		buckets = make(map[key]*RenderBucket)
+	)
+	for _, f := range fields {
+		m, ok := bundles[f.Bundle]
+		if !ok {
+			return nil, fmt.Errorf("no manifest of bundle %q", f.Bundle)
+		}
+		l := f.Location
+		if l.StorageID < 0 || l.StorageID >= len(m.Storages) {
+			return nil, fmt.Errorf("storage %d out of range for bundle %q with %d storages", l.StorageID, f.Bundle, len(m.Storages))
+		}
+		s := m.Storages[l.StorageID]
+		if l.BucketID < 0 || l.BucketID >= len(s.Buckets) {
+			return nil, fmt.Errorf("bucket %d out of range for storage %q with %d buckets", l.BucketID, s.Name, len(s.Buckets))
+		}
+		b := s.Buckets[l.BucketID]
+		if l.FieldID < 0 || l.FieldID >= b.NumFields {
+			return nil, fmt.Errorf("field %d out of range for bucket %d of storage %q with %d fields", l.FieldID, l.BucketID, s.Name, b.NumFields)
+		}
+
+		k := key{f.Bundle, l.StorageID, l.BucketID}
+		rb, ok := buckets[k]
+		if !ok {
+			rb = &RenderBucket{
+				Bundle:           f.Bundle,
+				Storage:          s.Name,
+				StorageID:        l.StorageID,
+				Bucket:           l.BucketID,
+				CompressedSize:   b.Size,
+				UncompressedSize: b.UncompressedSize,
+			}
+			buckets[k] = rb
+			order = append(order, k)
+		}
+		rb.Lookups++
+		if rb.Lookups == 1 || !cfg.ReuseBuckets {
+			rb.Gas += cfg.Gas(b.Size, b.UncompressedSize)
+		}
+	}
+
+	tr := &TokenRender{TokenID: id, Gas: cfg.BaseGas}
+	for _, k := range order {
+		tr.Buckets = append(tr.Buckets, *buckets[k])
+		tr.Gas += buckets[k].Gas
+	}
+	sort.SliceStable(tr.Buckets, func(i, j int) bool {
+		return tr.Buckets[i].Gas > tr.Buckets[j].Gas
+	})
+	return tr, nil
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *RenderReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes a human-readable summary of the report, listing the
+// worst tokens and the buckets driving their cost.
+func (r *RenderReport) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("# Render gas\n\n")
+	fmt.Fprintf(&b, "%d tokens, mean %d, max %d gas; %d exceeding the limit of %d gas.\n", r.NumTokens, r.Mean, r.Max, len(r.OverLimit), r.GasLimit)
+
+	for _, t := range r.Worst {
+		fmt.Fprintf(&b, "\n## Token %d: %d gas\n\n", t.TokenID, t.Gas)
+		b.WriteString("| Bundle | Storage | Bucket | Compressed | Uncompressed | Lookups | Gas |\n")
+		b.WriteString("| --- | --- | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, rb := range t.Buckets {
+			fmt.Fprintf(&b, "| %s | %s | %d | %d | %d | %d | %d |\n", rb.Bundle, rb.Storage, rb.Bucket, rb.CompressedSize, rb.UncompressedSize, rb.Lookups, rb.Gas)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,262 @@
+package gasprofile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/proofxyz/solidify/go/storage"
+	"github.com/proofxyz/solidify/go/types"
+)
+
+func testManifests() []*storage.BundleManifest {
+	return []*storage.BundleManifest{
+		{
+			Name: "Layers",
+			Storages: []storage.StorageManifest{
+				{
+					Name: "LayersBucketStorage0",
+					Buckets: []storage.BucketManifest{
+						{NumFields: 2, Size: 100, UncompressedSize: 300},
+						{NumFields: 3, Size: 1000, UncompressedSize: 5000},
+					},
+				},
+			},
+		},
+		{
+			Name: "Traits",
+			Storages: []storage.StorageManifest{
+				{
+					Name:    "TraitsBucketStorage0",
+					Buckets: []storage.BucketManifest{{NumFields: 4, Size: 10, UncompressedSize: 40}},
+				},
+			},
+		},
+	}
+}
+
+// testRenderLocator maps the first feature to a field of the Layers bundle and
+// the second one to a field of the Traits bundle.
+func testRenderLocator(t types.Token) ([]RenderedField, error) {
+	if len(t.Features) != 2 {
+		return nil, fmt.Errorf("got %d features; want 2", len(t.Features))
+	}
+	layer := int(t.Features[0])
+	return []RenderedField{
+		// The layer is drawn on top of the first field of the storage.
+		{Bundle: "Layers", Location: storage.FieldLocation{BucketID: 0, FieldID: 0}},
+		{Bundle: "Layers", Location: storage.FieldLocation{BucketID: layer / 2, FieldID: layer % 2}},
+		{Bundle: "Traits", Location: storage.FieldLocation{FieldID: int(t.Features[1])}},
+	}, nil
+}
+
+// uncompressedGas charges one gas per uncompressed byte.
+func uncompressedGas(_, uncompressed int) uint64 {
+	return uint64(uncompressed)
+}
+
+func TestAnalyzeRenders(t *testing.T) {
+	tokens := []types.Token{
+		{TokenID: 0, Features: []uint8{0, 0}},
+		{TokenID: 1, Features: []uint8{2, 1}},
+		{TokenID: 2, Features: []uint8{1, 2}},
+		{TokenID: 3, Features: []uint8{3, 3}},
+	}
+
+	tests := []struct {
+		name string
+		cfg  RenderConfig
+		want *RenderReport
+	}{
+		{
+			name: "separate lookups",
+			cfg: RenderConfig{
+				Gas:         uncompressedGas,
+				BaseGas:     1,
+				GasLimit:    5000,
+				WorstTokens: 2,
+			},
+			want: &RenderReport{
+				NumTokens: 4,
+				Mean:      (641 + 5341 + 641 + 5341) / 4,
+				Max:       5341,
+				GasLimit:  5000,
+				OverLimit: []uint16{1, 3},
+				Worst: []TokenRender{
+					{
+						TokenID: 1,
+						Gas:     5341,
+						Buckets: []RenderBucket{
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 1, CompressedSize: 1000, UncompressedSize: 5000, Lookups: 1, Gas: 5000},
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 0, CompressedSize: 100, UncompressedSize: 300, Lookups: 1, Gas: 300},
+							{Bundle: "Traits", Storage: "TraitsBucketStorage0", Bucket: 0, CompressedSize: 10, UncompressedSize: 40, Lookups: 1, Gas: 40},
+						},
+					},
+					{
+						TokenID: 3,
+						Gas:     5341,
+						Buckets: []RenderBucket{
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 1, CompressedSize: 1000, UncompressedSize: 5000, Lookups: 1, Gas: 5000},
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 0, CompressedSize: 100, UncompressedSize: 300, Lookups: 1, Gas: 300},
+							{Bundle: "Traits", Storage: "TraitsBucketStorage0", Bucket: 0, CompressedSize: 10, UncompressedSize: 40, Lookups: 1, Gas: 40},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "reused buckets",
+			cfg: RenderConfig{
+				Gas:          uncompressedGas,
+				ReuseBuckets: true,
+				WorstTokens:  4,
+			},
+			want: &RenderReport{
+				NumTokens: 4,
+				Mean:      (340 + 5340 + 340 + 5340) / 4,
+				Max:       5340,
+				GasLimit:  DefaultRenderGasLimit,
+				OverLimit: []uint16{},
+				Worst: []TokenRender{
+					{
+						TokenID: 1,
+						Gas:     5340,
+						Buckets: []RenderBucket{
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 1, CompressedSize: 1000, UncompressedSize: 5000, Lookups: 1, Gas: 5000},
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 0, CompressedSize: 100, UncompressedSize: 300, Lookups: 1, Gas: 300},
+							{Bundle: "Traits", Storage: "TraitsBucketStorage0", Bucket: 0, CompressedSize: 10, UncompressedSize: 40, Lookups: 1, Gas: 40},
+						},
+					},
+					{
+						TokenID: 3,
+						Gas:     5340,
+						Buckets: []RenderBucket{
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 1, CompressedSize: 1000, UncompressedSize: 5000, Lookups: 1, Gas: 5000},
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 0, CompressedSize: 100, UncompressedSize: 300, Lookups: 1, Gas: 300},
+							{Bundle: "Traits", Storage: "TraitsBucketStorage0", Bucket: 0, CompressedSize: 10, UncompressedSize: 40, Lookups: 1, Gas: 40},
+						},
+					},
+					{
+						TokenID: 0,
+						Gas:     340,
+						Buckets: []RenderBucket{
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 0, CompressedSize: 100, UncompressedSize: 300, Lookups: 2, Gas: 300},
+							{Bundle: "Traits", Storage: "TraitsBucketStorage0", Bucket: 0, CompressedSize: 10, UncompressedSize: 40, Lookups: 1, Gas: 40},
+						},
+					},
+					{
+						TokenID: 2,
+						Gas:     340,
+						Buckets: []RenderBucket{
+							{Bundle: "Layers", Storage: "LayersBucketStorage0", Bucket: 0, CompressedSize: 100, UncompressedSize: 300, Lookups: 2, Gas: 300},
+							{Bundle: "Traits", Storage: "TraitsBucketStorage0", Bucket: 0, CompressedSize: 10, UncompressedSize: 40, Lookups: 1, Gas: 40},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AnalyzeRenders(tokens, testRenderLocator, testManifests(), tt.cfg)
+			if err != nil {
+				t.Fatalf("AnalyzeRenders(…) error %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("AnalyzeRenders(…) diff (-want +got):\n%s", diff)
+			}
+
+			var js bytes.Buffer
+			if err := got.WriteJSON(&js); err != nil {
+				t.Fatalf("%T.WriteJSON() error %v", got, err)
+			}
+			roundTrip := new(RenderReport)
+			if err := json.Unmarshal(js.Bytes(), roundTrip); err != nil {
+				t.Fatalf("json.Unmarshal(%T.WriteJSON()) error %v", got, err)
+			}
+			if diff := cmp.Diff(got, roundTrip); diff != "" {
+				t.Errorf("JSON round trip diff (-want +got):\n%s", diff)
+			}
+
+			var md strings.Builder
+			if err := got.WriteMarkdown(&md); err != nil {
+				t.Fatalf("%T.WriteMarkdown() error %v", got, err)
+			}
+			for _, want := range []string{"## Token 1: ", "| Layers | LayersBucketStorage0 | 1 | 1000 | 5000 |"} {
+				if !strings.Contains(md.String(), want) {
+					t.Errorf("%T.WriteMarkdown() missing %q; got:\n%s", got, want, md.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeRendersDefaultWorstTokens(t *testing.T) {
+	var tokens []types.Token
+	for i := 0; i < DefaultWorstTokens+2; i++ {
+		tokens = append(tokens, types.Token{TokenID: uint16(i), Features: []uint8{uint8(i % 4), 0}})
+	}
+
+	for _, n := range []int{0, -1} {
+		got, err := AnalyzeRenders(tokens, testRenderLocator, testManifests(), RenderConfig{Gas: uncompressedGas, WorstTokens: n})
+		if err != nil {
+			t.Fatalf("AnalyzeRenders(…, [WorstTokens %d]) error %v", n, err)
+		}
+		if len(got.Worst) != DefaultWorstTokens {
+			t.Errorf("AnalyzeRenders(…, [WorstTokens %d]) got %d worst tokens; want %d", n, len(got.Worst), DefaultWorstTokens)
+		}
+	}
+}
+
+func TestAnalyzeRendersErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        types.Token
+		manifests    []*storage.BundleManifest
+		wantContains string
+	}{
+		{
+			name:         "locator error",
+			token:        types.Token{Features: []uint8{0}},
+			manifests:    testManifests(),
+			wantContains: "locating fields of token",
+		},
+		{
+			name:         "missing manifest",
+			token:        types.Token{Features: []uint8{0, 0}},
+			manifests:    testManifests()[:1],
+			wantContains: `no manifest of bundle "Traits"`,
+		},
+		{
+			name:         "duplicate manifest",
+			token:        types.Token{Features: []uint8{0, 0}},
+			manifests:    append(testManifests(), testManifests()[0]),
+			wantContains: "duplicate manifest",
+		},
+		{
+			name:         "bucket out of range",
+			token:        types.Token{Features: []uint8{4, 0}},
+			manifests:    testManifests(),
+			wantContains: "bucket 2 out of range",
+		},
+		{
+			name:         "field out of range",
+			token:        types.Token{Features: []uint8{0, 4}},
+			manifests:    testManifests(),
+			wantContains: "field 4 out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := AnalyzeRenders([]types.Token{tt.token}, testRenderLocator, tt.manifests, RenderConfig{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("AnalyzeRenders(…) got err %v; want containing %q", err, tt.wantContains)
+			}
+		})
+	}
+}
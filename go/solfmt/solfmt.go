@@ -0,0 +1,416 @@
+// Package solfmt formats the subset of Solidity generated by solidify.
+//
+// The formatter is line-based and does not parse Solidity. It re-indents code
+// by its bracket nesting, aligns doc comments, normalises blank lines and
+// wraps function headers exceeding the line length, following the style of
+// `forge fmt` with the settings of this repository. Literals and expressions
+// are never rewritten, so long hex strings remain on a single line.
+package solfmt
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// LineLength is the maximum line length that function headers are wrapped
+	// at, matching `line_length` in foundry.toml.
+	LineLength = 80
+	// Indent is the indentation of a single nesting level.
+	Indent = "    "
+)
+
+// Format formats Solidity source code. The result is idempotent, i.e.
+// formatting it again leaves it unchanged.
+func Format(src []byte) ([]byte, error) {
+	f := new(formatter)
+	for i, l := range strings.Split(string(src), "\n") {
+		if err := f.line(l); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	if f.inComment {
+		return nil, fmt.Errorf("unterminated block comment")
+	}
+	if len(f.stack) > 0 {
+		return nil, fmt.Errorf("%d unclosed brackets", f.open())
+	}
+
+	return []byte(strings.Join(f.out, "\n") + "\n"), nil
+}
+
+// A frame is a nesting level opened by one or more brackets on the same line.
+type frame struct {
+	// open is the number of brackets that remain to be closed.
+	open int
+	// yul indicates an assembly block, in which statements are not terminated
+	// by semicolons.
+	yul bool
+}
+
+type formatter struct {
+	out   []string
+	stack []frame
+
+	// lastCode is the index in out of the last line containing code.
+	lastCode int
+	// unfinished indicates that the last code line did not complete its
+	// statement, so the next line is a continuation.
+	unfinished bool
+	// blank indicates that blank lines were skipped since the last line.
+	blank     bool
+	inComment bool
+}
+
+func (f *formatter) open() int {
+	var n int
+	for _, fr := range f.stack {
+		n += fr.open
+	}
+	return n
+}
+
+func (f *formatter) inYul() bool {
+	return len(f.stack) > 0 && f.stack[len(f.stack)-1].yul
+}
+
+func (f *formatter) indent(extra int) string {
+	return strings.Repeat(Indent, len(f.stack)+extra)
+}
+
+// emit appends a line, retaining a single blank line before it unless it
+// directly follows an opening or precedes a closing bracket.
+func (f *formatter) emit(l string) {
+	if f.blank && len(f.out) > 0 {
+		prev := f.out[len(f.out)-1]
+		cur := strings.TrimSpace(l)
+		if !strings.ContainsAny(prev[len(prev)-1:], "{([") && !strings.ContainsAny(cur[:1], "})]") {
+			f.out = append(f.out, "")
+		}
+	}
+	f.blank = false
+	f.out = append(f.out, l)
+}
+
+func (f *formatter) line(raw string) error {
+	l := strings.TrimSpace(raw)
+
+	if f.inComment {
+		if strings.Contains(l, "*/") {
+			f.inComment = false
+		}
+		if strings.HasPrefix(l, "*") {
+			l = " " + l
+		}
+		f.out = append(f.out, strings.TrimRight(f.indent(0)+l, " "))
+		return nil
+	}
+
+	if l == "" {
+		f.blank = true
+		return nil
+	}
+
+	// Templates emitting lists may place separators at the beginning of
+	// lines, which are moved to the end of the previous code line instead.
+	if strings.HasPrefix(l, ",") {
+		if len(f.out) == 0 {
+			return fmt.Errorf("leading %q without preceding code", ",")
+		}
+		f.out[f.lastCode] += ","
+		f.unfinished = false
+		l = strings.TrimSpace(l[1:])
+		if l == "" {
+			return nil
+		}
+	}
+
+	toks, comment, err := scan(l)
+	if err != nil {
+		return err
+	}
+	if comment == blockComment {
+		f.inComment = true
+	}
+
+	var i int
+	for ; i < len(toks) && toks[i].leading; i++ {
+		if err := f.close(); err != nil {
+			return err
+		}
+	}
+
+	code := !isComment(l)
+	cont := code && f.unfinished && i == 0 && !strings.HasPrefix(l, "{")
+
+	extra := 0
+	if cont {
+		extra = 1
+	}
+	indent := f.indent(extra)
+
+	var pending int
+	for _, t := range toks[i:] {
+		if t.opening {
+			pending++
+			continue
+		}
+		if pending > 0 {
+			pending--
+			continue
+		}
+		if err := f.close(); err != nil {
+			return err
+		}
+	}
+	yul := f.inYul() || strings.HasPrefix(l, "assembly")
+
+	if code && len(indent)+len(l) > LineLength && isFunctionHeader(l) {
+		f.emitHeader(indent, l)
+	} else {
+		f.emit(indent + l)
+	}
+
+	if pending > 0 {
+		f.stack = append(f.stack, frame{open: pending, yul: yul})
+	}
+	if code {
+		f.lastCode = len(f.out) - 1
+		f.unfinished = !f.inYul() && !finished(stripComment(l))
+	}
+	return nil
+}
+
+// close closes a single bracket of the innermost frame.
+func (f *formatter) close() error {
+	if len(f.stack) == 0 {
+		return fmt.Errorf("unbalanced closing bracket")
+	}
+	top := &f.stack[len(f.stack)-1]
+	top.open--
+	if top.open == 0 {
+		f.stack = f.stack[:len(f.stack)-1]
+	}
+	return nil
+}
+
+// isComment returns whether a line only contains a comment.
+func isComment(l string) bool {
+	return strings.HasPrefix(l, "//") || strings.HasPrefix(l, "/*")
+}
+
+// finished returns whether a line of code completes a statement or opens or
+// closes a block, as opposed to being continued on the next line.
+func finished(code string) bool {
+	if code == "" {
+		return true
+	}
+	switch code[len(code)-1] {
+	case '{', '}', ';', ',', '(', '[':
+		return true
+	}
+	return false
+}
+
+// isFunctionHeader returns whether a line is a function (or constructor)
+// header followed by its body.
+func isFunctionHeader(l string) bool {
+	return (strings.HasPrefix(l, "function ") || strings.HasPrefix(l, "constructor(")) && strings.HasSuffix(l, "{")
+}
+
+// emitHeader wraps a function header, placing each attribute on its own line
+// and, if the parameters don't fit either, each parameter as well.
+func (f *formatter) emitHeader(indent, l string) {
+	open := strings.Index(l, "(")
+	close := matching(l, open)
+	if close < 0 {
+		f.emit(indent + l)
+		return
+	}
+
+	head := l[:close+1]
+	attrs := splitTopLevel(strings.TrimSpace(strings.TrimSuffix(l[close+1:], "{")), ' ')
+	for i := 0; i < len(attrs); i++ {
+		if attrs[i] == "returns" && i+1 < len(attrs) {
+			attrs[i] += " " + attrs[i+1]
+			attrs = append(attrs[:i+1], attrs[i+2:]...)
+		}
+	}
+
+	inner := indent + Indent
+	if len(indent)+len(head) <= LineLength {
+		f.emit(indent + head)
+		for _, a := range attrs {
+			f.emit(inner + a)
+		}
+		f.emit(indent + "{")
+		return
+	}
+
+	f.emit(indent + l[:open+1])
+	params := splitTopLevel(l[open+1:close], ',')
+	for i, p := range params {
+		if i < len(params)-1 {
+			p += ","
+		}
+		f.emit(inner + p)
+	}
+
+	tail := ") " + strings.Join(append(attrs, "{"), " ")
+	if len(attrs) == 0 || len(indent)+len(tail) <= LineLength {
+		f.emit(indent + tail)
+		return
+	}
+	f.emit(indent + ")")
+	for _, a := range attrs {
+		f.emit(inner + a)
+	}
+	f.emit(indent + "{")
+}
+
+// matching returns the index of the bracket closing the one at index i, or -1
+// if there is none.
+func matching(l string, i int) int {
+	if i < 0 {
+		return -1
+	}
+	var depth int
+	for j := i; j < len(l); j++ {
+		switch l[j] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		case '"', '\'':
+			end := strings.IndexByte(l[j+1:], l[j])
+			if end < 0 {
+				return -1
+			}
+			j += end + 1
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits s at every sep that is not enclosed in brackets,
+// dropping empty parts.
+func splitTopLevel(s string, sep byte) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	add := func(p string) {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				add(s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(s[start:])
+	return parts
+}
+
+// stripComment removes a trailing comment from a line of code.
+func stripComment(l string) string {
+	if i := commentStart(l); i >= 0 {
+		return strings.TrimSpace(l[:i])
+	}
+	return l
+}
+
+type bracket struct {
+	opening bool
+	// leading indicates a closing bracket preceded only by other closing
+	// brackets and whitespace.
+	leading bool
+}
+
+type commentKind int
+
+const (
+	noComment commentKind = iota
+	lineComment
+	// blockComment is an unterminated block comment.
+	blockComment
+)
+
+// scan returns the brackets in a line, skipping those in literals and
+// comments, and the kind of comment the line ends in.
+func scan(l string) ([]bracket, commentKind, error) {
+	var toks []bracket
+	leading := true
+	for i := 0; i < len(l); i++ {
+		c := l[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := literalEnd(l, i)
+			if end < 0 {
+				return nil, noComment, fmt.Errorf("unterminated literal")
+			}
+			i = end
+		case strings.HasPrefix(l[i:], "//"):
+			return toks, lineComment, nil
+		case strings.HasPrefix(l[i:], "/*"):
+			end := strings.Index(l[i+2:], "*/")
+			if end < 0 {
+				return toks, blockComment, nil
+			}
+			i += end + 3
+		case c == '(' || c == '[' || c == '{':
+			toks = append(toks, bracket{opening: true})
+		case c == ')' || c == ']' || c == '}':
+			toks = append(toks, bracket{leading: leading})
+			continue
+		case c == ' ' || c == '\t':
+			continue
+		}
+		leading = false
+	}
+	return toks, noComment, nil
+}
+
+// literalEnd returns the index of the quote terminating the string literal
+// starting at index i, or -1 if it is unterminated.
+func literalEnd(l string, i int) int {
+	for j := i + 1; j < len(l); j++ {
+		switch l[j] {
+		case '\\':
+			j++
+		case l[i]:
+			return j
+		}
+	}
+	return -1
+}
+
+// commentStart returns the index at which a comment starts in a line, or -1.
+func commentStart(l string) int {
+	for i := 0; i < len(l); i++ {
+		switch {
+		case l[i] == '"' || l[i] == '\'':
+			end := literalEnd(l, i)
+			if end < 0 {
+				return -1
+			}
+			i = end
+		case strings.HasPrefix(l[i:], "//"), strings.HasPrefix(l[i:], "/*"):
+			return i
+		}
+	}
+	return -1
+}
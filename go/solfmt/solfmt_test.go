@@ -0,0 +1,207 @@
+package solfmt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "Indentation and doc comments",
+			src: `
+contract Foo {
+
+        /**
+        * @notice Bar.
+        */
+  function bar() external pure returns (uint256) {
+return 1;
+
+
+      }
+
+}`,
+			want: `contract Foo {
+    /**
+     * @notice Bar.
+     */
+    function bar() external pure returns (uint256) {
+        return 1;
+    }
+}
+`,
+		},
+		{
+			name: "Leading separators",
+			src: `enum Foo {
+    A
+    , B
+
+    , C
+}`,
+			want: `enum Foo {
+    A,
+    B,
+
+    C
+}
+`,
+		},
+		{
+			name: "Nested brackets on a single line",
+			src: `function foo() {
+return Compressed({
+size: 1,
+data: hex"00"
+});
+}`,
+			want: `function foo() {
+    return Compressed({
+        size: 1,
+        data: hex"00"
+    });
+}
+`,
+		},
+		{
+			name: "Continuation lines",
+			src: `function foo() {
+return a == b
+|| a == c;
+bar();
+}`,
+			want: `function foo() {
+    return a == b
+        || a == c;
+    bar();
+}
+`,
+		},
+		{
+			name: "Assembly",
+			src: `function foo() {
+assembly {
+x := mload(0)
+y := add(x, 1)
+}
+}`,
+			want: `function foo() {
+    assembly {
+        x := mload(0)
+        y := add(x, 1)
+    }
+}
+`,
+		},
+		{
+			name: "Brackets in literals and comments",
+			src: `function foo() {
+string memory s = "{(";
+// }
+/* ) */ bar();
+}`,
+			want: `function foo() {
+    string memory s = "{(";
+    // }
+    /* ) */ bar();
+}
+`,
+		},
+		{
+			name: "Wrapped attributes",
+			src: `contract C {
+function getBuckets(uint256[] calldata idxs) external pure returns (Compressed[] memory buckets) {
+}
+}`,
+			want: `contract C {
+    function getBuckets(uint256[] calldata idxs)
+        external
+        pure
+        returns (Compressed[] memory buckets)
+    {
+    }
+}
+`,
+		},
+		{
+			name: "Wrapped parameters",
+			src: `contract C {
+function foo(uint256 aVeryLongParameterName, uint256 anotherVeryLongParameterName) internal {
+}
+}`,
+			want: `contract C {
+    function foo(
+        uint256 aVeryLongParameterName,
+        uint256 anotherVeryLongParameterName
+    ) internal {
+    }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Format([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("Format(…) error %v", err)
+			}
+			if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+				t.Errorf("Format(…) diff (-want +got):\n%s", diff)
+			}
+
+			again, err := Format(got)
+			if err != nil {
+				t.Fatalf("Format(Format(…)) error %v", err)
+			}
+			if diff := cmp.Diff(string(got), string(again)); diff != "" {
+				t.Errorf("Format(…) is not idempotent; diff (-once +twice):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		src          string
+		wantContains string
+	}{
+		{
+			name:         "Unbalanced",
+			src:          "}",
+			wantContains: "line 1: unbalanced closing bracket",
+		},
+		{
+			name:         "Unclosed",
+			src:          "contract Foo {\n",
+			wantContains: "1 unclosed brackets",
+		},
+		{
+			name:         "Unterminated literal",
+			src:          "contract Foo {\nstring s = \"foo;\n}",
+			wantContains: "line 2: unterminated literal",
+		},
+		{
+			name:         "Unterminated comment",
+			src:          "/**\n * foo",
+			wantContains: "unterminated block comment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Format([]byte(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("Format(%q) got err %v; want containing %q", tt.src, err, tt.wantContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// An ArtifactFormat is the layout of the compilation artifacts expected by a
+// development framework.
+type ArtifactFormat int
+
+// Supported ArtifactFormats.
+const (
+	// FoundryArtifacts are written as `<out>/<Name>.sol/<Name>.json`, as found
+	// by `vm.getCode("<Name>.sol:<Name>")`.
+	FoundryArtifacts ArtifactFormat = iota
+	// HardhatArtifacts are written as `<artifacts>/<sourceName>/<Name>.json`.
+	HardhatArtifacts
+)
+
+// foundryArtifact mirrors the relevant subset of the artifacts written by forge.
+type foundryArtifact struct {
+	ABI               json.RawMessage   `json:"abi"`
+	Bytecode          foundryBytecode   `json:"bytecode"`
+	DeployedBytecode  foundryBytecode   `json:"deployedBytecode"`
+	MethodIdentifiers map[string]string `json:"methodIdentifiers"`
+}
+
+type foundryBytecode struct {
+	Object         hexutil.Bytes  `json:"object"`
+	SourceMap      string         `json:"sourceMap"`
+	LinkReferences map[string]any `json:"linkReferences"`
+}
+
+// hardhatArtifact mirrors the artifacts written by hardhat.
+type hardhatArtifact struct {
+	Format                 string          `json:"_format"`
+	ContractName           string          `json:"contractName"`
+	SourceName             string          `json:"sourceName"`
+	ABI                    json.RawMessage `json:"abi"`
+	Bytecode               hexutil.Bytes   `json:"bytecode"`
+	DeployedBytecode       hexutil.Bytes   `json:"deployedBytecode"`
+	LinkReferences         map[string]any  `json:"linkReferences"`
+	DeployedLinkReferences map[string]any  `json:"deployedLinkReferences"`
+}
+
+// WriteStorageArtifact writes a ready-to-deploy artifact of the contract
+// implementing IBucketStorage for a given storage (see StorageBytecode). The
+// sourceName is the path of the Solidity file the contract would be compiled
+// from, e.g. "src/gen/storage/FooBucketStorage0.sol", and is only used by
+// HardhatArtifacts.
+func WriteStorageArtifact(s BucketStorage, format ArtifactFormat, sourceName string, w io.Writer) error {
+	code, err := StorageBytecode(s)
+	if err != nil {
+		return err
+	}
+
+	var artifact any
+	switch format {
+	case FoundryArtifacts:
+		ids := make(map[string]string)
+		for _, m := range bucketStorageABI.Methods {
+			ids[m.Sig] = hexutil.Encode(m.ID)[2:]
+		}
+		artifact = foundryArtifact{
+			ABI:               json.RawMessage(BucketStorageABI),
+			Bytecode:          foundryBytecode{Object: code.Creation, LinkReferences: map[string]any{}},
+			DeployedBytecode:  foundryBytecode{Object: code.Deployed, LinkReferences: map[string]any{}},
+			MethodIdentifiers: ids,
+		}
+	case HardhatArtifacts:
+		artifact = hardhatArtifact{
+			Format:                 "hh-sol-artifact-1",
+			ContractName:           s.Name(),
+			SourceName:             sourceName,
+			ABI:                    json.RawMessage(BucketStorageABI),
+			Bytecode:               code.Creation,
+			DeployedBytecode:       code.Deployed,
+			LinkReferences:         map[string]any{},
+			DeployedLinkReferences: map[string]any{},
+		}
+	default:
+		return fmt.Errorf("unsupported artifact format %d", format)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(artifact)
+}
+
+// WriteStorageArtifacts is a convenience wrapper to write the artifacts of all
+// storages to fsys (or disk if nil), using the directory layout of the given
+// format. The sourceDir is the directory the storage contracts would be
+// compiled from (see WriteStorageArtifact). It returns the paths of the
+// written artifacts.
+func WriteStorageArtifacts[S BucketStorage](stores []S, format ArtifactFormat, sourceDir string, fsys WritableFS, outDir string) ([]string, error) {
+	var paths []string
+	for _, s := range stores {
+		src := path.Join(filepath.ToSlash(sourceDir), s.Name()+".sol")
+
+		var p string
+		switch format {
+		case FoundryArtifacts:
+			p = filepath.Join(outDir, s.Name()+".sol", s.Name()+".json")
+		case HardhatArtifacts:
+			p = filepath.Join(outDir, filepath.FromSlash(src), s.Name()+".json")
+		default:
+			return nil, fmt.Errorf("unsupported artifact format %d", format)
+		}
+
+		if err := writeFile(fsys, p, func(w io.Writer) error {
+			return WriteStorageArtifact(s, format, src, w)
+		}); err != nil {
+			return nil, fmt.Errorf("writing artifact %q: %w", p, err)
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
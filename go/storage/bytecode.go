@@ -0,0 +1,419 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/proofxyz/solidify/go/evmasm"
+)
+
+// BucketStorageABI is the JSON ABI of the IBucketStorage interface.
+const BucketStorageABI = `[
+  {"type":"error","name":"InvalidBucketIndex","inputs":[]},
+  {"type":"function","name":"bucketFormat","stateMutability":"pure","inputs":[{"name":"bucketIndex","type":"uint256","internalType":"uint256"}],"outputs":[{"name":"","type":"bytes32","internalType":"bytes32"}]},
+  {"type":"function","name":"bucketHash","stateMutability":"pure","inputs":[{"name":"bucketIndex","type":"uint256","internalType":"uint256"}],"outputs":[{"name":"","type":"bytes32","internalType":"bytes32"}]},
+  {"type":"function","name":"getBucket","stateMutability":"pure","inputs":[{"name":"bucketIndex","type":"uint256","internalType":"uint256"}],"outputs":[{"name":"","type":"tuple","internalType":"struct Compressed","components":[{"name":"uncompressedSize","type":"uint256","internalType":"uint256"},{"name":"data","type":"bytes","internalType":"bytes"}]}]},
+  {"type":"function","name":"getBuckets","stateMutability":"pure","inputs":[{"name":"bucketIndices","type":"uint256[]","internalType":"uint256[]"}],"outputs":[{"name":"","type":"tuple[]","internalType":"struct Compressed[]","components":[{"name":"uncompressedSize","type":"uint256","internalType":"uint256"},{"name":"data","type":"bytes","internalType":"bytes"}]}]},
+  {"type":"function","name":"numBuckets","stateMutability":"pure","inputs":[],"outputs":[{"name":"","type":"uint256","internalType":"uint256"}]},
+  {"type":"function","name":"numFields","stateMutability":"pure","inputs":[],"outputs":[{"name":"","type":"uint256","internalType":"uint256"}]},
+  {"type":"function","name":"numFieldsPerBucket","stateMutability":"pure","inputs":[],"outputs":[{"name":"","type":"uint256[]","internalType":"uint256[]"}]},
+  {"type":"function","name":"storageFormat","stateMutability":"pure","inputs":[],"outputs":[{"name":"","type":"bytes32","internalType":"bytes32"}]},
+  {"type":"function","name":"storageRoot","stateMutability":"pure","inputs":[],"outputs":[{"name":"","type":"bytes32","internalType":"bytes32"}]},
+  {"type":"function","name":"supportsInterface","stateMutability":"pure","inputs":[{"name":"interfaceId","type":"bytes4","internalType":"bytes4"}],"outputs":[{"name":"","type":"bool","internalType":"bool"}]}
+]`
+
+var bucketStorageABI = func() abi.ABI {
+	a, err := abi.JSON(strings.NewReader(BucketStorageABI))
+	if err != nil {
+		panic(err)
+	}
+	return a
+}()
+
+// ERC165InterfaceID is the ERC-165 interface identifier of IERC165.
+var ERC165InterfaceID = [4]byte{0x01, 0xff, 0xc9, 0xa7}
+
+// BucketStorageInterfaceID returns the ERC-165 interface identifier of
+// IBucketStorage, i.e. `type(IBucketStorage).interfaceId`.
+func BucketStorageInterfaceID() [4]byte {
+	var id [4]byte
+	for _, m := range bucketStorageABI.Methods {
+		// Inherited functions are not part of the interface identifier.
+		if m.Name == "supportsInterface" {
+			continue
+		}
+		for i := range id {
+			id[i] ^= m.ID[i]
+		}
+	}
+	return id
+}
+
+// Bytecode is the EVM bytecode of a contract.
+type Bytecode struct {
+	// Creation is the code deploying the contract, i.e. the transaction data
+	// of the deployment.
+	Creation []byte
+	// Deployed is the runtime code of the deployed contract.
+	Deployed []byte
+}
+
+// StorageBytecode assembles the bytecode of a contract implementing
+// IBucketStorage for a given storage, without compiling the Solidity contract
+// generated by WriteBucketStorage.
+//
+// The runtime code consists of a small dispatcher followed by the ABI encoded
+// return values, which are copied from code. It is functionally equivalent to
+// the generated Solidity contract, but does not include Solidity metadata.
+func StorageBytecode(s BucketStorage) (*Bytecode, error) {
+	deployed, err := storageRuntime(s)
+	if err != nil {
+		return nil, fmt.Errorf("assembling runtime of %q: %w", s.Name(), err)
+	}
+
+	// The generated contract has no constructor, so deployments must not send
+	// any value.
+	var p evmasm.Program
+	p.Op(vm.CALLVALUE)
+	p.JumpIf("revert")
+	p.Push(uint64(len(deployed)))
+	p.Op(vm.DUP1)
+	p.PushLabel("runtime")
+	p.Push(0)
+	p.Op(vm.CODECOPY)
+	p.Push(0)
+	p.Op(vm.RETURN)
+	p.Jumpdest("revert")
+	p.Push(0)
+	p.Op(vm.DUP1, vm.REVERT)
+	p.Label("runtime")
+	p.Data(deployed)
+
+	creation, err := p.Assemble()
+	if err != nil {
+		return nil, fmt.Errorf("assembling creation code of %q: %w", s.Name(), err)
+	}
+	return &Bytecode{Creation: creation, Deployed: deployed}, nil
+}
+
+// lowerMask returns a mask of the lower n bits.
+func lowerMask(n uint) *big.Int {
+	m := new(big.Int).Lsh(big.NewInt(1), n)
+	return m.Sub(m, big.NewInt(1))
+}
+
+// storageRuntime assembles the runtime code of a BucketStorage.
+func storageRuntime(s BucketStorage) ([]byte, error) {
+	bs := s.Buckets()
+	n := uint64(len(bs))
+
+	var (
+		hashes, formats []byte
+		tuples          [][]byte
+	)
+	for i, b := range bs {
+		h, err := BucketHash(b)
+		if err != nil {
+			return nil, fmt.Errorf("BucketHash([bucket %d]): %w", i, err)
+		}
+		hashes = append(hashes, h.Bytes()...)
+		formats = append(formats, BucketFormatOf(b).Descriptor().Bytes()...)
+
+		t, err := encodeCompressed(b)
+		if err != nil {
+			return nil, fmt.Errorf("encoding bucket %d: %w", i, err)
+		}
+		tuples = append(tuples, t)
+	}
+
+	root, err := StorageRoot(s)
+	if err != nil {
+		return nil, fmt.Errorf("StorageRoot(): %w", err)
+	}
+	perBucket := make([]*big.Int, len(bs))
+	for i, b := range bs {
+		perBucket[i] = big.NewInt(int64(b.NumFields()))
+	}
+	numFieldsPerBucket, err := bucketStorageABI.Methods["numFieldsPerBucket"].Outputs.Pack(perBucket)
+	if err != nil {
+		return nil, fmt.Errorf("encoding fields per bucket: %w", err)
+	}
+
+	constants := []struct {
+		method string
+		data   []byte
+	}{
+		{"numBuckets", common.BigToHash(new(big.Int).SetUint64(n)).Bytes()},
+		{"numFields", common.BigToHash(big.NewInt(int64(s.NumFields()))).Bytes()},
+		{"numFieldsPerBucket", numFieldsPerBucket},
+		{"storageRoot", root.Bytes()},
+		{"storageFormat", StorageFormatOf(s).Descriptor().Bytes()},
+	}
+
+	var p evmasm.Program
+
+	// All functions are pure and therefore not payable.
+	p.Op(vm.CALLVALUE)
+	p.JumpIf("revert")
+	p.Push(4)
+	p.Op(vm.CALLDATASIZE, vm.LT)
+	p.JumpIf("revert")
+	p.Push(0)
+	p.Op(vm.CALLDATALOAD)
+	p.Push(0xe0)
+	p.Op(vm.SHR)
+
+	var methods []string
+	for _, c := range constants {
+		methods = append(methods, c.method)
+	}
+	methods = append(methods, "bucketHash", "bucketFormat", "getBucket", "getBuckets", "supportsInterface")
+	for _, m := range methods {
+		p.Op(vm.DUP1)
+		p.PushBytes(bucketStorageABI.Methods[m].ID)
+		p.Op(vm.EQ)
+		p.JumpIf(m)
+	}
+
+	p.Jumpdest("revert")
+	p.Push(0)
+	p.Op(vm.DUP1, vm.REVERT)
+
+	p.Jumpdest("invalidIndex")
+	errID := bucketStorageABI.Errors["InvalidBucketIndex"].ID
+	p.PushBytes(errID[:4])
+	p.Push(0xe0)
+	p.Op(vm.SHL)
+	p.Push(0)
+	p.Op(vm.MSTORE)
+	p.Push(4)
+	p.Push(0)
+	p.Op(vm.REVERT)
+
+	for _, c := range constants {
+		p.Jumpdest(c.method)
+		p.Push(uint64(len(c.data)))
+		p.PushLabel(c.method + "Data")
+		p.Push(0)
+		p.Op(vm.CODECOPY)
+		p.Push(uint64(len(c.data)))
+		p.Push(0)
+		p.Op(vm.RETURN)
+	}
+
+	// loadIndex leaves the bucket index of the first argument on the stack,
+	// reverting if it is out-of-bounds.
+	loadIndex := func() {
+		p.Push(0x24)
+		p.Op(vm.CALLDATASIZE, vm.LT)
+		p.JumpIf("revert")
+		p.Push(4)
+		p.Op(vm.CALLDATALOAD)
+		checkIndex(&p, n)
+	}
+
+	// Returns the 32-byte entry of a table for a given bucket index.
+	for _, t := range []struct{ method, table string }{
+		{"bucketHash", "hashes"},
+		{"bucketFormat", "formats"},
+	} {
+		p.Jumpdest(t.method)
+		loadIndex()
+		loadEntry(&p, t.table, 0)
+		p.Push(0x20)
+		p.Push(0)
+		p.Op(vm.RETURN)
+	}
+
+	// Each meta entry packs the code offset of the ABI encoded Compressed tuple
+	// of a bucket in the upper and its length in the lower 128 bits.
+	lenMask := lowerMask(128)
+
+	p.Jumpdest("getBucket")
+	loadIndex()
+	loadEntry(&p, "meta", 0) //                     [meta]
+	p.Op(vm.DUP1)
+	p.PushBig(lenMask)
+	p.Op(vm.AND, vm.SWAP1) //                       [meta, len]
+	p.Push(128)
+	p.Op(vm.SHR, vm.DUP2, vm.SWAP1) //              [off, len, len]
+	p.Push(0x20)
+	p.Op(vm.CODECOPY) //                            [len]
+	p.Push(0x20)
+	p.Push(0)
+	p.Op(vm.MSTORE)
+	p.Push(0x20)
+	p.Op(vm.ADD)
+	p.Push(0)
+	p.Op(vm.RETURN)
+
+	p.Jumpdest("getBuckets")
+	p.Push(0x44)
+	p.Op(vm.CALLDATASIZE, vm.LT)
+	p.JumpIf("revert")
+	p.Push(4)
+	p.Op(vm.CALLDATALOAD) //                        [o]
+	checkUint64(&p)
+	p.Push(4)
+	p.Op(vm.ADD, vm.DUP1, vm.CALLDATALOAD) //       [n, a]
+	checkUint64(&p)
+	p.Op(vm.SWAP1)
+	p.Push(0x20)
+	p.Op(vm.ADD) //                                 [base, n]
+	p.Op(vm.DUP2)
+	p.Push(5)
+	p.Op(vm.SHL, vm.DUP2, vm.ADD, vm.CALLDATASIZE, vm.LT)
+	p.JumpIf("revert")
+	p.Push(0x20)
+	p.Push(0)
+	p.Op(vm.MSTORE)
+	p.Op(vm.DUP2)
+	p.Push(0x20)
+	p.Op(vm.MSTORE)
+	p.Op(vm.DUP2)
+	p.Push(5)
+	p.Op(vm.SHL)
+	p.Push(0x40)
+	p.Op(vm.ADD) //                                 [p, base, n]
+	p.Push(0)    //                                 [j, p, base, n]
+
+	p.Jumpdest("getBucketsLoop")
+	p.Op(vm.DUP4, vm.DUP2, vm.LT, vm.ISZERO)
+	p.JumpIf("getBucketsDone")
+	p.Op(vm.DUP1)
+	p.Push(5)
+	p.Op(vm.SHL, vm.DUP4, vm.ADD, vm.CALLDATALOAD) // [i, j, p, base, n]
+	checkIndex(&p, n)
+	loadEntry(&p, "meta", 2) //                     [meta, j, p, base, n]
+	p.Push(0x40)
+	p.Op(vm.DUP4, vm.SUB, vm.DUP3)
+	p.Push(5)
+	p.Op(vm.SHL)
+	p.Push(0x40)
+	p.Op(vm.ADD, vm.MSTORE) //                      [meta, j, p, base, n]
+	p.Op(vm.DUP1)
+	p.PushBig(lenMask)
+	p.Op(vm.AND, vm.SWAP1)
+	p.Push(128)
+	p.Op(vm.SHR, vm.DUP2, vm.SWAP1, vm.DUP5) //     [p, off, len, len, j, p, base, n]
+	p.Op(vm.CODECOPY, vm.DUP3, vm.ADD)       //     [p', j, p, base, n]
+	p.Op(vm.SWAP2, vm.POP)
+	p.Push(1)
+	p.Op(vm.ADD) //                                 [j+1, p', base, n]
+	p.Jump("getBucketsLoop")
+
+	p.Jumpdest("getBucketsDone")
+	p.Op(vm.POP)
+	p.Push(0)
+	p.Op(vm.RETURN)
+
+	p.Jumpdest("supportsInterface")
+	p.Push(0x24)
+	p.Op(vm.CALLDATASIZE, vm.LT)
+	p.JumpIf("revert")
+	p.Push(4)
+	p.Op(vm.CALLDATALOAD, vm.DUP1)
+	p.PushBig(lowerMask(224))
+	p.Op(vm.AND)
+	p.JumpIf("revert")
+	p.Push(0xe0)
+	p.Op(vm.SHR, vm.DUP1)
+	id := BucketStorageInterfaceID()
+	p.PushBytes(id[:])
+	p.Op(vm.EQ, vm.SWAP1)
+	p.PushBytes(ERC165InterfaceID[:])
+	p.Op(vm.EQ, vm.OR)
+	p.Push(0)
+	p.Op(vm.MSTORE)
+	p.Push(0x20)
+	p.Push(0)
+	p.Op(vm.RETURN)
+
+	// Data must never be executed.
+	p.Op(vm.INVALID)
+	for _, c := range constants {
+		p.Label(c.method + "Data")
+		p.Data(c.data)
+	}
+	p.Label("hashes")
+	p.Data(hashes)
+	p.Label("formats")
+	p.Data(formats)
+
+	offset := p.Len() + 32*len(bs)
+	p.Label("meta")
+	for _, t := range tuples {
+		var m [32]byte
+		binary.BigEndian.PutUint64(m[8:16], uint64(offset))
+		binary.BigEndian.PutUint64(m[24:], uint64(len(t)))
+		p.Data(m[:])
+		offset += len(t)
+	}
+	for _, t := range tuples {
+		p.Data(t)
+	}
+
+	return p.Assemble()
+}
+
+// checkIndex reverts with InvalidBucketIndex() if the index on top of the
+// stack is not smaller than n.
+func checkIndex(p *evmasm.Program, n uint64) {
+	p.Push(n)
+	p.Op(vm.DUP2, vm.LT, vm.ISZERO)
+	p.JumpIf("invalidIndex")
+}
+
+// checkUint64 reverts if the value on top of the stack exceeds 64 bits, as
+// done by the Solidity ABI decoder for offsets and lengths.
+func checkUint64(p *evmasm.Program) {
+	p.Op(vm.DUP1)
+	p.PushBig(lowerMask(64))
+	p.Op(vm.LT)
+	p.JumpIf("revert")
+}
+
+// loadEntry replaces the bucket index on top of the stack with the 32-byte
+// entry of a table in code. The entry is loaded via the memory at the offset
+// stored in the stack slot at a given depth, counted from the index at depth
+// 0, or at offset 0 if depth is 0.
+func loadEntry(p *evmasm.Program, table string, depth int) {
+	p.Push(5)
+	p.Op(vm.SHL)
+	p.PushLabel(table)
+	p.Op(vm.ADD)
+	p.Push(0x20)
+	p.Op(vm.SWAP1) //                               [off, 0x20]
+	if depth == 0 {
+		p.Push(0)
+		p.Op(vm.CODECOPY)
+		p.Push(0)
+		p.Op(vm.MLOAD)
+		return
+	}
+	p.Op(vm.DUP1 + vm.OpCode(depth+1))
+	p.Op(vm.CODECOPY)
+	p.Op(vm.DUP1 + vm.OpCode(depth-1))
+	p.Op(vm.MLOAD)
+}
+
+// encodeCompressed returns the ABI encoding of the Compressed struct of a
+// bucket without the leading offset, i.e. as embedded in an array.
+func encodeCompressed(b Bucket) ([]byte, error) {
+	d, err := b.Data()
+	if err != nil {
+		return nil, fmt.Errorf("%T.Data(): %w", b, err)
+	}
+
+	enc := make([]byte, 96+(len(d)+31)/32*32)
+	copy(enc[:32], common.BigToHash(big.NewInt(int64(b.UncompressedSize()))).Bytes())
+	enc[63] = 0x40
+	copy(enc[64:96], common.BigToHash(big.NewInt(int64(len(d)))).Bytes())
+	copy(enc[96:], d)
+	return enc, nil
+}
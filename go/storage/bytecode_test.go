@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/google/go-cmp/cmp"
+)
+
+// compressed mirrors the Compressed struct for ABI decoding.
+type compressed struct {
+	UncompressedSize *big.Int `abi:"uncompressedSize"`
+	Data             []byte   `abi:"data"`
+}
+
+// deployStorage deploys the bytecode of a storage to an in-memory EVM and
+// returns a function calling it.
+func deployStorage(t *testing.T, s BucketStorage) func(method string, args ...any) ([]any, error) {
+	t.Helper()
+
+	code, err := StorageBytecode(s)
+	if err != nil {
+		t.Fatalf("StorageBytecode(%q) error %v", s.Name(), err)
+	}
+
+	cfg := new(runtime.Config)
+	deployed, addr, _, err := runtime.Create(code.Creation, cfg)
+	if err != nil {
+		t.Fatalf("runtime.Create([creation code of %q]) error %v", s.Name(), err)
+	}
+	if !bytes.Equal(deployed, code.Deployed) {
+		t.Fatalf("runtime.Create([creation code of %q]) deployed unexpected code", s.Name())
+	}
+
+	return func(method string, args ...any) ([]any, error) {
+		in, err := bucketStorageABI.Pack(method, args...)
+		if err != nil {
+			t.Fatalf("%T.Pack(%q, %v) error %v", bucketStorageABI, method, args, err)
+		}
+		ret, _, err := runtime.Call(addr, in, cfg)
+		if err != nil {
+			return nil, &revertError{err: err, data: ret}
+		}
+		out, err := bucketStorageABI.Unpack(method, ret)
+		if err != nil {
+			t.Fatalf("%T.Unpack(%q, %#x) error %v", bucketStorageABI, method, ret, err)
+		}
+		return out, nil
+	}
+}
+
+type revertError struct {
+	err  error
+	data []byte
+}
+
+func (e *revertError) Error() string { return e.err.Error() }
+func (e *revertError) Unwrap() error { return e.err }
+
+func TestStorageBytecode(t *testing.T) {
+	s := fakeStorage{
+		name: "Test",
+		buckets: []Bucket{
+			fakeFormattedBucket{
+				fakeBucket: fakeBucket{data: []byte("foo"), numFields: 2},
+				format:     BucketFormat{Flavour: FlavourIndexed, OffsetBits: 16},
+			},
+			fakeFormattedBucket{
+				fakeBucket: fakeBucket{data: bytes.Repeat([]byte{0x5b, 0x42}, 40), numFields: 7},
+				format:     BucketFormat{Flavour: FlavourLabelled, LabelBits: 16, FieldSize: 3},
+			},
+			fakeBucket{numFields: 0},
+		},
+	}
+	call := deployStorage(t, s)
+
+	wantBucket := func(i int) compressed {
+		b := s.buckets[i]
+		d, _ := b.Data()
+		if d == nil {
+			d = []byte{}
+		}
+		return compressed{UncompressedSize: big.NewInt(int64(b.UncompressedSize())), Data: d}
+	}
+	hash := func(i int) [32]byte {
+		h, err := BucketHash(s.buckets[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	root, err := StorageRoot(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		args   []any
+		want   any
+	}{
+		{"numBuckets", nil, big.NewInt(3)},
+		{"numFields", nil, big.NewInt(9)},
+		{"numFieldsPerBucket", nil, []*big.Int{big.NewInt(2), big.NewInt(7), big.NewInt(0)}},
+		{"storageRoot", nil, [32]byte(root)},
+		{"storageFormat", nil, [32]byte(StorageFormatOf(s).Descriptor())},
+		{"bucketHash", []any{big.NewInt(1)}, hash(1)},
+		{"bucketFormat", []any{big.NewInt(0)}, [32]byte(BucketFormatOf(s.buckets[0]).Descriptor())},
+		{"bucketFormat", []any{big.NewInt(2)}, [32]byte(BucketFormatOf(s.buckets[2]).Descriptor())},
+		{"supportsInterface", []any{BucketStorageInterfaceID()}, true},
+		{"supportsInterface", []any{ERC165InterfaceID}, true},
+		{"supportsInterface", []any{[4]byte{0xff, 0xff, 0xff, 0xff}}, false},
+		{"getBucket", []any{big.NewInt(0)}, wantBucket(0)},
+		{"getBucket", []any{big.NewInt(1)}, wantBucket(1)},
+		{"getBucket", []any{big.NewInt(2)}, wantBucket(2)},
+		{"getBuckets", []any{[]*big.Int{}}, []compressed{}},
+		{"getBuckets", []any{[]*big.Int{big.NewInt(1), big.NewInt(0), big.NewInt(1), big.NewInt(2)}}, []compressed{wantBucket(1), wantBucket(0), wantBucket(1), wantBucket(2)}},
+	}
+
+	for _, tt := range tests {
+		out, err := call(tt.method, tt.args...)
+		if err != nil {
+			t.Errorf("%s(%v) error %v", tt.method, tt.args, err)
+			continue
+		}
+
+		var got any
+		switch tt.want.(type) {
+		case compressed:
+			got = *abi.ConvertType(out[0], new(compressed)).(*compressed)
+		case []compressed:
+			got = *abi.ConvertType(out[0], new([]compressed)).(*[]compressed)
+		default:
+			got = out[0]
+		}
+
+		if diff := cmp.Diff(tt.want, got, cmp.Comparer(func(a, b *big.Int) bool { return a.Cmp(b) == 0 })); diff != "" {
+			t.Errorf("%s(%v) diff (-want +got):\n%s", tt.method, tt.args, diff)
+		}
+	}
+
+	invalidIndex := bucketStorageABI.Errors["InvalidBucketIndex"].ID
+	for _, method := range []string{"bucketHash", "bucketFormat", "getBucket"} {
+		_, err := call(method, big.NewInt(3))
+		var rev *revertError
+		if !errors.As(err, &rev) || !errors.Is(err, vm.ErrExecutionReverted) || !bytes.Equal(rev.data, invalidIndex[:4]) {
+			t.Errorf("%s(3) got err %v; want revert with InvalidBucketIndex()", method, err)
+		}
+	}
+	if _, err := call("getBuckets", []*big.Int{big.NewInt(0), big.NewInt(3)}); !errors.Is(err, vm.ErrExecutionReverted) {
+		t.Errorf("getBuckets([0, 3]) got err %v; want revert", err)
+	}
+}
+
+func TestWriteStorageArtifacts(t *testing.T) {
+	stores := []fakeStorage{
+		{name: "TestBucketStorage0", buckets: []Bucket{fakeBucket{data: []byte("foo"), numFields: 2}}},
+	}
+	code, err := StorageBytecode(stores[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format   ArtifactFormat
+		wantPath string
+		// creation extracts the creation code from the artifact.
+		creation func(map[string]any) any
+	}{
+		{
+			format:   FoundryArtifacts,
+			wantPath: "out/TestBucketStorage0.sol/TestBucketStorage0.json",
+			creation: func(a map[string]any) any { return a["bytecode"].(map[string]any)["object"] },
+		},
+		{
+			format:   HardhatArtifacts,
+			wantPath: "artifacts/src/gen/storage/TestBucketStorage0.sol/TestBucketStorage0.json",
+			creation: func(a map[string]any) any { return a["bytecode"] },
+		},
+	}
+
+	for _, tt := range tests {
+		mem := new(MemFS)
+		outDir := "out"
+		if tt.format == HardhatArtifacts {
+			outDir = "artifacts"
+		}
+		if _, err := WriteStorageArtifacts(stores, tt.format, "./src/gen/storage", mem, outDir); err != nil {
+			t.Fatalf("WriteStorageArtifacts(…, %d, …) error %v", tt.format, err)
+		}
+
+		raw, ok := mem.Files()[tt.wantPath]
+		if !ok {
+			t.Fatalf("WriteStorageArtifacts(…, %d, …) did not write %q; got %v", tt.format, tt.wantPath, mem.Files())
+		}
+		var a map[string]any
+		if err := json.Unmarshal(raw, &a); err != nil {
+			t.Fatalf("json.Unmarshal([artifact]) error %v", err)
+		}
+		if got, want := tt.creation(a), common.Bytes2Hex(code.Creation); got != "0x"+want {
+			t.Errorf("artifact %q creation code got %v; want 0x%s", tt.wantPath, got, want)
+		}
+	}
+}
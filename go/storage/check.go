@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A FileStatus describes how a file on disk differs from the generated one.
+type FileStatus int
+
+// Possible FileStatus values.
+const (
+	// FileMissing files are generated but do not exist on disk.
+	FileMissing FileStatus = iota + 1
+	// FileStale files exist on disk but differ from the generated ones.
+	FileStale
+	// FileOrphaned files are owned by the checked bundles (see Checker.Own and
+	// Checker.OwnStorages) but are no longer generated, e.g. storage contracts
+	// left behind after the number of storages shrank.
+	FileOrphaned
+)
+
+// String returns a lower-case description of the status.
+func (s FileStatus) String() string {
+	switch s {
+	case FileMissing:
+		return "missing"
+	case FileStale:
+		return "stale"
+	case FileOrphaned:
+		return "orphaned"
+	default:
+		return fmt.Sprintf("FileStatus(%d)", int(s))
+	}
+}
+
+// A FileDiff describes a single file that is not up to date.
+type FileDiff struct {
+	// Path is the slash-separated path of the file.
+	Path   string
+	Status FileStatus
+	// Generated is the expected content of the file; nil for orphaned files.
+	Generated []byte
+	// OnDisk is the current content of the file; nil for missing files.
+	OnDisk []byte
+}
+
+// A CheckReport lists all files that are not up to date, sorted by path.
+type CheckReport struct {
+	Diffs []FileDiff
+}
+
+// UpToDate returns whether all generated files match those on disk.
+func (r *CheckReport) UpToDate() bool {
+	return len(r.Diffs) == 0
+}
+
+// Paths returns the paths of all files with a given status.
+func (r *CheckReport) Paths(s FileStatus) []string {
+	var ps []string
+	for _, d := range r.Diffs {
+		if d.Status == s {
+			ps = append(ps, d.Path)
+		}
+	}
+	return ps
+}
+
+// String returns one line per file that is not up to date, e.g.
+// "stale: gen/FooStorageMapping.sol".
+func (r *CheckReport) String() string {
+	var b strings.Builder
+	for _, d := range r.Diffs {
+		fmt.Fprintf(&b, "%s: %s\n", d.Status, d.Path)
+	}
+	return b.String()
+}
+
+// Err returns nil if all files are up to date, and an error listing the files
+// that are not otherwise.
+func (r *CheckReport) Err() error {
+	if r.UpToDate() {
+		return nil
+	}
+	return fmt.Errorf("generated files not up to date:\n%s", r.String())
+}
+
+// RemoveOrphaned deletes all orphaned files from disk. Only files owned by the
+// checked bundles are ever reported as orphaned.
+func (r *CheckReport) RemoveOrphaned() error {
+	for _, p := range r.Paths(FileOrphaned) {
+		if err := os.Remove(filepath.FromSlash(p)); err != nil {
+			return fmt.Errorf("os.Remove(%q): %w", p, err)
+		}
+	}
+	return nil
+}
+
+// A Checker is a WritableFS that renders files in memory instead of writing
+// them, allowing them to be compared against the files on disk. Set it as the
+// GeneratorConfig.Output (or pass it to the *ToFile functions), run the
+// generation as usual and call Check().
+type Checker struct {
+	// Format optionally post-processes the generated Solidity files before
+	// they are compared, e.g. FormatSolSources if the files on disk were
+	// formatted with FormatSol. If nil, the files are compared as generated.
+	Format Formatter
+
+	mem MemFS
+	// owned are the explicitly owned paths and ownedStorages the patterns of
+	// owned storage contracts, keyed by directory.
+	owned         map[string]bool
+	ownedStorages map[string][]*regexp.Regexp
+}
+
+var _ WritableFS = (*Checker)(nil)
+
+// Create returns a writer collecting the contents of the file in memory.
+func (c *Checker) Create(p string) (io.WriteCloser, error) {
+	return c.mem.Create(p)
+}
+
+// Own marks the given slash-separated paths as owned by the checked bundles,
+// such that they are reported as orphaned if they exist on disk but are not
+// generated.
+func (c *Checker) Own(paths ...string) {
+	if c.owned == nil {
+		c.owned = make(map[string]bool)
+	}
+	for _, p := range paths {
+		c.owned[path.Clean(p)] = true
+	}
+}
+
+// OwnStorages marks all storage contracts of a bundle, i.e. files named after
+// cfg.StorageName(base, n) for any n in the storage subdirectory of outputDir,
+// as owned (see Own). Output directories are commonly shared between bundles,
+// so only owned files are considered for orphan detection.
+func (c *Checker) OwnStorages(cfg *GeneratorConfig, base, outputDir string) {
+	if c.ownedStorages == nil {
+		c.ownedStorages = make(map[string][]*regexp.Regexp)
+	}
+	dir := path.Join(filepath.ToSlash(outputDir), "storage")
+	c.ownedStorages[dir] = append(c.ownedStorages[dir], storageNamePattern(cfg, base))
+}
+
+// storageNamePattern returns a regular expression matching the file names of
+// the storage contracts of a bundle.
+func storageNamePattern(cfg *GeneratorConfig, base string) *regexp.Regexp {
+	pattern := cfg.withDefaults().Naming.Storage
+
+	var re strings.Builder
+	re.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 's':
+			re.WriteString(regexp.QuoteMeta(base))
+		case 'd':
+			re.WriteString("[0-9]+")
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	re.WriteString(`\.sol$`)
+	return regexp.MustCompile(re.String())
+}
+
+// owns returns whether a file is owned by the checked bundles.
+func (c *Checker) owns(p string) bool {
+	if c.owned[p] {
+		return true
+	}
+	for _, re := range c.ownedStorages[path.Dir(p)] {
+		if re.MatchString(path.Base(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check compares all generated files, optionally post-processed by Format,
+// against the files on disk. Besides missing and stale files, files owned by
+// the checked bundles (see Own and OwnStorages) are reported as orphaned if
+// they exist on disk but are no longer generated. Files of other bundles
+// sharing the same directories are ignored.
+func (c *Checker) Check() (*CheckReport, error) {
+	files := c.mem.Files()
+
+	if c.Format != nil {
+		sol := make(map[string][]byte)
+		for p, b := range files {
+			if path.Ext(p) == ".sol" {
+				sol[p] = b
+			}
+		}
+		formatted, err := c.Format(sol)
+		if err != nil {
+			return nil, fmt.Errorf("formatting: %w", err)
+		}
+		for p := range sol {
+			b, ok := formatted[p]
+			if !ok {
+				return nil, fmt.Errorf("formatter dropped %q", p)
+			}
+			files[p] = b
+		}
+	}
+
+	r := new(CheckReport)
+	for p, want := range files {
+		got, err := os.ReadFile(filepath.FromSlash(p))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			r.Diffs = append(r.Diffs, FileDiff{Path: p, Status: FileMissing, Generated: want})
+		case err != nil:
+			return nil, fmt.Errorf("os.ReadFile(%q): %w", p, err)
+		case !bytes.Equal(got, want):
+			r.Diffs = append(r.Diffs, FileDiff{Path: p, Status: FileStale, Generated: want, OnDisk: got})
+		}
+	}
+
+	dirs := make(map[string]bool)
+	for p := range c.owned {
+		dirs[path.Dir(p)] = true
+	}
+	for dir := range c.ownedStorages {
+		dirs[dir] = true
+	}
+	for dir := range dirs {
+		entries, err := os.ReadDir(filepath.FromSlash(dir))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadDir(%q): %w", dir, err)
+		}
+
+		for _, e := range entries {
+			p := path.Join(dir, e.Name())
+			if !e.Type().IsRegular() || !c.owns(p) {
+				continue
+			}
+			if _, ok := files[p]; ok {
+				continue
+			}
+			got, err := os.ReadFile(filepath.FromSlash(p))
+			if err != nil {
+				return nil, fmt.Errorf("os.ReadFile(%q): %w", p, err)
+			}
+			r.Diffs = append(r.Diffs, FileDiff{Path: p, Status: FileOrphaned, OnDisk: got})
+		}
+	}
+
+	sort.Slice(r.Diffs, func(i, j int) bool {
+		return r.Diffs[i].Path < r.Diffs[j].Path
+	})
+	return r, nil
+}
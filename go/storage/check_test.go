@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChecker(t *testing.T) {
+	dir := filepath.ToSlash(filepath.Join(t.TempDir(), "gen"))
+
+	stores := []fakeStorage{
+		{name: "TestBucketStorage0", buckets: []Bucket{fakeBucket{numFields: 2}}},
+		{name: "TestBucketStorage1", buckets: []Bucket{fakeBucket{numFields: 1}}},
+	}
+	groups := []fakeGroup{{"FOO", 2}, {"BAR", 1}}
+
+	check := func(t *testing.T, stores []fakeStorage) *CheckReport {
+		t.Helper()
+		c := new(Checker)
+		cfg := DefaultGeneratorConfig()
+		cfg.Output = c
+		c.OwnStorages(cfg, "Test", dir)
+		if _, err := WriteGroupStorage("Test", groups[:len(stores)], stores, cfg, dir); err != nil {
+			t.Fatalf("WriteGroupStorage(…) error %v", err)
+		}
+		r, err := c.Check()
+		if err != nil {
+			t.Fatalf("%T.Check() error %v", c, err)
+		}
+		return r
+	}
+
+	if _, err := WriteGroupStorage("Test", groups, stores, nil, dir); err != nil {
+		t.Fatalf("WriteGroupStorage(…) error %v", err)
+	}
+	if r := check(t, stores); !r.UpToDate() {
+		t.Fatalf("%T.Check() got %v; want up to date", r, r)
+	}
+
+	if err := os.WriteFile(path.Join(dir, "TestStorageMapping.sol"), []byte("outdated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path.Join(dir, "TestStorageManager.sol")); err != nil {
+		t.Fatal(err)
+	}
+
+	r := check(t, stores[:1])
+
+	tests := []struct {
+		status FileStatus
+		want   []string
+	}{
+		{FileMissing, []string{path.Join(dir, "TestStorageManager.sol")}},
+		{FileStale, []string{
+			path.Join(dir, "TestStorageDeployer.sol"),
+			path.Join(dir, "TestStorageMapping.sol"),
+		}},
+		{FileOrphaned, []string{path.Join(dir, "storage/TestBucketStorage1.sol")}},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, r.Paths(tt.status)); diff != "" {
+			t.Errorf("%T.Paths(%v) diff (-want +got):\n%s", r, tt.status, diff)
+		}
+	}
+	if r.Err() == nil {
+		t.Errorf("%T.Err() got nil; want error", r)
+	}
+
+	if err := r.RemoveOrphaned(); err != nil {
+		t.Fatalf("%T.RemoveOrphaned() error %v", r, err)
+	}
+	orphan := path.Join(dir, "storage/TestBucketStorage1.sol")
+	if _, err := os.Stat(orphan); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("os.Stat(%q) after %T.RemoveOrphaned() got err %v; want %v", orphan, r, err, fs.ErrNotExist)
+	}
+}
+
+func TestCheckerSharedOutputDir(t *testing.T) {
+	dir := filepath.ToSlash(filepath.Join(t.TempDir(), "gen"))
+
+	groups := []fakeGroup{{"FOO", 1}}
+	bundles := map[string][]fakeStorage{
+		"Layer": {
+			{name: "LayerBucketStorage0", buckets: []Bucket{fakeBucket{numFields: 1}}},
+			{name: "LayerBucketStorage1", buckets: []Bucket{fakeBucket{numFields: 1}}},
+		},
+		"Trait": {{name: "TraitBucketStorage0", buckets: []Bucket{fakeBucket{numFields: 1}}}},
+	}
+	for name, stores := range bundles {
+		if _, err := WriteGroupStorage(name, groups, stores, nil, dir); err != nil {
+			t.Fatalf("WriteGroupStorage(%q, …) error %v", name, err)
+		}
+	}
+	// Written on its own, like the table mapping of test/indexed.
+	extra := path.Join(dir, "GroupTableStorageMapping.sol")
+	if err := os.WriteFile(extra, []byte("table"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Checking only "Layer", which shrank to a single storage, must neither
+	// report the files of "Trait" nor the extra file.
+	c := new(Checker)
+	cfg := DefaultGeneratorConfig()
+	cfg.Output = c
+	c.OwnStorages(cfg, "Layer", dir)
+	if _, err := WriteGroupStorage("Layer", groups, bundles["Layer"][:1], cfg, dir); err != nil {
+		t.Fatalf("WriteGroupStorage(%q, …) error %v", "Layer", err)
+	}
+	r, err := c.Check()
+	if err != nil {
+		t.Fatalf("%T.Check() error %v", c, err)
+	}
+
+	want := []string{path.Join(dir, "storage/LayerBucketStorage1.sol")}
+	if diff := cmp.Diff(want, r.Paths(FileOrphaned)); diff != "" {
+		t.Errorf("%T.Paths(%v) diff (-want +got):\n%s", r, FileOrphaned, diff)
+	}
+	if err := r.RemoveOrphaned(); err != nil {
+		t.Fatalf("%T.RemoveOrphaned() error %v", r, err)
+	}
+	for _, p := range []string{extra, path.Join(dir, "storage/TraitBucketStorage0.sol"), path.Join(dir, "TraitStorageMapping.sol")} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("os.Stat(%q) after %T.RemoveOrphaned() error %v; want nil", p, r, err)
+		}
+	}
+
+	// Explicitly owned files are reported as well.
+	c.Own(extra)
+	r, err = c.Check()
+	if err != nil {
+		t.Fatalf("%T.Check() error %v", c, err)
+	}
+	if diff := cmp.Diff([]string{extra}, r.Paths(FileOrphaned)); diff != "" {
+		t.Errorf("%T.Paths(%v) after %T.Own(%q) diff (-want +got):\n%s", r, FileOrphaned, c, extra, diff)
+	}
+}
@@ -5,12 +5,9 @@ package storage
 import (
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
-
-	_ "embed"
 )
 
 var (
@@ -21,12 +18,45 @@ var (
 		"numFields": func(s interface{ NumFields() int }) int {
 			return s.NumFields()
 		},
-		"numFieldsPerBucketHex": func(s BucketStorage) string {
-			nums := []byte{}
+		"numFieldsPerBucket": func(s BucketStorage) (PackedTable, error) {
+			var max int
 			for _, b := range s.Buckets() {
-				nums = append(nums, uint8(b.NumFields()))
+				if n := b.NumFields(); n > max {
+					max = n
+				}
+			}
+			n, err := fieldCountBytes(max)
+			if err != nil {
+				return PackedTable{}, fmt.Errorf("fields per bucket in %q: %w", s.Name(), err)
+			}
+			return packNumFieldsPerBucket(s, n)
+		},
+		"bucketHashesHex": func(s BucketStorage) (string, error) {
+			hs, err := BucketHashes(s)
+			if err != nil {
+				return "", err
 			}
-			return fmt.Sprintf(`hex"%x"`, nums)
+			var b []byte
+			for _, h := range hs {
+				b = append(b, h.Bytes()...)
+			}
+			return fmt.Sprintf(`hex"%x"`, b), nil
+		},
+		"storageRoot": func(s BucketStorage) (string, error) {
+			h, err := StorageRoot(s)
+			if err != nil {
+				return "", err
+			}
+			return h.Hex(), nil
+		},
+		"bucketFormats": func(s BucketStorage) BucketFormats {
+			return newBucketFormats(s)
+		},
+		"storageFormat": func(s BucketStorage) string {
+			return StorageFormatOf(s).Descriptor().Hex()
+		},
+		"libraryVersion": func() uint8 {
+			return LibraryVersion
 		},
 		"printUnlessFirstCall": func(s string) func() string {
 			i := -1
@@ -39,28 +69,40 @@ var (
 			}
 		},
 		"toLower": strings.ToLower,
-		"numBits": func(x int) int {
-			if x > 255 {
-				return 16
-			}
-			return 8
+		"mul": func(a, b int) int {
+			return a * b
 		},
 	}
+)
 
-	//go:embed templates/bucket-storage.go.tmpl
-	rawBucketStorageTmpl string
+// maxFieldCountBytes is the maximum width of the integers representing field
+// counts in the generated contracts.
+const maxFieldCountBytes = 4
 
-	bucketStorageTmpl = template.Must(
-		template.New("bucket-storage").Funcs(tmplFuncsCommon).Parse(rawBucketStorageTmpl),
-	)
+// fieldCountBytes returns the number of bytes needed to represent field counts
+// up to a given maximum.
+func fieldCountBytes(max int) (int, error) {
+	n := bytesFor(bitsFor(max))
+	if n > maxFieldCountBytes {
+		return 0, fmt.Errorf("field count %d exceeds the supported %d bits", max, 8*maxFieldCountBytes)
+	}
+	return n, nil
+}
 
-	//go:embed templates/storage-deployer.go.tmpl
-	rawDeployerTmpl string
+// packNumFieldsPerBucket packs the number of fields in each bucket of a
+// storage with a given number of bytes per entry.
+func packNumFieldsPerBucket(s BucketStorage, entryBytes int) (PackedTable, error) {
+	var nums []uint64
+	for _, b := range s.Buckets() {
+		nums = append(nums, uint64(b.NumFields()))
+	}
 
-	deployerTmpl = template.Must(
-		template.New("storage-deployer").Funcs(tmplFuncsCommon).Parse(rawDeployerTmpl),
-	)
-)
+	t, err := newPackedTable(entryBytes, nums)
+	if err != nil {
+		return PackedTable{}, fmt.Errorf("packing fields per bucket in %q: %w", s.Name(), err)
+	}
+	return t, nil
+}
 
 // A Field represents arbitrary data that can be represented in a binary format.
 type Field interface {
@@ -83,22 +125,31 @@ type BucketStorage interface {
 }
 
 // WriteBucketStorage writes the storage contract file for a given BucketStorage.
-func WriteBucketStorage(s BucketStorage, w io.Writer) error {
-	return bucketStorageTmpl.Execute(w, struct{ Store BucketStorage }{s})
+func WriteBucketStorage(s BucketStorage, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.executeTemplate(BucketStorageTemplate, w, BucketStorageData{
+		Config: cfg,
+		Store:  s,
+	})
 }
 
 // WriteStorageDeployer writes a helper contract to deploy a set of BucketStorage contracts located at storagePath.
-func WriteStorageDeployer[S BucketStorage](name string, storagePath string, stores []S, w io.Writer) error {
-	return deployerTmpl.Execute(w,
-		struct {
-			Name        string
-			StoragePath string
-			Stores      []BucketStorage
-		}{
-			Name:        name,
-			StoragePath: storagePath,
-			Stores:      convertStorages(stores),
-		})
+func WriteStorageDeployer[S BucketStorage](name string, storagePath string, stores []S, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.executeTemplate(StorageDeployerTemplate, w, StorageDeployerData{
+		Config:      cfg,
+		Name:        name,
+		StoragePath: storagePath,
+		Stores:      convertStorages(stores),
+	})
 }
 
 func convertStorages[S BucketStorage](s []S) []BucketStorage {
@@ -120,43 +171,21 @@ func addTemplateFuncs(m1, m2 template.FuncMap) template.FuncMap {
 	return ret
 }
 
-func createFile(outputDir, name string) (*os.File, error) {
-	err := os.MkdirAll(outputDir, 0755)
-	if err != nil {
-		return nil, fmt.Errorf("os.Mkdir(%q): %w", outputDir, err)
-	}
-
-	path := filepath.Join(outputDir, fmt.Sprintf("%s.sol", name))
-	f, err := os.Create(path)
-	if err != nil {
-		return nil, fmt.Errorf("os.Create(%s): %w", path, err)
-	}
-
-	return f, nil
-}
-
-// A fileGenerator writes files and tracks the paths of the files it creates.
+// A fileGenerator writes files to a WritableFS and tracks the paths of the
+// files it creates.
 type fileGenerator struct {
+	fsys    WritableFS
 	created []string
 }
 
 // writeSolFile creates a new file <dir>/<name>.sol and passes it to the write()
 // callback for generation. Any error returned by write() will be propagated.
-func (g *fileGenerator) writeSolFile(dir, name string, write func(*os.File) error) (retErr error) {
-	f, err := createFile(dir, name)
-	if err != nil {
-		return fmt.Errorf("createFile(%q, %q): %v", dir, name, err)
-	}
-	defer func() {
-		if err := f.Close(); retErr == nil {
-			retErr = err
-		}
-	}()
-
-	if err := write(f); err != nil {
-		return err
+func (g *fileGenerator) writeSolFile(dir, name string, write func(io.Writer) error) error {
+	path := filepath.Join(dir, name+".sol")
+	if err := writeFile(g.fsys, path, write); err != nil {
+		return fmt.Errorf("writing %q: %w", path, err)
 	}
-	g.created = append(g.created, f.Name())
+	g.created = append(g.created, path)
 	return nil
 }
 
@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// GeneratorConfig controls the conventions of the generated Solidity code,
+// allowing it to match those of the surrounding codebase without
+// post-processing.
+// A nil *GeneratorConfig is equivalent to DefaultGeneratorConfig(). Empty
+// fields fall back to their defaults, except for License and Copyright, which
+// are omitted from the generated files if empty.
+type GeneratorConfig struct {
+	// Pragma is the compiler version constraint, e.g. "^0.8.16".
+	Pragma string
+	// License is the SPDX license identifier, e.g. "MIT".
+	License string
+	// Copyright is the copyright notice following "Copyright ", e.g.
+	// "2022 PROOF Holdings Inc".
+	Copyright string
+	// SolidifyImportPrefix is the remapping under which the solidify contracts
+	// are imported.
+	SolidifyImportPrefix string
+	// OpenZeppelinImportPrefix is the remapping under which the OpenZeppelin
+	// contracts are imported.
+	OpenZeppelinImportPrefix string
+	// ForgeStdImportPrefix is the remapping under which forge-std is imported
+	// by the generated deployment scripts.
+	ForgeStdImportPrefix string
+	// Naming defines the names of the generated contracts and libraries.
+	Naming Naming
+	// Target is the chain the storages are deployed to, defining the size
+	// limits enforced when packing and the gas schedule used for cost
+	// estimates. Defaults to ProfileMainnet.
+	Target *ChainProfile
+
+	// Templates optionally replaces the default templates. Files are matched
+	// by the names of the default templates (e.g. BucketStorageTemplate) and
+	// missing ones fall back to the defaults. Unknown template names are
+	// rejected.
+	Templates fs.FS
+	// Funcs are additional functions available in all templates. These take
+	// precedence over the default functions with the same names.
+	Funcs template.FuncMap
+
+	// Output is the filesystem that the convenience wrappers writing multiple
+	// contracts (e.g. WriteGroupStorage) write to. If nil, files are written to
+	// disk.
+	Output WritableFS
+
+	// templates are the parsed templates of a resolved config.
+	templates map[string]*template.Template
+}
+
+// Naming defines the names of generated contracts and libraries as fmt
+// patterns. All patterns receive the base name of the bundle, e.g. "Layer", as
+// their first argument.
+type Naming struct {
+	// Storage names the BucketStorage contracts. The index of the storage in the
+	// bundle is passed as second argument.
+	Storage string
+	// Deployer names the library deploying the storage contracts.
+	Deployer string
+	// Mapping names the storage mapping library.
+	Mapping string
+	// Manager names the storage manager contract.
+	Manager string
+	// Type names the enum of the groups in a storage mapping.
+	Type string
+	// Addresses names the library resolving storage IDs to precomputed
+	// addresses.
+	Addresses string
+	// Verifier names the library verifying fields supplied in calldata against
+	// a Merkle root.
+	Verifier string
+	// Script names the forge scripts deploying a bundle in batches. The index
+	// of the batch is passed as second argument.
+	Script string
+}
+
+// DefaultGeneratorConfig returns the conventions used throughout solidify.
+func DefaultGeneratorConfig() *GeneratorConfig {
+	return &GeneratorConfig{
+		Pragma:                   "^0.8.16",
+		License:                  "MIT",
+		Copyright:                "2022 PROOF Holdings Inc",
+		SolidifyImportPrefix:     "solidify-contracts",
+		OpenZeppelinImportPrefix: "openzeppelin-contracts",
+		ForgeStdImportPrefix:     "forge-std",
+		Naming: Naming{
+			Storage:   "%sBucketStorage%d",
+			Deployer:  "%sStorageDeployer",
+			Mapping:   "%sStorageMapping",
+			Manager:   "%sStorageManager",
+			Type:      "%sType",
+			Addresses: "%sStorageAddresses",
+			Verifier:  "%sFieldVerifier",
+			Script:    "Deploy%sStorageBatch%d",
+		},
+	}
+}
+
+// withDefaults returns a copy of the config with empty fields set to their
+// defaults.
+func (c *GeneratorConfig) withDefaults() *GeneratorConfig {
+	d := DefaultGeneratorConfig()
+	if c == nil {
+		return d
+	}
+
+	r := *c
+	orDefault := func(s *string, def string) {
+		if *s == "" {
+			*s = def
+		}
+	}
+	orDefault(&r.Pragma, d.Pragma)
+	orDefault(&r.SolidifyImportPrefix, d.SolidifyImportPrefix)
+	orDefault(&r.OpenZeppelinImportPrefix, d.OpenZeppelinImportPrefix)
+	orDefault(&r.ForgeStdImportPrefix, d.ForgeStdImportPrefix)
+	orDefault(&r.Naming.Storage, d.Naming.Storage)
+	orDefault(&r.Naming.Deployer, d.Naming.Deployer)
+	orDefault(&r.Naming.Mapping, d.Naming.Mapping)
+	orDefault(&r.Naming.Manager, d.Naming.Manager)
+	orDefault(&r.Naming.Type, d.Naming.Type)
+	orDefault(&r.Naming.Addresses, d.Naming.Addresses)
+	orDefault(&r.Naming.Verifier, d.Naming.Verifier)
+	orDefault(&r.Naming.Script, d.Naming.Script)
+	return &r
+}
+
+var solidityIdentifier = regexp.MustCompile(`^[a-zA-Z$_][a-zA-Z0-9$_]*$`)
+
+// Validate checks that the config results in valid Solidity and that all
+// template overrides can be parsed.
+func (c *GeneratorConfig) Validate() error {
+	if err := c.validateConventions(); err != nil {
+		return err
+	}
+	if err := c.TargetProfile().Validate(); err != nil {
+		return err
+	}
+	if _, err := c.withDefaults().loadTemplates(); err != nil {
+		return fmt.Errorf("loading templates: %w", err)
+	}
+	return nil
+}
+
+// validateConventions checks the header, import and naming settings.
+func (c *GeneratorConfig) validateConventions() error {
+	r := c.withDefaults()
+
+	if strings.ContainsAny(r.Pragma, ";\n") {
+		return fmt.Errorf("invalid pragma %q", r.Pragma)
+	}
+	for _, s := range []string{r.License, r.Copyright} {
+		if strings.Contains(s, "\n") {
+			return fmt.Errorf("header line %q must not contain line breaks", s)
+		}
+	}
+	for _, p := range []string{r.SolidifyImportPrefix, r.OpenZeppelinImportPrefix, r.ForgeStdImportPrefix} {
+		if strings.ContainsAny(p, "\"\n") {
+			return fmt.Errorf("invalid import prefix %q", p)
+		}
+	}
+
+	// The index patterns receive the index as second argument, which would
+	// otherwise be rendered as `%!(EXTRA …)` or missing.
+	indexed := map[string]string{
+		"storage": r.Naming.Storage,
+		"script":  r.Naming.Script,
+	}
+	for kind, p := range indexed {
+		if n := strings.Count(strings.ReplaceAll(p, "%%", ""), "%d"); n != 1 {
+			return fmt.Errorf("%s naming %q must contain exactly one %%d for the index, got %d", kind, p, n)
+		}
+	}
+
+	names := map[string]string{
+		"storage":   r.StorageName("X", 0),
+		"deployer":  r.DeployerName("X"),
+		"mapping":   r.MappingName("X"),
+		"manager":   r.ManagerName("X"),
+		"type":      r.TypeName("X"),
+		"addresses": r.AddressesName("X"),
+		"verifier":  r.VerifierName("X"),
+		"script":    r.ScriptName("X", 0),
+	}
+	for kind, n := range names {
+		if !solidityIdentifier.MatchString(n) {
+			return fmt.Errorf("%s naming results in invalid identifier %q", kind, n)
+		}
+	}
+
+	return nil
+}
+
+// resolveConfig validates a config, fills in the defaults and loads the
+// templates. Resolved configs are returned as is.
+func resolveConfig(c *GeneratorConfig) (*GeneratorConfig, error) {
+	if c != nil && c.templates != nil {
+		return c, nil
+	}
+	if err := c.validateConventions(); err != nil {
+		return nil, fmt.Errorf("%T.Validate(): %w", c, err)
+	}
+
+	r := c.withDefaults()
+	ts, err := r.loadTemplates()
+	if err != nil {
+		return nil, fmt.Errorf("loading templates: %w", err)
+	}
+	r.templates = ts
+	return r, nil
+}
+
+// loadTemplates parses the templates of the config, reusing the default ones
+// if there are no overrides.
+func (c *GeneratorConfig) loadTemplates() (map[string]*template.Template, error) {
+	if c.Templates == nil && len(c.Funcs) == 0 {
+		return defaultTemplates, nil
+	}
+	return loadTemplates(c.Templates, c.Funcs)
+}
+
+// TargetProfile returns the profile of the target chain.
+func (c *GeneratorConfig) TargetProfile() *ChainProfile {
+	if c == nil || c.Target == nil {
+		return ProfileMainnet
+	}
+	return c.Target
+}
+
+// StorageName returns the name of the storage contract with a given index in a
+// bundle.
+func (c *GeneratorConfig) StorageName(base string, idx int) string {
+	return fmt.Sprintf(c.withDefaults().Naming.Storage, base, idx)
+}
+
+// DeployerName returns the name of the deployer library of a bundle.
+func (c *GeneratorConfig) DeployerName(base string) string {
+	return fmt.Sprintf(c.withDefaults().Naming.Deployer, base)
+}
+
+// MappingName returns the name of the storage mapping library of a bundle.
+func (c *GeneratorConfig) MappingName(base string) string {
+	return fmt.Sprintf(c.withDefaults().Naming.Mapping, base)
+}
+
+// ManagerName returns the name of the storage manager contract of a bundle.
+func (c *GeneratorConfig) ManagerName(base string) string {
+	return fmt.Sprintf(c.withDefaults().Naming.Manager, base)
+}
+
+// TypeName returns the name of the group enum of a bundle.
+func (c *GeneratorConfig) TypeName(base string) string {
+	return fmt.Sprintf(c.withDefaults().Naming.Type, base)
+}
+
+// AddressesName returns the name of the storage addresses library of a bundle.
+func (c *GeneratorConfig) AddressesName(base string) string {
+	return fmt.Sprintf(c.withDefaults().Naming.Addresses, base)
+}
+
+// VerifierName returns the name of the Merkle field verifier library of a
+// bundle.
+func (c *GeneratorConfig) VerifierName(base string) string {
+	return fmt.Sprintf(c.withDefaults().Naming.Verifier, base)
+}
+
+// ScriptName returns the name of the forge script deploying a given batch of a
+// bundle.
+func (c *GeneratorConfig) ScriptName(base string, batch int) string {
+	return fmt.Sprintf(c.withDefaults().Naming.Script, base, batch)
+}
+
+// SolidifyImport returns the import path of a given solidify contract file.
+func (c *GeneratorConfig) SolidifyImport(file string) string {
+	return joinImport(c.withDefaults().SolidifyImportPrefix, file)
+}
+
+// OpenZeppelinImport returns the import path of a given OpenZeppelin contract
+// file.
+func (c *GeneratorConfig) OpenZeppelinImport(file string) string {
+	return joinImport(c.withDefaults().OpenZeppelinImportPrefix, file)
+}
+
+// ForgeStdImport returns the import path of a given forge-std file.
+func (c *GeneratorConfig) ForgeStdImport(file string) string {
+	return joinImport(c.withDefaults().ForgeStdImportPrefix, file)
+}
+
+// joinImport joins an import prefix and a file path. In contrast to path.Join,
+// relative prefixes like "./" are retained.
+func joinImport(prefix, file string) string {
+	return strings.TrimSuffix(prefix, "/") + "/" + file
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGeneratorConfig(t *testing.T) {
+	cfg := &GeneratorConfig{
+		Pragma:               "0.8.17",
+		License:              "Apache-2.0",
+		SolidifyImportPrefix: "@acme/solidify/",
+		Naming: Naming{
+			Storage:  "%sChunk%d",
+			Deployer: "Deploy%s",
+			Mapping:  "%sLocator",
+			Manager:  "%sAssets",
+			Type:     "%sKind",
+			Verifier: "%sProver",
+		},
+	}
+
+	stores := []fakeStorage{
+		{name: cfg.StorageName("Test", 0), buckets: []Bucket{fakeBucket{numFields: 2}}},
+	}
+	groups := []fakeGroup{{"FOO", 2}}
+
+	tests := []struct {
+		name         string
+		write        func(*bytes.Buffer) error
+		wantContains []string
+		wantMissing  []string
+	}{
+		{
+			name: "BucketStorage",
+			write: func(buf *bytes.Buffer) error {
+				return WriteBucketStorage(stores[0], cfg, buf)
+			},
+			wantContains: []string{
+				"// SPDX-License-Identifier: Apache-2.0\n// GENERATED CODE - DO NOT EDIT\npragma solidity 0.8.17;\n",
+				`from "@acme/solidify/IBucketStorage.sol";`,
+				`from "openzeppelin-contracts/utils/introspection/IERC165.sol";`,
+				"contract TestChunk0 is IBucketStorage {",
+			},
+			wantMissing: []string{"Copyright"},
+		},
+		{
+			name: "StorageDeployer",
+			write: func(buf *bytes.Buffer) error {
+				return WriteStorageDeployer("Test", "./storage", stores, cfg, buf)
+			},
+			wantContains: []string{
+				`import "./storage/TestChunk0.sol";`,
+				"library DeployTest {",
+			},
+		},
+		{
+			name: "SequentialStorageMapping",
+			write: func(buf *bytes.Buffer) error {
+				return WriteSequentialStorageMapping("Test", groups, stores, cfg, buf)
+			},
+			wantContains: []string{
+				"enum TestKind {",
+				"library TestLocator {",
+				"function locate(TestKind testType, uint256 index)",
+			},
+		},
+		{
+			name: "StorageManager",
+			write: func(buf *bytes.Buffer) error {
+				return WriteStorageManager("Test", groups, cfg, buf)
+			},
+			wantContains: []string{
+				`import { DeployTest } from "./DeployTest.sol";`,
+				`import { TestLocator, TestKind } from "./TestLocator.sol";`,
+				"contract TestAssets {",
+				"DeployTest.Bundle private _bundle;",
+			},
+		},
+		{
+			name: "MerkleFieldVerifier",
+			write: func(buf *bytes.Buffer) error {
+				mt, err := NewFieldsMerkleTree(groups, [][]Field{{fakeField("a"), fakeField("b")}})
+				if err != nil {
+					return err
+				}
+				return WriteMerkleFieldVerifier("Test", groups, mt, cfg, buf)
+			},
+			wantContains: []string{
+				`import {MerkleFieldLib} from "@acme/solidify/MerkleFieldLib.sol";`,
+				"enum TestKind {",
+				"library TestProver {",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf); err != nil {
+				t.Fatalf("Write%s(…) error %v", tt.name, err)
+			}
+
+			got := buf.String()
+			for _, want := range tt.wantContains {
+				if !strings.Contains(got, want) {
+					t.Errorf("Write%s(…) does not contain %q", tt.name, want)
+				}
+			}
+			for _, miss := range tt.wantMissing {
+				if strings.Contains(got, miss) {
+					t.Errorf("Write%s(…) contains %q", tt.name, miss)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratorConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *GeneratorConfig
+		wantErr bool
+		// wantContains, if not empty, must be contained in the error.
+		wantContains string
+	}{
+		{
+			name: "Nil",
+			cfg:  nil,
+		},
+		{
+			name: "Empty",
+			cfg:  &GeneratorConfig{},
+		},
+		{
+			name:    "Pragma injection",
+			cfg:     &GeneratorConfig{Pragma: "^0.8.0; contract X {}"},
+			wantErr: true,
+		},
+		{
+			name:    "Multi-line copyright",
+			cfg:     &GeneratorConfig{Copyright: "Foo\nBar"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid identifier",
+			cfg:     &GeneratorConfig{Naming: Naming{Mapping: "%s-Mapping"}},
+			wantErr: true,
+		},
+		{
+			name:         "Storage naming without index",
+			cfg:          &GeneratorConfig{Naming: Naming{Storage: "%sStorage"}},
+			wantErr:      true,
+			wantContains: `storage naming "%sStorage" must contain exactly one %d`,
+		},
+		{
+			name:         "Script naming with two indices",
+			cfg:          &GeneratorConfig{Naming: Naming{Script: "Deploy%s%dBatch%d"}},
+			wantErr:      true,
+			wantContains: "got 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("%+v.Validate() error %v, wantErr %t", tt.cfg, err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("%+v.Validate() got err %v; want containing %q", tt.cfg, err, tt.wantContains)
+			}
+		})
+	}
+}
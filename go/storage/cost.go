@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// A StorageCost is the estimated gas of deploying a single storage contract,
+// as assembled by StorageBytecode, broken down by source.
+type StorageCost struct {
+	Name string `json:"name"`
+	// CodeBytes is the size of the deployed code.
+	CodeBytes int `json:"codeBytes"`
+	// InitCodeBytes is the size of the creation code.
+	InitCodeBytes int `json:"initCodeBytes"`
+	// Create is the base cost of the CREATE operation.
+	Create uint64 `json:"create"`
+	// CodeDeposit is the cost of storing the deployed code, charged per byte.
+	CodeDeposit uint64 `json:"codeDeposit"`
+	// InitCode is the cost of the creation code being embedded in the
+	// creation code of the contract calling the deployer library, which is
+	// charged as transaction data per byte, plus the EIP-3860 charge per word
+	// of both that deployment and the CREATE of the storage.
+	InitCode uint64 `json:"initCode"`
+	// Execution is the gas used by executing the creation code, excluding
+	// the code deposit.
+	Execution uint64 `json:"execution"`
+}
+
+// Gas returns the total gas of the deployment.
+func (c StorageCost) Gas() uint64 {
+	return c.Create + c.CodeDeposit + c.InitCode + c.Execution
+}
+
+// A DeploymentCost is the estimated gas of deploying a bundle of storages with
+// the library written by WriteStorageDeployer.
+type DeploymentCost struct {
+	Name     string
+	Storages []StorageCost
+	// Deployer is the overhead of deploying all storages from the constructor
+	// of a single contract via the deployer library: the transaction base
+	// cost, copying the embedded creation codes to memory and the expansion of
+	// the memory holding them.
+	Deployer uint64
+}
+
+// Gas returns the total gas of deploying the bundle.
+func (c *DeploymentCost) Gas() uint64 {
+	g := c.Deployer
+	for _, s := range c.Storages {
+		g += s.Gas()
+	}
+	return g
+}
+
+// Wei returns the cost of the deployment at a given gas price in wei, which is
+// zero for a nil price.
+func (c *DeploymentCost) Wei(gasPrice *big.Int) *big.Int {
+	if gasPrice == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(c.Gas()), gasPrice)
+}
+
+// EstimateDeploymentCost estimates the gas of deploying the storages of a
+// bundle via the deployer library on the target chain of the config, which may
+// be nil to use the defaults. The creation code of each storage is checked
+// against the size limits of the chain and executed in an in-memory EVM, while
+// the remaining costs are computed from the gas schedule of the chain.
+//
+// The functions of the deployer library are internal, so every `new
+// <Storage>()` embeds the creation code of the storage in the contract calling
+// them. The estimate assumes that this contract deploys the bundle from its
+// constructor, such that the creation codes are only carried as transaction
+// data. Calling the deployer from runtime code instead additionally stores the
+// creation codes as code of the caller, charged at CodeDepositPerByte.
+//
+// The creation codes are assembled by StorageBytecode, which differs slightly
+// from the solc output of the generated contracts, and the estimate excludes
+// the remaining code of the calling contract as well as the storage manager.
+// It is therefore an approximate lower bound of the gas used in practice.
+func EstimateDeploymentCost[S BucketStorage](name string, stores []S, cfg *GeneratorConfig) (*DeploymentCost, error) {
+	if len(stores) == 0 {
+		return nil, errors.New("no storages to estimate")
+	}
+	p := cfg.TargetProfile()
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	cost := &DeploymentCost{Name: name}
+	var words uint64
+	for _, s := range stores {
+		code, err := StorageBytecode(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.ValidateSize(s.Name(), code); err != nil {
+			return nil, err
+		}
+
+		c, err := estimateStorageCost(s.Name(), code, p.Gas)
+		if err != nil {
+			return nil, err
+		}
+		cost.Storages = append(cost.Storages, *c)
+
+		w := toWords(len(code.Creation))
+		words += w
+		cost.Deployer += p.Gas.Copy * w
+	}
+	// The constructor copies every creation code from its own code to newly
+	// allocated memory, which Solidity never releases.
+	cost.Deployer += p.Gas.TxBase + p.Gas.memoryGas(words)
+
+	return cost, nil
+}
+
+// estimateStorageCost computes the cost of deploying a single storage.
+func estimateStorageCost(name string, code *Bytecode, g GasSchedule) (*StorageCost, error) {
+	c := &StorageCost{
+		Name:          name,
+		CodeBytes:     len(code.Deployed),
+		InitCodeBytes: len(code.Creation),
+		Create:        g.Create,
+		CodeDeposit:   g.CodeDepositPerByte * uint64(len(code.Deployed)),
+		// The calling contract's deployment carries the creation code as
+		// transaction data, then CREATE charges its words once more.
+		InitCode: g.initCodeGas(code.Creation) + g.InitCodeWord*toWords(len(code.Creation)),
+	}
+
+	cfg := &runtime.Config{GasLimit: math.MaxUint64}
+	_, _, left, err := runtime.Create(code.Creation, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("executing creation code of %q: %w", name, err)
+	}
+	// The EVM charges the mainnet code deposit after execution.
+	c.Execution = cfg.GasLimit - left - params.CreateDataGas*uint64(len(code.Deployed))
+
+	return c, nil
+}
+
+// toWords returns the number of 32-byte words required to hold n bytes.
+func toWords(n int) uint64 {
+	return uint64(n+31) / 32
+}
+
+// A ProjectCost aggregates the DeploymentCosts of all bundles of a project.
+type ProjectCost struct {
+	// GasPrice is the gas price in wei.
+	GasPrice *big.Int
+	Bundles  []*DeploymentCost
+}
+
+// Gas returns the total gas of deploying all bundles.
+func (p *ProjectCost) Gas() uint64 {
+	var g uint64
+	for _, b := range p.Bundles {
+		g += b.Gas()
+	}
+	return g
+}
+
+// Wei returns the total cost of deploying all bundles in wei, which is zero if
+// the gas price is not set.
+func (p *ProjectCost) Wei() *big.Int {
+	if p.GasPrice == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Mul(new(big.Int).SetUint64(p.Gas()), p.GasPrice)
+}
+
+// Gwei is the number of wei in a gwei, as commonly used to quote gas prices.
+var Gwei = big.NewInt(params.GWei)
+
+// FormatETH formats an amount of wei as a decimal number of ether without
+// trailing zeros.
+func FormatETH(wei *big.Int) string {
+	s := new(big.Rat).SetFrac(wei, big.NewInt(params.Ether)).FloatString(18)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// jsonBundleCost is the JSON representation of a DeploymentCost.
+type jsonBundleCost struct {
+	Name     string        `json:"name"`
+	Gas      uint64        `json:"gas"`
+	Wei      string        `json:"wei"`
+	ETH      string        `json:"eth"`
+	Deployer uint64        `json:"deployer"`
+	Storages []StorageCost `json:"storages"`
+}
+
+// jsonProjectCost is the JSON representation of a ProjectCost.
+type jsonProjectCost struct {
+	GasPrice string           `json:"gasPrice"`
+	Gas      uint64           `json:"gas"`
+	Wei      string           `json:"wei"`
+	ETH      string           `json:"eth"`
+	Bundles  []jsonBundleCost `json:"bundles"`
+}
+
+// WriteJSON writes the cost of the project as indented JSON, intended to be
+// consumed by budget checks in CI. Amounts of wei are encoded as decimal
+// strings to avoid loss of precision.
+func (p *ProjectCost) WriteJSON(w io.Writer) error {
+	if p.GasPrice == nil {
+		return errors.New("gas price not set")
+	}
+
+	out := jsonProjectCost{
+		GasPrice: p.GasPrice.String(),
+		Gas:      p.Gas(),
+		Wei:      p.Wei().String(),
+		ETH:      FormatETH(p.Wei()),
+		Bundles:  []jsonBundleCost{},
+	}
+	for _, b := range p.Bundles {
+		wei := b.Wei(p.GasPrice)
+		out.Bundles = append(out.Bundles, jsonBundleCost{
+			Name:     b.Name,
+			Gas:      b.Gas(),
+			Wei:      wei.String(),
+			ETH:      FormatETH(wei),
+			Deployer: b.Deployer,
+			Storages: b.Storages,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("json.Encode(%T): %w", out, err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEstimateDeploymentCost(t *testing.T) {
+	stores := []fakeStorage{
+		{name: "TestBucketStorage0", buckets: []Bucket{fakeBucket{data: bytes.Repeat([]byte("foo"), 1000), numFields: 2}}},
+		{name: "TestBucketStorage1", buckets: []Bucket{fakeBucket{data: []byte("bar"), numFields: 1}}},
+	}
+
+	got, err := EstimateDeploymentCost("Test", stores, nil)
+	if err != nil {
+		t.Fatalf("EstimateDeploymentCost(…) error %v", err)
+	}
+	if len(got.Storages) != len(stores) {
+		t.Fatalf("EstimateDeploymentCost(…) got %d storage costs; want %d", len(got.Storages), len(stores))
+	}
+
+	var words uint64
+	for i, c := range got.Storages {
+		code, err := StorageBytecode(stores[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		words += toWords(len(code.Creation))
+
+		// EIP-3860 charges 2 gas per word in both the deployment of the
+		// calling contract and CREATE.
+		want := StorageCost{
+			Name:          stores[i].Name(),
+			CodeBytes:     len(code.Deployed),
+			InitCodeBytes: len(code.Creation),
+			Create:        32000,
+			CodeDeposit:   200 * uint64(len(code.Deployed)),
+			InitCode:      txDataGas(code.Creation) + 4*toWords(len(code.Creation)),
+			Execution:     c.Execution,
+		}
+		if diff := cmp.Diff(want, c); diff != "" {
+			t.Errorf("EstimateDeploymentCost(…).Storages[%d] diff (-want +got):\n%s", i, diff)
+		}
+		if c.Execution == 0 {
+			t.Errorf("EstimateDeploymentCost(…).Storages[%d].Execution = 0; want > 0", i)
+		}
+	}
+
+	if c0, c1 := got.Storages[0], got.Storages[1]; c0.Execution <= c1.Execution {
+		t.Errorf("execution gas of larger storage (%d) not greater than of smaller one (%d)", c0.Execution, c1.Execution)
+	}
+
+	wantDeployer := 21000 + 3*words + 3*words + words*words/512
+	if got.Deployer != wantDeployer {
+		t.Errorf("EstimateDeploymentCost(…).Deployer = %d; want %d", got.Deployer, wantDeployer)
+	}
+	if want := got.Deployer + got.Storages[0].Gas() + got.Storages[1].Gas(); got.Gas() != want {
+		t.Errorf("%T.Gas() = %d; want %d", got, got.Gas(), want)
+	}
+
+	if _, err := EstimateDeploymentCost[fakeStorage]("Empty", nil, nil); err == nil {
+		t.Errorf("EstimateDeploymentCost(…, nil, nil) got nil error; want error")
+	}
+
+	t.Run("target", func(t *testing.T) {
+		p := *ProfileMainnet
+		p.Name = "test"
+		p.Gas.CodeDepositPerByte *= 2
+		p.Gas.TxDataCompression = 0.5
+
+		l2, err := EstimateDeploymentCost("Test", stores, &GeneratorConfig{Target: &p})
+		if err != nil {
+			t.Fatalf("EstimateDeploymentCost(…, [target %q]) error %v", p.Name, err)
+		}
+		for i, c := range l2.Storages {
+			mainnet := got.Storages[i]
+			if want := 2 * mainnet.CodeDeposit; c.CodeDeposit != want {
+				t.Errorf("Storages[%d].CodeDeposit = %d; want %d", i, c.CodeDeposit, want)
+			}
+			if c.InitCode >= mainnet.InitCode {
+				t.Errorf("Storages[%d].InitCode = %d with compressed transaction data; want < %d", i, c.InitCode, mainnet.InitCode)
+			}
+			if c.Execution != mainnet.Execution {
+				t.Errorf("Storages[%d].Execution = %d; want %d as on mainnet", i, c.Execution, mainnet.Execution)
+			}
+		}
+	})
+
+	t.Run("code size limit", func(t *testing.T) {
+		p := *ProfileMainnet
+		p.Name = "tiny"
+		p.MaxCodeSize = 1000
+
+		_, err := EstimateDeploymentCost("Test", stores, &GeneratorConfig{Target: &p})
+		if !errors.Is(err, ErrCodeSizeExceeded) {
+			t.Errorf("EstimateDeploymentCost(…, [target %q]) error %v; want %v", p.Name, err, ErrCodeSizeExceeded)
+		}
+	})
+}
+
+// txDataGas returns the mainnet cost of transaction data.
+func txDataGas(data []byte) uint64 {
+	var g uint64
+	for _, b := range data {
+		if b == 0 {
+			g += 4
+		} else {
+			g += 16
+		}
+	}
+	return g
+}
+
+func TestFormatETH(t *testing.T) {
+	tests := []struct {
+		wei  *big.Int
+		want string
+	}{
+		{wei: big.NewInt(0), want: "0"},
+		{wei: big.NewInt(1), want: "0.000000000000000001"},
+		{wei: big.NewInt(1e18), want: "1"},
+		{wei: big.NewInt(1_500_000_000_000_000_000), want: "1.5"},
+		{wei: new(big.Int).Mul(big.NewInt(12_345_678), Gwei), want: "0.012345678"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatETH(tt.wei); got != tt.want {
+			t.Errorf("FormatETH(%v) got %q; want %q", tt.wei, got, tt.want)
+		}
+	}
+}
+
+func TestProjectCostJSON(t *testing.T) {
+	p := &ProjectCost{
+		GasPrice: new(big.Int).Mul(big.NewInt(20), Gwei),
+		Bundles: []*DeploymentCost{
+			{
+				Name:     "Foo",
+				Deployer: 25000,
+				Storages: []StorageCost{
+					{Name: "FooStorage0", CodeBytes: 100, InitCodeBytes: 120, Create: 32000, CodeDeposit: 20000, InitCode: 1500, Execution: 500},
+				},
+			},
+			{
+				Name:     "Bar",
+				Deployer: 21000,
+				Storages: []StorageCost{
+					{Name: "BarStorage0", CodeBytes: 10, InitCodeBytes: 30, Create: 32000, CodeDeposit: 2000, InitCode: 400, Execution: 100},
+				},
+			},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := p.WriteJSON(buf); err != nil {
+		t.Fatalf("%T.WriteJSON() error %v", p, err)
+	}
+
+	var got jsonProjectCost
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("json.Unmarshal(%T.WriteJSON()) error %v", p, err)
+	}
+
+	want := jsonProjectCost{
+		GasPrice: "20000000000",
+		Gas:      134500,
+		Wei:      "2690000000000000",
+		ETH:      "0.00269",
+		Bundles: []jsonBundleCost{
+			{
+				Name:     "Foo",
+				Gas:      79000,
+				Wei:      "1580000000000000",
+				ETH:      "0.00158",
+				Deployer: 25000,
+				Storages: p.Bundles[0].Storages,
+			},
+			{
+				Name:     "Bar",
+				Gas:      55500,
+				Wei:      "1110000000000000",
+				ETH:      "0.00111",
+				Deployer: 21000,
+				Storages: p.Bundles[1].Storages,
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("%T.WriteJSON() diff (-want +got):\n%s", p, diff)
+	}
+
+	if err := (&ProjectCost{}).WriteJSON(new(bytes.Buffer)); err == nil {
+		t.Errorf("%T{}.WriteJSON() without gas price got nil error; want error", p)
+	}
+}
+
+func TestWeiWithoutGasPrice(t *testing.T) {
+	d := &DeploymentCost{Deployer: 21000}
+	if got := d.Wei(nil); got.Sign() != 0 {
+		t.Errorf("%T.Wei(nil) got %v; want 0", d, got)
+	}
+	p := &ProjectCost{Bundles: []*DeploymentCost{d}}
+	if got := p.Wei(); got.Sign() != 0 {
+		t.Errorf("%T{GasPrice: nil}.Wei() got %v; want 0", p, got)
+	}
+}
@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"go.uber.org/multierr"
+)
+
+// DefaultCreate2Factory is the deterministic deployment proxy that forge uses
+// for `new Contract{salt: salt}()` in scripts. It is available at the same
+// address on most EVM chains.
+var DefaultCreate2Factory = common.HexToAddress("0x4e59b44847b379578588920cA78FbF26c0B4956C")
+
+// create2FactoryCode is the runtime code of the deterministic deployment proxy,
+// which deploys the calldata following a 32-byte salt via CREATE2 and returns
+// the 20-byte address.
+var create2FactoryCode = common.FromHex("0x7fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe03601600081602082378035828234f58015156039578182fd5b8082525050506014600cf3")
+
+// DeploymentConfig configures PlanDeployment. The zero value is valid.
+type DeploymentConfig struct {
+	// Factory is the CREATE2 factory deploying the storages. Defaults to
+	// DefaultCreate2Factory. Custom factories are expected to behave
+	// identically, i.e. to accept the salt followed by the creation code.
+	Factory common.Address
+	// Salt is mixed into the salts of all storages, allowing multiple
+	// deployments of the same data.
+	Salt common.Hash
+	// BatchGasLimit limits the estimated gas of each batch. Defaults to half
+	// of the block gas limit of the target chain.
+	BatchGasLimit uint64
+	// Target is the chain the storages are deployed to. Defaults to
+	// ProfileMainnet.
+	Target *ChainProfile
+}
+
+// A PlannedDeployment is the deployment of a single storage via CREATE2.
+type PlannedDeployment struct {
+	Storage BucketStorage
+	// Salt is keccak256(DeploymentConfig.Salt, name).
+	Salt common.Hash
+	// Address is the address of the deployed storage.
+	Address common.Address
+	// Gas is the gas used by the deployment transaction, including intrinsic
+	// gas.
+	Gas uint64
+}
+
+// A DeploymentBatch is a set of storage deployments that is executed by a
+// single forge script.
+type DeploymentBatch struct {
+	Deployments []PlannedDeployment
+	// Gas is the total gas used by the deployments.
+	Gas uint64
+}
+
+// A DeploymentPlan splits the deployment of a bundle of storages into
+// gas-bounded batches with precomputed addresses.
+type DeploymentPlan struct {
+	Factory common.Address
+	Batches []DeploymentBatch
+}
+
+// Deployments returns all deployments of the plan in storage order.
+func (p *DeploymentPlan) Deployments() []PlannedDeployment {
+	var ds []PlannedDeployment
+	for _, b := range p.Batches {
+		ds = append(ds, b.Deployments...)
+	}
+	return ds
+}
+
+// Addresses returns the addresses of all storages in storage order.
+func (p *DeploymentPlan) Addresses() []common.Address {
+	var addrs []common.Address
+	for _, d := range p.Deployments() {
+		addrs = append(addrs, d.Address)
+	}
+	return addrs
+}
+
+// Gas returns the total gas used by all batches.
+func (p *DeploymentPlan) Gas() uint64 {
+	var g uint64
+	for _, b := range p.Batches {
+		g += b.Gas
+	}
+	return g
+}
+
+// PlanDeployment plans the deployment of the bytecode assembled by
+// StorageBytecode for each storage via a CREATE2 factory, such that storage
+// addresses are known before deployment and can be hardcoded (see
+// WriteStorageAddresses).
+//
+// Each deployment is checked against the size limits of the target chain and
+// simulated against the factory in an in-memory EVM to determine its gas and
+// to confirm the address computed with crypto.CreateAddress2. The EVM charges
+// the CREATE2 and code deposit costs of mainnet, which are substituted with
+// those of the target chain. The deployments are then greedily grouped into
+// batches, retaining the order of the storages, such that no batch exceeds
+// cfg.BatchGasLimit.
+func PlanDeployment[S BucketStorage](stores []S, cfg DeploymentConfig) (*DeploymentPlan, error) {
+	if cfg.Factory == (common.Address{}) {
+		cfg.Factory = DefaultCreate2Factory
+	}
+	if cfg.Target == nil {
+		cfg.Target = ProfileMainnet
+	}
+	if err := cfg.Target.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.BatchGasLimit == 0 {
+		cfg.BatchGasLimit = cfg.Target.BlockGasLimit / 2
+	}
+
+	sdb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("state.New(…): %w", err)
+	}
+	sdb.SetCode(cfg.Factory, create2FactoryCode)
+	rcfg := &runtime.Config{State: sdb, GasLimit: math.MaxUint64}
+
+	plan := &DeploymentPlan{Factory: cfg.Factory}
+	var batch DeploymentBatch
+	for _, s := range stores {
+		d, err := simulateDeployment(s, cfg, rcfg)
+		if err != nil {
+			return nil, err
+		}
+		if d.Gas > cfg.BatchGasLimit {
+			return nil, fmt.Errorf("deployment of %q uses %d gas, exceeding the batch gas limit of %d", s.Name(), d.Gas, cfg.BatchGasLimit)
+		}
+
+		if batch.Gas+d.Gas > cfg.BatchGasLimit {
+			plan.Batches = append(plan.Batches, batch)
+			batch = DeploymentBatch{}
+		}
+		batch.Deployments = append(batch.Deployments, *d)
+		batch.Gas += d.Gas
+	}
+	if len(batch.Deployments) > 0 {
+		plan.Batches = append(plan.Batches, batch)
+	}
+
+	return plan, nil
+}
+
+// simulateDeployment deploys a storage via the factory in rcfg.State.
+func simulateDeployment(s BucketStorage, cfg DeploymentConfig, rcfg *runtime.Config) (*PlannedDeployment, error) {
+	code, err := StorageBytecode(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Target.ValidateSize(s.Name(), code); err != nil {
+		return nil, err
+	}
+
+	salt := crypto.Keccak256Hash(cfg.Salt[:], []byte(s.Name()))
+	addr := crypto.CreateAddress2(cfg.Factory, salt, crypto.Keccak256(code.Creation))
+	if rcfg.State.GetCodeSize(addr) > 0 {
+		return nil, fmt.Errorf("storage %q collides with a previous deployment at %v", s.Name(), addr)
+	}
+
+	data := append(salt.Bytes(), code.Creation...)
+	ret, left, err := runtime.Call(cfg.Factory, data, rcfg)
+	if err != nil {
+		return nil, fmt.Errorf("simulating deployment of %q: %w", s.Name(), err)
+	}
+	if got := common.BytesToAddress(ret); got != addr {
+		return nil, fmt.Errorf("simulated deployment of %q at %v; expected %v", s.Name(), got, addr)
+	}
+
+	gas := rcfg.GasLimit - left
+	gas += cfg.Target.Gas.Create + cfg.Target.Gas.CodeDepositPerByte*uint64(len(code.Deployed))
+	gas -= params.Create2Gas + params.CreateDataGas*uint64(len(code.Deployed))
+
+	return &PlannedDeployment{
+		Storage: s,
+		Salt:    salt,
+		Address: addr,
+		Gas:     gas + cfg.Target.Gas.TxBase + cfg.Target.Gas.initCodeGas(data),
+	}, nil
+}
+
+// DeployScriptData is the data passed to DeployScriptTemplate.
+type DeployScriptData struct {
+	Config *GeneratorConfig
+	Name   string
+	// Batch is the index of the batch deployed by the script.
+	Batch      int
+	NumBatches int
+	Factory    common.Address
+	// ArtifactsDir is the directory containing the Foundry artifacts of the
+	// storages, relative to the project root.
+	ArtifactsDir string
+	DeploymentBatch
+}
+
+// WriteDeployScript writes a forge script deploying a batch of a
+// DeploymentPlan. The script reads the creation code of each storage from the
+// Foundry artifacts in artifactsDir (see WriteStorageArtifacts), which must be
+// readable via `fs_permissions` in foundry.toml. Storages that are already
+// deployed are skipped, so scripts can be safely re-run.
+func WriteDeployScript(name string, plan *DeploymentPlan, batch int, artifactsDir string, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+	if batch < 0 || batch >= len(plan.Batches) {
+		return fmt.Errorf("batch %d out of range for plan with %d batches", batch, len(plan.Batches))
+	}
+
+	return cfg.executeTemplate(DeployScriptTemplate, w, DeployScriptData{
+		Config:          cfg,
+		Name:            name,
+		Batch:           batch,
+		NumBatches:      len(plan.Batches),
+		Factory:         plan.Factory,
+		ArtifactsDir:    path.Clean(filepath.ToSlash(artifactsDir)),
+		DeploymentBatch: plan.Batches[batch],
+	})
+}
+
+// StorageAddressesData is the data passed to StorageAddressesTemplate.
+type StorageAddressesData struct {
+	Config    *GeneratorConfig
+	Name      string
+	Addresses []common.Address
+}
+
+// AddressSelection returns a Selection over the addresses, or nil if there are
+// none.
+func (d StorageAddressesData) AddressSelection() *Selection[common.Address] {
+	return newSelection(d.Addresses)
+}
+
+// WriteStorageAddresses writes a library resolving storage IDs, as returned by
+// the storage mappings, to the addresses precomputed by a DeploymentPlan.
+func WriteStorageAddresses(name string, plan *DeploymentPlan, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.executeTemplate(StorageAddressesTemplate, w, StorageAddressesData{
+		Config:    cfg,
+		Name:      name,
+		Addresses: plan.Addresses(),
+	})
+}
+
+// WriteDeployment is a convenience wrapper that writes all files needed to
+// execute a DeploymentPlan to a given output directory: the Foundry artifacts
+// of the storages in the `artifacts` subdirectory, one forge script per batch
+// in the `script` subdirectory and the addresses library (see
+// WriteStorageAddresses). Paths in the scripts are relative to the working
+// directory, which is therefore expected to be the project root. Returns the
+// paths of the written files.
+//
+// Combined with WriteStaticStorageManager, this replaces the storage deployer
+// written by WriteGroupStorage.
+func WriteDeployment(name string, plan *DeploymentPlan, cfg *GeneratorConfig, outputDir string) ([]string, error) {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(plan.Batches) == 0 {
+		return nil, errors.New("empty deployment plan")
+	}
+
+	var stores []BucketStorage
+	for _, d := range plan.Deployments() {
+		stores = append(stores, d.Storage)
+	}
+	artifactsDir := filepath.Join(outputDir, "artifacts")
+	created, err := WriteStorageArtifacts(stores, FoundryArtifacts, filepath.Join(outputDir, "storage"), cfg.Output, artifactsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := fileGenerator{fsys: cfg.Output, created: created}
+	errs := []error{
+		fs.writeSolFile(outputDir, cfg.AddressesName(name), func(f io.Writer) error {
+			return annotateNonNil(WriteStorageAddresses(name, plan, cfg, f), "storage.WriteStorageAddresses(%q, …)", name)
+		}),
+	}
+	for i := range plan.Batches {
+		i := i
+		errs = append(errs, fs.writeSolFile(filepath.Join(outputDir, "script"), cfg.ScriptName(name, i)+".s", func(f io.Writer) error {
+			return annotateNonNil(WriteDeployScript(name, plan, i, artifactsDir, cfg, f), "storage.WriteDeployScript(%q, …, %d, …)", name, i)
+		}))
+	}
+	if err := multierr.Combine(errs...); err != nil {
+		return nil, err
+	}
+
+	return fs.created, nil
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanDeployment(t *testing.T) {
+	stores := []fakeStorage{
+		{name: "TestBucketStorage0", buckets: []Bucket{fakeBucket{data: bytes.Repeat([]byte("foo"), 100), numFields: 2}}},
+		{name: "TestBucketStorage1", buckets: []Bucket{fakeBucket{data: []byte("bar"), numFields: 1}}},
+		{name: "TestBucketStorage2", buckets: []Bucket{fakeBucket{data: []byte("baz"), numFields: 3}}},
+	}
+
+	plan, err := PlanDeployment(stores, DeploymentConfig{})
+	if err != nil {
+		t.Fatalf("PlanDeployment(…) error %v", err)
+	}
+	if got := len(plan.Batches); got != 1 {
+		t.Fatalf("PlanDeployment(…) with default gas limit got %d batches; want 1", got)
+	}
+
+	// Replay the plan against the factory to confirm the addresses and gas.
+	sdb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdb.SetCode(DefaultCreate2Factory, create2FactoryCode)
+	cfg := &runtime.Config{State: sdb, GasLimit: math.MaxUint64}
+
+	var gas []uint64
+	for i, d := range plan.Deployments() {
+		code, err := StorageBytecode(stores[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Storage.Name() != stores[i].Name() {
+			t.Errorf("Deployments()[%d] got storage %q; want %q", i, d.Storage.Name(), stores[i].Name())
+		}
+		if want := crypto.CreateAddress2(DefaultCreate2Factory, d.Salt, crypto.Keccak256(code.Creation)); d.Address != want {
+			t.Errorf("Deployments()[%d].Address got %v; want %v", i, d.Address, want)
+		}
+
+		if _, _, err := runtime.Call(DefaultCreate2Factory, append(d.Salt.Bytes(), code.Creation...), cfg); err != nil {
+			t.Fatalf("runtime.Call([factory], [deployment %d]) error %v", i, err)
+		}
+		if got := sdb.GetCode(d.Address); !bytes.Equal(got, code.Deployed) {
+			t.Errorf("code at %v after deployment %d does not match %q", d.Address, i, stores[i].Name())
+		}
+		gas = append(gas, d.Gas)
+	}
+	if gas[0] <= gas[1] {
+		t.Errorf("deployment gas of larger storage (%d) not greater than of smaller one (%d)", gas[0], gas[1])
+	}
+
+	t.Run("batches", func(t *testing.T) {
+		limit := gas[1] + gas[2]
+		got, err := PlanDeployment(stores, DeploymentConfig{BatchGasLimit: limit})
+		if err != nil {
+			t.Fatalf("PlanDeployment(…, [limit %d]) error %v", limit, err)
+		}
+
+		var sizes []int
+		for _, b := range got.Batches {
+			sizes = append(sizes, len(b.Deployments))
+			if b.Gas > limit {
+				t.Errorf("batch uses %d gas; exceeding limit %d", b.Gas, limit)
+			}
+		}
+		if diff := cmp.Diff([]int{1, 2}, sizes); diff != "" {
+			t.Errorf("PlanDeployment(…, [limit %d]) batch sizes diff (-want +got):\n%s", limit, diff)
+		}
+		if diff := cmp.Diff(plan.Addresses(), got.Addresses()); diff != "" {
+			t.Errorf("PlanDeployment(…, [limit %d]) addresses differ from single batch; diff (-want +got):\n%s", limit, diff)
+		}
+	})
+
+	t.Run("salt", func(t *testing.T) {
+		got, err := PlanDeployment(stores, DeploymentConfig{Salt: common.Hash{1}})
+		if err != nil {
+			t.Fatalf("PlanDeployment(…, [salt]) error %v", err)
+		}
+		for i, a := range got.Addresses() {
+			if a == plan.Addresses()[i] {
+				t.Errorf("PlanDeployment(…, [salt]) address %d unchanged", i)
+			}
+		}
+	})
+
+	t.Run("target", func(t *testing.T) {
+		target := *ProfileMainnet
+		target.Name = "test"
+		target.BlockGasLimit = 2 * (gas[1] + gas[2] + 10_000)
+		target.Gas.CodeDepositPerByte++
+
+		got, err := PlanDeployment(stores, DeploymentConfig{Target: &target})
+		if err != nil {
+			t.Fatalf("PlanDeployment(…, [target %q]) error %v", target.Name, err)
+		}
+		var sizes []int
+		for _, b := range got.Batches {
+			sizes = append(sizes, len(b.Deployments))
+		}
+		if diff := cmp.Diff([]int{1, 2}, sizes); diff != "" {
+			t.Errorf("PlanDeployment(…, [target %q]) batch sizes diff (-want +got):\n%s", target.Name, diff)
+		}
+		for i, d := range got.Deployments() {
+			code, err := StorageBytecode(stores[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := gas[i] + uint64(len(code.Deployed)); d.Gas != want {
+				t.Errorf("PlanDeployment(…, [target %q]) deployment %d gas = %d; want %d", target.Name, i, d.Gas, want)
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tiny := *ProfileMainnet
+		tiny.Name = "tiny"
+		tiny.MaxCodeSize = 100
+
+		tests := []struct {
+			name         string
+			stores       []fakeStorage
+			cfg          DeploymentConfig
+			wantContains string
+		}{
+			{
+				name:         "Gas limit",
+				stores:       stores,
+				cfg:          DeploymentConfig{BatchGasLimit: gas[0] - 1},
+				wantContains: "exceeding the batch gas limit",
+			},
+			{
+				name:         "Duplicate",
+				stores:       []fakeStorage{stores[1], stores[1]},
+				wantContains: "collides with a previous deployment",
+			},
+			{
+				name:         "Code size",
+				stores:       stores,
+				cfg:          DeploymentConfig{Target: &tiny},
+				wantContains: "max 100 on tiny",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := PlanDeployment(tt.stores, tt.cfg); err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+					t.Errorf("PlanDeployment(…) got err %v; want containing %q", err, tt.wantContains)
+				}
+			})
+		}
+	})
+}
+
+func TestWriteDeployment(t *testing.T) {
+	stores := []fakeStorage{
+		{name: "TestBucketStorage0", buckets: []Bucket{fakeBucket{data: []byte("foo"), numFields: 2}}},
+		{name: "TestBucketStorage1", buckets: []Bucket{fakeBucket{data: []byte("bar"), numFields: 1}}},
+	}
+	single, err := PlanDeployment(stores, DeploymentConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := PlanDeployment(stores, DeploymentConfig{BatchGasLimit: single.Batches[0].Deployments[0].Gas})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs := plan.Addresses()
+
+	mem := new(MemFS)
+	cfg := DefaultGeneratorConfig()
+	cfg.Output = mem
+	paths, err := WriteDeployment("Test", plan, cfg, "gen")
+	if err != nil {
+		t.Fatalf("WriteDeployment(…) error %v", err)
+	}
+
+	want := []string{
+		"gen/artifacts/TestBucketStorage0.sol/TestBucketStorage0.json",
+		"gen/artifacts/TestBucketStorage1.sol/TestBucketStorage1.json",
+		"gen/TestStorageAddresses.sol",
+		"gen/script/DeployTestStorageBatch0.s.sol",
+		"gen/script/DeployTestStorageBatch1.s.sol",
+	}
+	if diff := cmp.Diff(want, paths); diff != "" {
+		t.Errorf("WriteDeployment(…) paths diff (-want +got):\n%s", diff)
+	}
+
+	files := mem.Files()
+	wantContains := map[string][]string{
+		"gen/TestStorageAddresses.sol": {
+			"library TestStorageAddresses {",
+			"uint256 internal constant NUM_STORAGES = 2;",
+			"if (storageId < 1) {",
+			"storage_ = IBucketStorage(" + addrs[0].Hex() + ");",
+			"storage_ = IBucketStorage(" + addrs[1].Hex() + ");",
+		},
+		"gen/script/DeployTestStorageBatch0.s.sol": {
+			`import {Script} from "forge-std/Script.sol";`,
+			"contract DeployTestStorageBatch0 is Script {",
+			"@notice Deploys batch 0 (of 2)",
+			`"gen/artifacts/TestBucketStorage0.sol/TestBucketStorage0.json",`,
+			addrs[0].Hex(),
+		},
+		"gen/script/DeployTestStorageBatch1.s.sol": {
+			"contract DeployTestStorageBatch1 is Script {",
+			`"gen/artifacts/TestBucketStorage1.sol/TestBucketStorage1.json",`,
+			addrs[1].Hex(),
+		},
+	}
+	for p, wants := range wantContains {
+		for _, w := range wants {
+			if !strings.Contains(string(files[p]), w) {
+				t.Errorf("WriteDeployment(…) file %q does not contain %q", p, w)
+			}
+		}
+	}
+	if strings.Contains(string(files["gen/script/DeployTestStorageBatch1.s.sol"]), addrs[0].Hex()) {
+		t.Errorf("WriteDeployment(…) batch 1 deploys storage of batch 0")
+	}
+}
+
+func TestWriteStaticStorageManager(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteStaticStorageManager("Test", []fakeGroup{{"FOO", 2}}, nil, &buf); err != nil {
+		t.Fatalf("WriteStaticStorageManager(…) error %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		`import { TestStorageAddresses } from "./TestStorageAddresses.sol";`,
+		"return TestStorageAddresses.storageAt(coordinates.bucket.storageId).getBucket(",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteStaticStorageManager(…) does not contain %q", want)
+		}
+	}
+	for _, notWant := range []string{"TestStorageDeployer", "_bundle", "constructor("} {
+		if strings.Contains(got, notWant) {
+			t.Errorf("WriteStaticStorageManager(…) contains %q", notWant)
+		}
+	}
+}
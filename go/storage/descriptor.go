@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// LibraryVersion is the version of the solidify storage format. It is embedded
+// in the format descriptors of generated storages and in the generated mapping
+// libraries and must match `BucketFormatLib.LIBRARY_VERSION` on-chain.
+const LibraryVersion uint8 = 1
+
+// BucketFlavour identifies the indexing scheme of a bucket.
+type BucketFlavour uint8
+
+// Bucket flavours as encoded in format descriptors.
+const (
+	// FlavourCustom denotes buckets with a user-defined indexing scheme.
+	FlavourCustom BucketFlavour = iota
+	// FlavourIndexed denotes buckets compatible with `IndexedBucketLib`.
+	FlavourIndexed
+	// FlavourLabelled denotes buckets compatible with `LabelledBucketLib`.
+	FlavourLabelled
+
+	// FlavourMixed denotes storages containing buckets of different formats.
+	FlavourMixed BucketFlavour = 0xff
+)
+
+// Codec identifies the compression of the bucket data.
+type Codec uint8
+
+// Codecs as encoded in format descriptors.
+const (
+	// CodecDeflate denotes DEFLATE compressed data, see `InflateLibWrapper`.
+	CodecDeflate Codec = iota
+	// CodecDeflateEncrypted denotes DEFLATE compressed data that is encrypted
+	// until revealed, see EncryptedBucket and `BucketCipherLib`.
+	CodecDeflateEncrypted
+)
+
+// BucketFormat describes how the data of a bucket is encoded.
+type BucketFormat struct {
+	Flavour BucketFlavour
+	Codec   Codec
+	// LabelBits is the width of the field labels in labelled buckets.
+	LabelBits uint8
+	// OffsetBits is the width of the offsets in the index header of indexed
+	// buckets.
+	OffsetBits uint8
+	// FieldSize is the size of each field for flavours with fixed-size fields.
+	FieldSize uint16
+}
+
+// A FormattedBucket is a Bucket that describes its own format.
+type FormattedBucket interface {
+	Bucket
+	Format() BucketFormat
+}
+
+// BucketFormatOf returns the format of a given bucket. Buckets that do not
+// implement FormattedBucket are assumed to be DEFLATE compressed and of custom
+// flavour.
+func BucketFormatOf(b Bucket) BucketFormat {
+	if f, ok := b.(FormattedBucket); ok {
+		return f.Format()
+	}
+	return BucketFormat{Flavour: FlavourCustom, Codec: CodecDeflate}
+}
+
+// StorageFormatOf returns the format shared by all buckets in a storage. If the
+// buckets have different formats, only the flavour FlavourMixed is set.
+func StorageFormatOf(s BucketStorage) BucketFormat {
+	bs := s.Buckets()
+	if len(bs) == 0 {
+		return BucketFormat{Flavour: FlavourMixed}
+	}
+
+	f := BucketFormatOf(bs[0])
+	for _, b := range bs[1:] {
+		if BucketFormatOf(b) != f {
+			return BucketFormat{Flavour: FlavourMixed}
+		}
+	}
+	return f
+}
+
+// Descriptor encodes the format together with the LibraryVersion into a
+// 32-byte descriptor as returned by the generated storage contracts. The
+// layout is (big-endian, from the most significant byte)
+//
+//	| version | flavour | codec | labelBits | offsetBits | fieldSize (2 bytes) | 0 (25 bytes) |
+//
+// See also `BucketFormatLib`.
+func (f BucketFormat) Descriptor() common.Hash {
+	var d common.Hash
+	d[0] = LibraryVersion
+	d[1] = uint8(f.Flavour)
+	d[2] = uint8(f.Codec)
+	d[3] = f.LabelBits
+	d[4] = f.OffsetBits
+	d[5] = uint8(f.FieldSize >> 8)
+	d[6] = uint8(f.FieldSize)
+	return d
+}
+
+// BucketFormats contains the format descriptors of all buckets in a storage.
+type BucketFormats struct {
+	// Uniform is true if all buckets share the same Descriptor.
+	Uniform bool
+	// Descriptor is the hex-encoded descriptor shared by all buckets if they
+	// are uniform.
+	Descriptor string
+	// Packed contains the tightly packed descriptors of all buckets if they are
+	// not uniform.
+	Packed []byte
+}
+
+func newBucketFormats(s BucketStorage) BucketFormats {
+	f := StorageFormatOf(s)
+	if f.Flavour != FlavourMixed {
+		return BucketFormats{Uniform: true, Descriptor: f.Descriptor().Hex()}
+	}
+
+	var fs BucketFormats
+	for _, b := range s.Buckets() {
+		fs.Packed = append(fs.Packed, BucketFormatOf(b).Descriptor().Bytes()...)
+	}
+	return fs
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fakeFormattedBucket is a fakeBucket with a fixed format.
+type fakeFormattedBucket struct {
+	fakeBucket
+	format BucketFormat
+}
+
+func (b fakeFormattedBucket) Format() BucketFormat { return b.format }
+
+func TestDescriptor(t *testing.T) {
+	tests := []struct {
+		format BucketFormat
+		want   common.Hash
+	}{
+		{
+			format: BucketFormat{},
+			want:   common.HexToHash("0x0100000000000000000000000000000000000000000000000000000000000000"),
+		},
+		{
+			format: BucketFormat{Flavour: FlavourIndexed, Codec: CodecDeflate, OffsetBits: 16},
+			want:   common.HexToHash("0x0101000010000000000000000000000000000000000000000000000000000000"),
+		},
+		{
+			format: BucketFormat{Flavour: FlavourLabelled, Codec: CodecDeflate, LabelBits: 16, FieldSize: 0x0102},
+			want:   common.HexToHash("0x0102001000010200000000000000000000000000000000000000000000000000"),
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.format.Descriptor(); got != tt.want {
+			t.Errorf("%+v.Descriptor() = %v, want %v", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestStorageFormatOf(t *testing.T) {
+	indexed := BucketFormat{Flavour: FlavourIndexed, OffsetBits: 16}
+	labelled := BucketFormat{Flavour: FlavourLabelled, LabelBits: 16, FieldSize: 3}
+
+	tests := []struct {
+		name    string
+		buckets []Bucket
+		want    BucketFormat
+	}{
+		{
+			name: "uniform",
+			buckets: []Bucket{
+				fakeFormattedBucket{format: indexed},
+				fakeFormattedBucket{format: indexed},
+			},
+			want: indexed,
+		},
+		{
+			name: "unformatted",
+			buckets: []Bucket{
+				fakeBucket{},
+			},
+			want: BucketFormat{Flavour: FlavourCustom, Codec: CodecDeflate},
+		},
+		{
+			name: "mixed",
+			buckets: []Bucket{
+				fakeFormattedBucket{format: indexed},
+				fakeFormattedBucket{format: labelled},
+			},
+			want: BucketFormat{Flavour: FlavourMixed},
+		},
+		{
+			name: "empty",
+			want: BucketFormat{Flavour: FlavourMixed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := fakeStorage{name: "Foo", buckets: tt.buckets}
+			if got := StorageFormatOf(s); got != tt.want {
+				t.Errorf("StorageFormatOf(%+v) = %+v, want %+v", s, got, tt.want)
+			}
+		})
+	}
+}
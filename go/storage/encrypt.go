@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// A RevealSecret is the secret from which the keystreams of encrypted buckets
+// are derived. Only its commitment is stored on-chain until it is revealed
+// via the `reveal(secret)` function of the generated storage manager.
+type RevealSecret [32]byte
+
+// GenerateRevealSecret returns a new random secret.
+func GenerateRevealSecret() (RevealSecret, error) {
+	var s RevealSecret
+	if _, err := rand.Read(s[:]); err != nil {
+		return RevealSecret{}, fmt.Errorf("rand.Read(): %w", err)
+	}
+	return s, nil
+}
+
+// ParseRevealSecret parses a hex-encoded secret as returned by
+// RevealSecret.Hex.
+func ParseRevealSecret(s string) (RevealSecret, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		return RevealSecret{}, fmt.Errorf("hexutil.Decode(): %w", err)
+	}
+	if len(b) != len(RevealSecret{}) {
+		return RevealSecret{}, fmt.Errorf("got %d bytes; want %d", len(b), len(RevealSecret{}))
+	}
+	var sec RevealSecret
+	copy(sec[:], b)
+	return sec, nil
+}
+
+// Hex returns the 0x-prefixed hex encoding of the secret, which is passed as
+// `bytes32` to `reveal(secret)`.
+func (s RevealSecret) Hex() string {
+	return hexutil.Encode(s[:])
+}
+
+// Commitment returns the keccak256 hash of the secret, which is stored
+// on-chain to check the revealed secret against. This matches
+// `BucketCipherLib.commitment`.
+func (s RevealSecret) Commitment() common.Hash {
+	return crypto.Keccak256Hash(s[:])
+}
+
+// Encrypt encrypts data as `nonce | ciphertext`, where the ciphertext is data
+// XORed with the keystream derived from the secret and nonce (see
+// `BucketCipherLib`). The nonce is derived from the secret and data, such that
+// encryption is deterministic and generated contracts remain reproducible.
+func (s RevealSecret) Encrypt(data []byte) []byte {
+	nonce := crypto.Keccak256Hash([]byte("solidify.nonce"), s[:], data)
+	return append(nonce.Bytes(), s.xorKeystream(nonce, data)...)
+}
+
+// Decrypt reverses Encrypt. Decrypting with a different secret results in
+// garbage instead of an error.
+func (s RevealSecret) Decrypt(encrypted []byte) ([]byte, error) {
+	if len(encrypted) < common.HashLength {
+		return nil, errors.New("ciphertext shorter than nonce")
+	}
+	nonce := common.BytesToHash(encrypted[:common.HashLength])
+	return s.xorKeystream(nonce, encrypted[common.HashLength:]), nil
+}
+
+// xorKeystream XORs data with the keystream
+// `keccak256(secret | nonce | uint256(0)) | keccak256(secret | nonce | uint256(1)) | …`.
+func (s RevealSecret) xorKeystream(nonce common.Hash, data []byte) []byte {
+	out := make([]byte, len(data))
+	var ctr [32]byte
+	for off := 0; off < len(data); off += 32 {
+		binary.BigEndian.PutUint64(ctr[24:], uint64(off/32))
+		key := crypto.Keccak256(s[:], nonce[:], ctr[:])
+		for i := off; i < len(data) && i < off+32; i++ {
+			out[i] = data[i] ^ key[i-off]
+		}
+	}
+	return out
+}
+
+// EncryptedBucket is a Bucket whose compressed data is encrypted with a
+// RevealSecret. Its format equals that of the wrapped bucket, with the codec
+// set to CodecDeflateEncrypted.
+type EncryptedBucket struct {
+	bucket Bucket
+	secret RevealSecret
+}
+
+// EncryptBucket wraps a bucket to encrypt its data with a given secret.
+func EncryptBucket(b Bucket, s RevealSecret) *EncryptedBucket {
+	return &EncryptedBucket{bucket: b, secret: s}
+}
+
+// Data returns the encrypted compressed data of the wrapped bucket.
+func (b *EncryptedBucket) Data() ([]byte, error) {
+	d, err := b.bucket.Data()
+	if err != nil {
+		return nil, err
+	}
+	return b.secret.Encrypt(d), nil
+}
+
+// UncompressedSize returns the uncompressed size of the wrapped bucket.
+func (b *EncryptedBucket) UncompressedSize() int {
+	return b.bucket.UncompressedSize()
+}
+
+// NumFields returns the number of fields of the wrapped bucket.
+func (b *EncryptedBucket) NumFields() int {
+	return b.bucket.NumFields()
+}
+
+// Format returns the format of the wrapped bucket, marked as encrypted.
+func (b *EncryptedBucket) Format() BucketFormat {
+	f := BucketFormatOf(b.bucket)
+	f.Codec = CodecDeflateEncrypted
+	return f
+}
+
+// Commitment returns the commitment to the bucket's secret.
+func (b *EncryptedBucket) Commitment() common.Hash {
+	return b.secret.Commitment()
+}
+
+// Unwrap returns the unencrypted bucket.
+func (b *EncryptedBucket) Unwrap() Bucket {
+	return b.bucket
+}
+
+// RevealCommitmentOf returns the commitment to the secret with which all
+// buckets of the given storages are encrypted, or nil if none are encrypted.
+// Storages mixing unencrypted buckets or different secrets are rejected, as
+// the generated storage manager reveals a single secret.
+func RevealCommitmentOf[S BucketStorage](stores []S) (*common.Hash, error) {
+	var bs []storedBucket
+	for _, s := range stores {
+		for i, b := range s.Buckets() {
+			bs = append(bs, storedBucket{storage: s.Name(), index: i, bucket: b})
+		}
+	}
+	return revealCommitment(bs)
+}
+
+// A storedBucket is a bucket with the name of its storage and its index
+// therein.
+type storedBucket struct {
+	storage string
+	index   int
+	bucket  Bucket
+}
+
+// revealCommitment implements RevealCommitmentOf for a given set of buckets.
+func revealCommitment(buckets []storedBucket) (*common.Hash, error) {
+	var (
+		commitment *common.Hash
+		plain      int
+	)
+	for _, sb := range buckets {
+		e, ok := sb.bucket.(*EncryptedBucket)
+		if !ok {
+			plain++
+			continue
+		}
+		c := e.Commitment()
+		if commitment == nil {
+			commitment = &c
+		} else if *commitment != c {
+			return nil, fmt.Errorf("bucket %d of %q encrypted with a different secret", sb.index, sb.storage)
+		}
+	}
+	if commitment != nil && plain > 0 {
+		return nil, fmt.Errorf("%d of %d buckets are not encrypted", plain, len(buckets))
+	}
+	return commitment, nil
+}
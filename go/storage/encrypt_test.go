@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRevealSecret(t *testing.T) {
+	s, err := GenerateRevealSecret()
+	if err != nil {
+		t.Fatalf("GenerateRevealSecret() error %v", err)
+	}
+	if s == (RevealSecret{}) {
+		t.Errorf("GenerateRevealSecret() got zero secret")
+	}
+
+	parsed, err := ParseRevealSecret(s.Hex())
+	if err != nil {
+		t.Fatalf("ParseRevealSecret(%q) error %v", s.Hex(), err)
+	}
+	if parsed != s {
+		t.Errorf("ParseRevealSecret(%q) got %v; want %v", s.Hex(), parsed, s)
+	}
+	if _, err := ParseRevealSecret("0x0102"); err == nil {
+		t.Errorf("ParseRevealSecret(%q) got nil error; want error", "0x0102")
+	}
+
+	if got, want := s.Commitment(), crypto.Keccak256Hash(s[:]); got != want {
+		t.Errorf("%T.Commitment() got %v; want %v", s, got, want)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	secret := RevealSecret{1, 2, 3}
+	// Spanning more than one keystream block and ending in a partial one.
+	data := bytes.Repeat([]byte("solidify"), 9)
+
+	enc := secret.Encrypt(data)
+	if got, want := len(enc), common.HashLength+len(data); got != want {
+		t.Fatalf("len(%T.Encrypt(…)) got %d; want %d", secret, got, want)
+	}
+	if !bytes.Equal(enc, secret.Encrypt(data)) {
+		t.Errorf("%T.Encrypt(…) is not deterministic", secret)
+	}
+
+	// Independently deriving the keystream as done by `BucketCipherLib`.
+	nonce := enc[:common.HashLength]
+	for i, c := range enc[common.HashLength:] {
+		var ctr [32]byte
+		ctr[31] = byte(i / 32)
+		key := crypto.Keccak256(secret[:], nonce, ctr[:])
+		if got, want := c, data[i]^key[i%32]; got != want {
+			t.Fatalf("%T.Encrypt(…) ciphertext byte %d got %#x; want %#x", secret, i, got, want)
+		}
+	}
+
+	dec, err := secret.Decrypt(enc)
+	if err != nil {
+		t.Fatalf("%T.Decrypt(…) error %v", secret, err)
+	}
+	if diff := cmp.Diff(data, dec); diff != "" {
+		t.Errorf("%T.Decrypt(%T.Encrypt(…)) diff (-want +got):\n%s", secret, secret, diff)
+	}
+
+	if dec, _ := (RevealSecret{4}).Decrypt(enc); bytes.Equal(dec, data) {
+		t.Errorf("Decrypt(…) with different secret recovered the data")
+	}
+	if _, err := secret.Decrypt(enc[:10]); err == nil {
+		t.Errorf("%T.Decrypt([too short]) got nil error; want error", secret)
+	}
+}
+
+func TestEncryptedBucket(t *testing.T) {
+	secret := RevealSecret{1}
+	plain := fakeFormattedBucket{
+		fakeBucket: fakeBucket{data: []byte("foo"), numFields: 2},
+		format:     BucketFormat{Flavour: FlavourIndexed, Codec: CodecDeflate, OffsetBits: 16},
+	}
+	b := EncryptBucket(plain, secret)
+
+	want := plain.format
+	want.Codec = CodecDeflateEncrypted
+	if diff := cmp.Diff(want, BucketFormatOf(b)); diff != "" {
+		t.Errorf("BucketFormatOf(%T) diff (-want +got):\n%s", b, diff)
+	}
+	if b.NumFields() != plain.NumFields() || b.UncompressedSize() != plain.UncompressedSize() {
+		t.Errorf("%T does not retain the number of fields and uncompressed size", b)
+	}
+
+	d, err := b.Data()
+	if err != nil {
+		t.Fatalf("%T.Data() error %v", b, err)
+	}
+	if dec, err := secret.Decrypt(d); err != nil || !bytes.Equal(dec, plain.data) {
+		t.Errorf("%T.Decrypt(%T.Data()) got (%q, %v); want (%q, nil)", secret, b, dec, err, plain.data)
+	}
+}
+
+func TestRevealCommitmentOf(t *testing.T) {
+	s1, s2 := RevealSecret{1}, RevealSecret{2}
+	plain := fakeBucket{data: []byte("foo"), numFields: 1}
+	c1 := s1.Commitment()
+
+	tests := []struct {
+		name         string
+		buckets      []Bucket
+		want         *common.Hash
+		wantContains string
+	}{
+		{
+			name:    "unencrypted",
+			buckets: []Bucket{plain, plain},
+		},
+		{
+			name:    "encrypted",
+			buckets: []Bucket{EncryptBucket(plain, s1), EncryptBucket(plain, s1)},
+			want:    &c1,
+		},
+		{
+			name:         "mixed",
+			buckets:      []Bucket{EncryptBucket(plain, s1), plain},
+			wantContains: "1 of 2 buckets are not encrypted",
+		},
+		{
+			name:         "different secrets",
+			buckets:      []Bucket{EncryptBucket(plain, s1), EncryptBucket(plain, s2)},
+			wantContains: "different secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := []fakeStorage{{name: "TestBucketStorage0", buckets: tt.buckets}}
+			got, err := RevealCommitmentOf(stores)
+			if tt.wantContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+					t.Errorf("RevealCommitmentOf(…) got err %v; want containing %q", err, tt.wantContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RevealCommitmentOf(…) error %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("RevealCommitmentOf(…) diff (-want +got):\n%s", diff)
+			}
+
+			mem := new(MemFS)
+			cfg := DefaultGeneratorConfig()
+			cfg.Output = mem
+			if _, err := WriteGroupStorage("Test", []fakeGroup{{"FOO", 2}}, stores, cfg, "gen"); err != nil {
+				t.Fatalf("WriteGroupStorage(…) error %v", err)
+			}
+			manager := string(mem.Files()["gen/TestStorageManager.sol"])
+			if got := strings.Contains(manager, "function reveal(bytes32 secret)"); got != (tt.want != nil) {
+				t.Errorf("WriteGroupStorage(…) wrote manager with reveal path = %t; want %t", got, tt.want != nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package storage
+
+// fakeBucket is a minimal Bucket with fixed data.
+type fakeBucket struct {
+	data      []byte
+	numFields int
+}
+
+func (b fakeBucket) Data() ([]byte, error) { return b.data, nil }
+func (b fakeBucket) UncompressedSize() int { return 2 * len(b.data) }
+func (b fakeBucket) NumFields() int        { return b.numFields }
+
+// fakeStorage is a minimal BucketStorage.
+type fakeStorage struct {
+	name    string
+	buckets []Bucket
+}
+
+func (s fakeStorage) Name() string      { return s.name }
+func (s fakeStorage) Buckets() []Bucket { return s.buckets }
+func (s fakeStorage) NumFields() int {
+	var n int
+	for _, b := range s.buckets {
+		n += b.NumFields()
+	}
+	return n
+}
+func (s fakeStorage) Size() (int, error) {
+	var n int
+	for _, b := range s.buckets {
+		d, _ := b.Data()
+		n += len(d)
+	}
+	return n, nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -13,19 +12,10 @@ import (
 	"github.com/daragao/merkletree"
 	"github.com/proofxyz/solidify/go/types"
 	"go.uber.org/multierr"
-
-	_ "embed"
 )
 
 var (
 	tmplFuncsFeatures = addTemplateFuncs(tmplFuncsCommon, template.FuncMap{
-		"lastTokenLabel": func(x any) int {
-			lastLabel, err := lastTokenLabel(x)
-			if err != nil {
-				panic(fmt.Errorf("lastTokenLabel([stores]): %w", err))
-			}
-			return int(lastLabel)
-		},
 		"reversed": func(s []FeatureGroup) []FeatureGroup {
 			a := make([]FeatureGroup, len(s))
 			for i := 0; i < len(s); i++ {
@@ -48,39 +38,8 @@ var (
 			return gs
 		},
 	})
-
-	//go:embed templates/labelled-storage-mapping.go.tmpl
-	rawLabelledMappingTmpl string
-
-	labelledStorageMappingTmpl = template.Must(
-		template.New("labelled-storage-mapping").Funcs(tmplFuncsFeatures).Parse(rawLabelledMappingTmpl),
-	)
-
-	//go:embed templates/features-lib.go.tmpl
-	rawFeaturesLibTmpl string
-
-	featuresLibTmpl = template.Must(
-		template.New("features-lib").Funcs(tmplFuncsFeatures).Parse(rawFeaturesLibTmpl),
-	)
 )
 
-func last[T any](l []T) T {
-	return l[len(l)-1]
-}
-
-func lastTokenLabel(x any) (uint16, error) {
-	switch v := x.(type) {
-	case BucketStorage:
-		return lastTokenLabel(last(v.Buckets()))
-	case []Bucket:
-		return lastTokenLabel(last(v))
-	case LabelledBucket:
-		return last(v.Labels()), nil
-	default:
-		return 0, fmt.Errorf("type %T not supported by lastTokenLabel()", v)
-	}
-}
-
 // LabelledField is a field with an additional label
 type LabelledField interface {
 	Field
@@ -93,17 +52,127 @@ type LabelledBucket interface {
 	Labels() []uint16
 }
 
+// LabelledMapping contains the precomputed lookup table for a labelled storage
+// mapping.
+type LabelledMapping struct {
+	// Buckets packs `(first label, last label, storageId, bucketId)` for every
+	// bucket in the bundle, sorted by labels.
+	Buckets       PackedTable
+	NumBuckets    int
+	LabelBits     int
+	StorageIDBits int
+	BucketIDBits  int
+}
+
+// FirstLabelShift is the number of bits that a bucket entry has to be shifted
+// to the right to obtain the first label.
+func (m *LabelledMapping) FirstLabelShift() int {
+	return m.LabelBits + m.LastLabelShift()
+}
+
+// LastLabelShift is the number of bits that a bucket entry has to be shifted to
+// the right to obtain the last label (after masking).
+func (m *LabelledMapping) LastLabelShift() int {
+	return m.StorageIDBits + m.BucketIDBits
+}
+
+func newLabelledMapping(stores []BucketStorage) (*LabelledMapping, error) {
+	type boundary struct {
+		first, last uint16
+		storage     int
+		bucket      int
+	}
+
+	var (
+		bs                              []boundary
+		maxLabel, maxStorage, maxBucket int
+	)
+	for i, s := range stores {
+		for j, b := range s.Buckets() {
+			lb, ok := b.(LabelledBucket)
+			if !ok {
+				return nil, fmt.Errorf("bucket %d in storage %q: %T is not a LabelledBucket", j, s.Name(), b)
+			}
+
+			labels := lb.Labels()
+			if len(labels) == 0 {
+				return nil, fmt.Errorf("bucket %d in storage %q is empty", j, s.Name())
+			}
+
+			for k, l := range labels {
+				if k > 0 && l <= labels[k-1] {
+					return nil, fmt.Errorf("labels in bucket %d in storage %q are not strictly increasing: %d after %d", j, s.Name(), l, labels[k-1])
+				}
+			}
+
+			bd := boundary{first: labels[0], last: labels[len(labels)-1], storage: i, bucket: j}
+			if len(bs) > 0 && bd.first <= bs[len(bs)-1].last {
+				return nil, fmt.Errorf("labels in bundle are not strictly increasing: bucket %d in storage %q starts at %d after %d", j, s.Name(), bd.first, bs[len(bs)-1].last)
+			}
+			bs = append(bs, bd)
+
+			if int(bd.last) > maxLabel {
+				maxLabel = int(bd.last)
+			}
+			if i > maxStorage {
+				maxStorage = i
+			}
+			if j > maxBucket {
+				maxBucket = j
+			}
+		}
+	}
+
+	if len(bs) == 0 {
+		return nil, fmt.Errorf("bundle does not contain any buckets")
+	}
+
+	m := &LabelledMapping{
+		NumBuckets:    len(bs),
+		LabelBits:     bitsFor(maxLabel),
+		StorageIDBits: bitsFor(maxStorage),
+		BucketIDBits:  bitsFor(maxBucket),
+	}
+
+	vals := make([]uint64, len(bs))
+	for i, b := range bs {
+		vals[i] = uint64(b.first)<<m.FirstLabelShift() |
+			uint64(b.last)<<m.LastLabelShift() |
+			uint64(b.storage)<<m.BucketIDBits |
+			uint64(b.bucket)
+	}
+
+	var err error
+	m.Buckets, err = newPackedTable(bytesFor(m.FirstLabelShift()+m.LabelBits), vals)
+	if err != nil {
+		return nil, fmt.Errorf("packing bucket table: %v", err)
+	}
+
+	return m, nil
+}
+
 // WriteLabelledStorageMappingFeatures writes the storage mapping to retrieve data from
 // storage contracts.
-func WriteLabelledStorageMappingFeatures[S BucketStorage](name string, stores []S, w io.Writer) error {
-	return labelledStorageMappingTmpl.Execute(w,
-		struct {
-			Name   string
-			Stores []BucketStorage
-		}{
-			Name:   name,
-			Stores: convertStorages(stores),
-		})
+// The mapping performs a binary search over a packed table of the first and
+// last labels in each bucket. The labels across the entire bundle therefore
+// have to be strictly increasing.
+func WriteLabelledStorageMappingFeatures[S BucketStorage](name string, stores []S, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	m, err := newLabelledMapping(convertStorages(stores))
+	if err != nil {
+		return fmt.Errorf("computing lookup table for %q: %w", name, err)
+	}
+
+	return cfg.executeTemplate(LabelledStorageMappingTemplate, w, LabelledStorageMappingData{
+		Config: cfg,
+		Name:   name,
+		Stores: convertStorages(stores),
+		Table:  m,
+	})
 }
 
 // FeatureGroup denotes a certain types of features.
@@ -115,35 +184,41 @@ type FeatureGroup interface {
 
 // WriteFeaturesLib writes a solidity file defining the Features struct and
 // a helper library to work with it.
-func WriteFeaturesLib[F FeatureGroup](groups []F, mt *merkletree.MerkleTree, w io.Writer) error {
-	return featuresLibTmpl.Execute(w,
-		struct {
-			FeatureGroups []FeatureGroup
-			MerkleRoot    []byte
-			NumTokens     int
-		}{
-			FeatureGroups: convertFeatureGroups(groups),
-			MerkleRoot:    mt.MerkleRoot(),
-			NumTokens:     len(mt.Leafs),
-		},
-	)
+func WriteFeaturesLib[F FeatureGroup](groups []F, mt *merkletree.MerkleTree, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.executeTemplate(FeaturesLibTemplate, w, FeaturesLibData{
+		Config:        cfg,
+		FeatureGroups: convertFeatureGroups(groups),
+		MerkleRoot:    mt.MerkleRoot(),
+		NumTokens:     len(mt.Leafs),
+		NumTokensBits: 8 * bytesFor(bitsFor(len(mt.Leafs))),
+	})
 }
 
 // WriteFeaturesContracts is a convenience wrapper that writes all contracts
 // relevant for storing and working with the features on-chain.
-func WriteFeaturesContracts[G FeatureGroup, S BucketStorage](groups []G, stores []S, mt *merkletree.MerkleTree, outputDir string) ([]string, error) {
-	var fs fileGenerator
+func WriteFeaturesContracts[G FeatureGroup, S BucketStorage](groups []G, stores []S, mt *merkletree.MerkleTree, cfg *GeneratorConfig, outputDir string) ([]string, error) {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := fileGenerator{fsys: cfg.Output}
 	storageSubdir := "storage"
 
 	errs := []error{
-		fs.writeSolFile(outputDir, "Features", func(f *os.File) error {
-			return annotateNonNil(WriteFeaturesLib(groups, mt, f), "storeate.WriteFeaturesLib(…)")
+		fs.writeSolFile(outputDir, "Features", func(f io.Writer) error {
+			return annotateNonNil(WriteFeaturesLib(groups, mt, cfg, f), "storeate.WriteFeaturesLib(…)")
 		}),
-		fs.writeSolFile(outputDir, "FeaturesStorageDeployer", func(f *os.File) error {
-			return annotateNonNil(WriteStorageDeployer("Features", "./"+storageSubdir, stores, f), "storage.WriteStorageDeployer(…)")
+		fs.writeSolFile(outputDir, cfg.DeployerName("Features"), func(f io.Writer) error {
+			return annotateNonNil(WriteStorageDeployer("Features", "./"+storageSubdir, stores, cfg, f), "storage.WriteStorageDeployer(…)")
 		}),
-		fs.writeSolFile(outputDir, "FeaturesStorageMapping", func(f *os.File) error {
-			return annotateNonNil(WriteLabelledStorageMappingFeatures("Features", stores, f), "storage.WriteStorageMappingFeatures(…)")
+		fs.writeSolFile(outputDir, cfg.MappingName("Features"), func(f io.Writer) error {
+			return annotateNonNil(WriteLabelledStorageMappingFeatures("Features", stores, cfg, f), "storage.WriteStorageMappingFeatures(…)")
 		}),
 	}
 	if err := multierr.Combine(errs...); err != nil {
@@ -151,8 +226,8 @@ func WriteFeaturesContracts[G FeatureGroup, S BucketStorage](groups []G, stores
 	}
 
 	for _, s := range stores {
-		if err := fs.writeSolFile(filepath.Join(outputDir, storageSubdir), s.Name(), func(f *os.File) error {
-			return annotateNonNil(WriteBucketStorage(s, f), "storage.WriteBucketStorage(…)")
+		if err := fs.writeSolFile(filepath.Join(outputDir, storageSubdir), s.Name(), func(f io.Writer) error {
+			return annotateNonNil(WriteBucketStorage(s, cfg, f), "storage.WriteBucketStorage(…)")
 		}); err != nil {
 			return nil, err
 		}
@@ -184,17 +259,11 @@ func WriteFeaturesJSON[G FeatureGroup](gs []G, ts []types.Token, w io.Writer) er
 }
 
 // WriteFeaturesJSONToFile is a convenience wrapper to write the JSON created by
-// `WriteFeaturesJSON` to a file.
-func WriteFeaturesJSONToFile[G FeatureGroup](gs []G, ts []types.Token, path string) (retErr error) {
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf(" os.Create(%q): %w", path, err)
-	}
-	defer (func() {
-		retErr = multierr.Combine(retErr, f.Close())
-	})()
-
-	return WriteFeaturesJSON(gs, ts, f)
+// `WriteFeaturesJSON` to a file in fsys. A nil fsys writes to disk.
+func WriteFeaturesJSONToFile[G FeatureGroup](gs []G, ts []types.Token, fsys WritableFS, path string) error {
+	return writeFile(fsys, path, func(w io.Writer) error {
+		return WriteFeaturesJSON(gs, ts, w)
+	})
 }
 
 func convertFeatureGroups[F FeatureGroup](fs []F) []FeatureGroup {
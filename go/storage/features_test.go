@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeLabelledBucket struct {
+	fakeBucket
+	labels []uint16
+}
+
+func (b fakeLabelledBucket) Labels() []uint16 { return b.labels }
+
+func TestNewLabelledMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		stores  []BucketStorage
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "Two storages",
+			stores: []BucketStorage{
+				fakeStorage{buckets: []Bucket{
+					fakeLabelledBucket{labels: []uint16{0, 1}},
+					fakeLabelledBucket{labels: []uint16{2, 6}},
+				}},
+				fakeStorage{buckets: []Bucket{
+					fakeLabelledBucket{labels: []uint16{7}},
+				}},
+			},
+			// | first (3 bits) | last (3 bits) | storageId (1 bit) | bucketId (1 bit) |
+			want: []byte{0b000_001_0_0, 0b010_110_0_1, 0b111_111_1_0},
+		},
+		{
+			name: "Overlapping buckets",
+			stores: []BucketStorage{
+				fakeStorage{buckets: []Bucket{
+					fakeLabelledBucket{labels: []uint16{0, 3}},
+					fakeLabelledBucket{labels: []uint16{2, 6}},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unlabelled bucket",
+			stores: []BucketStorage{
+				fakeStorage{buckets: []Bucket{fakeBucket{}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newLabelledMapping(tt.stores)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLabelledMapping([stores]) error %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if diff := cmp.Diff(tt.want, got.Buckets.Data); diff != "" {
+				t.Errorf("newLabelledMapping([stores]) table diff (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
@@ -1,16 +1,86 @@
 package storage
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 )
 
+// ErrForgeNotFound is returned by FormatSol if forge is not installed.
+var ErrForgeNotFound = errors.New("forge not found")
+
 // FormatSol formats a list of solidity files using the forge formatter.
+//
+// All generated contracts are already formatted canonically (see
+// solfmt.Format), so this is an optional post-processing step to apply the
+// formatting settings of a Foundry project. The diagnostics of forge are
+// included in the returned error.
 func FormatSol(files []string) error {
-	cmd := exec.Command("forge", "fmt")
-	cmd.Args = append(cmd.Args, files...)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("formatting sol files: %v", err)
+	forge, err := exec.LookPath("forge")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrForgeNotFound, err)
+	}
+
+	cmd := exec.Command(forge, append([]string{"fmt"}, files...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("forge fmt: %v\n%s", err, bytes.TrimSpace(out))
 	}
 	return nil
 }
+
+// A Formatter formats Solidity sources keyed by their paths, returning the
+// formatted sources under the same keys.
+type Formatter func(srcs map[string][]byte) (map[string][]byte, error)
+
+// FormatSolSources is a Formatter that formats in-memory sources with the
+// forge formatter, analogous to FormatSol. It can be used as Checker.Format if
+// the files on disk were post-processed with FormatSol. The sources are
+// formatted in a temporary directory so the formatting settings are those of
+// the project in the working directory.
+func FormatSolSources(srcs map[string][]byte) (_ map[string][]byte, retErr error) {
+	if len(srcs) == 0 {
+		return map[string][]byte{}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "solidify-fmt-")
+	if err != nil {
+		return nil, fmt.Errorf("os.MkdirTemp(): %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); retErr == nil {
+			retErr = err
+		}
+	}()
+
+	paths := make([]string, 0, len(srcs))
+	for p := range srcs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	tmp := make([]string, len(paths))
+	for i, p := range paths {
+		tmp[i] = filepath.Join(dir, fmt.Sprintf("%d.sol", i))
+		if err := os.WriteFile(tmp[i], srcs[p], 0644); err != nil {
+			return nil, fmt.Errorf("os.WriteFile(%q): %w", tmp[i], err)
+		}
+	}
+
+	if err := FormatSol(tmp); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]byte, len(paths))
+	for i, p := range paths {
+		b, err := os.ReadFile(tmp[i])
+		if err != nil {
+			return nil, fmt.Errorf("os.ReadFile(%q): %w", tmp[i], err)
+		}
+		out[p] = b
+	}
+	return out, nil
+}
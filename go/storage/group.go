@@ -1,34 +1,12 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
 	"io"
-	"os"
 	"path/filepath"
-	"text/template"
 
 	"go.uber.org/multierr"
-
-	_ "embed"
-)
-
-var (
-	tmplFuncsGroups = addTemplateFuncs(tmplFuncsCommon, template.FuncMap{
-		"bitsNumFields": func(stores []BucketStorage) int {
-			for _, s := range stores {
-				if s.NumFields() > 255 {
-					return 16
-				}
-			}
-			return 8
-		},
-	})
-
-	//go:embed templates/sequential-storage-mapping.go.tmpl
-	rawSequentialStorageMappingTmpl string
-
-	sequentialStorageMappingTmpl = template.Must(
-		template.New("sequential-storage-mapping").Funcs(tmplFuncsGroups).Parse(rawSequentialStorageMappingTmpl),
-	)
 )
 
 // FieldsGroup is a generic grouping of fields (e.g. all layers with a certain
@@ -53,33 +31,153 @@ type FieldsGroup interface {
 //     |                               BucketStorage 1
 //     │                    Bucket 0 ──┘
 //     └── qux <> Field 0 ──┘
-func WriteSequentialStorageMapping[G FieldsGroup, S BucketStorage](name string, groups []G, stores []S, w io.Writer) error {
-	return sequentialStorageMappingTmpl.Execute(w,
-		struct {
-			Name         string
-			FieldsGroups []G
-			Stores       []BucketStorage
-		}{
-			Name:         name,
-			FieldsGroups: groups,
-			Stores:       convertStorages(stores),
-		},
-	)
+func WriteSequentialStorageMapping[G FieldsGroup, S BucketStorage](name string, groups []G, stores []S, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	c, err := newFieldCounts(groups, convertStorages(stores))
+	if err != nil {
+		return fmt.Errorf("computing field counts for %q: %w", name, err)
+	}
+
+	return cfg.executeTemplate(SequentialStorageMappingTemplate, w, SequentialStorageMappingData{
+		Config:       cfg,
+		Name:         name,
+		FieldsGroups: convertFieldsGroups(groups),
+		Stores:       convertStorages(stores),
+		Counts:       c,
+	})
+}
+
+// FieldCounts contains the field counts of a sequential mapping together with
+// the integer widths needed to represent them.
+type FieldCounts struct {
+	// GroupBits is the number of bits used for the number of fields in each
+	// group.
+	GroupBits int
+	// StorageBits is the number of bits used for the number of fields in each
+	// storage.
+	StorageBits int
+	// BucketBytes is the number of bytes used for the number of fields in
+	// each bucket.
+	BucketBytes int
+	// Buckets contains the packed number of fields per bucket for each
+	// storage, using entries of BucketBytes each.
+	Buckets []PackedTable
+}
+
+// BucketShift is the number of bits that a word loaded at an entry of Buckets
+// has to be shifted to the right to obtain the entry.
+func (c *FieldCounts) BucketShift() int {
+	return 256 - 8*c.BucketBytes
+}
+
+func newFieldCounts[G FieldsGroup](groups []G, stores []BucketStorage) (*FieldCounts, error) {
+	// Solidity does not support empty array literals, which the mapping uses
+	// for the number of fields per storage.
+	if len(stores) == 0 {
+		return nil, errors.New("no storages")
+	}
+
+	var maxGroup, maxStorage, maxBucket int
+	for _, g := range groups {
+		if n := g.NumFields(); n > maxGroup {
+			maxGroup = n
+		}
+	}
+	for _, s := range stores {
+		if n := s.NumFields(); n > maxStorage {
+			maxStorage = n
+		}
+		for _, b := range s.Buckets() {
+			if n := b.NumFields(); n > maxBucket {
+				maxBucket = n
+			}
+		}
+	}
+
+	groupBytes, err := fieldCountBytes(maxGroup)
+	if err != nil {
+		return nil, fmt.Errorf("fields per group: %w", err)
+	}
+	storageBytes, err := fieldCountBytes(maxStorage)
+	if err != nil {
+		return nil, fmt.Errorf("fields per storage: %w", err)
+	}
+	bucketBytes, err := fieldCountBytes(maxBucket)
+	if err != nil {
+		return nil, fmt.Errorf("fields per bucket: %w", err)
+	}
+
+	c := &FieldCounts{
+		GroupBits:   8 * groupBytes,
+		StorageBits: 8 * storageBytes,
+		BucketBytes: bucketBytes,
+	}
+	for _, s := range stores {
+		t, err := packNumFieldsPerBucket(s, bucketBytes)
+		if err != nil {
+			return nil, err
+		}
+		c.Buckets = append(c.Buckets, t)
+	}
+
+	return c, nil
 }
 
 // WriteGroupStorage is a convenience wrapper that writes all contracts relating
 // to a grouping of fields and corresponding BucketStorages to a given output
-// directory. Returns the paths of then written files.
-func WriteGroupStorage[G FieldsGroup, S BucketStorage](name string, groups []G, stores []S, outputDir string) ([]string, error) {
-	var fs fileGenerator
+// directory, including a storage manager (see WriteStorageManager). Returns the
+// paths of then written files.
+func WriteGroupStorage[G FieldsGroup, S BucketStorage](name string, groups []G, stores []S, cfg *GeneratorConfig, outputDir string) ([]string, error) {
+	return writeGroupStorage(name, groups, stores, cfg, outputDir, func(cfg *GeneratorConfig, w io.Writer) error {
+		return annotateNonNil(WriteSequentialStorageMapping(name, groups, stores, cfg, w), "storage.WriteSequentialStorageMapping(%q, …)", name)
+	})
+}
+
+// WriteTableGroupStorage is analogous to WriteGroupStorage but writes a table
+// storage mapping instead (see WriteTableStorageMapping).
+func WriteTableGroupStorage[G FieldsGroup, S BucketStorage](name string, groups []G, stores []S, cfg *GeneratorConfig, outputDir string) ([]string, error) {
+	return writeGroupStorage(name, groups, stores, cfg, outputDir, func(cfg *GeneratorConfig, w io.Writer) error {
+		return annotateNonNil(WriteTableStorageMapping(name, groups, stores, cfg, w), "storage.WriteTableStorageMapping(%q, …)", name)
+	})
+}
+
+// WriteTableGroupStorageFromLocations is analogous to WriteTableGroupStorage
+// but for explicitly given field locations (see
+// WriteTableStorageMappingFromLocations), e.g. if fields were not packed in
+// the order of the groups.
+func WriteTableGroupStorageFromLocations[G FieldsGroup, S BucketStorage](name string, groups []G, stores []S, locs [][]FieldLocation, cfg *GeneratorConfig, outputDir string) ([]string, error) {
+	return writeGroupStorage(name, groups, stores, cfg, outputDir, func(cfg *GeneratorConfig, w io.Writer) error {
+		return annotateNonNil(WriteTableStorageMappingFromLocations(name, groups, locs, cfg, w), "storage.WriteTableStorageMappingFromLocations(%q, …)", name)
+	})
+}
+
+func writeGroupStorage[G FieldsGroup, S BucketStorage](name string, groups []G, stores []S, cfg *GeneratorConfig, outputDir string, writeMapping func(*GeneratorConfig, io.Writer) error) ([]string, error) {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	commitment, err := RevealCommitmentOf(stores)
+	if err != nil {
+		return nil, fmt.Errorf("RevealCommitmentOf(%q): %w", name, err)
+	}
+
+	fs := fileGenerator{fsys: cfg.Output}
 	storageSubdir := "storage"
 
 	errs := []error{
-		fs.writeSolFile(outputDir, name+"StorageDeployer", func(f *os.File) error {
-			return annotateNonNil(WriteStorageDeployer(name, "./"+storageSubdir, stores, f), "storage.WriteStorageDeployer(%q, …)", name)
+		fs.writeSolFile(outputDir, cfg.DeployerName(name), func(f io.Writer) error {
+			return annotateNonNil(WriteStorageDeployer(name, "./"+storageSubdir, stores, cfg, f), "storage.WriteStorageDeployer(%q, …)", name)
+		}),
+		fs.writeSolFile(outputDir, cfg.MappingName(name), func(f io.Writer) error {
+			return writeMapping(cfg, f)
 		}),
-		fs.writeSolFile(outputDir, name+"StorageMapping", func(f *os.File) error {
-			return annotateNonNil(WriteSequentialStorageMapping(name, groups, stores, f), "storage.WriteSequentialStorageMapping(%q, …)", name)
+		fs.writeSolFile(outputDir, cfg.ManagerName(name), func(f io.Writer) error {
+			return annotateNonNil(writeStorageManager(name, groups, managerOptions{storageBundle: name, commitment: commitment}, cfg, f), "storage.writeStorageManager(%q, …)", name)
 		}),
 	}
 	if err := multierr.Combine(errs...); err != nil {
@@ -87,8 +185,8 @@ func WriteGroupStorage[G FieldsGroup, S BucketStorage](name string, groups []G,
 	}
 
 	for _, s := range stores {
-		if err := fs.writeSolFile(filepath.Join(outputDir, storageSubdir), s.Name(), func(f *os.File) error {
-			return annotateNonNil(WriteBucketStorage(s, f), "storage.WriteBucketStorage(…)")
+		if err := fs.writeSolFile(filepath.Join(outputDir, storageSubdir), s.Name(), func(f io.Writer) error {
+			return annotateNonNil(WriteBucketStorage(s, cfg, f), "storage.WriteBucketStorage(…)")
 		}); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteSequentialStorageMappingFieldCountWidths(t *testing.T) {
+	tests := []struct {
+		name         string
+		groups       []fakeGroup
+		stores       []fakeStorage
+		wantContains []string
+		wantErr      bool
+	}{
+		{
+			name:   "Small",
+			groups: []fakeGroup{{"FOO", 2}, {"BAR", 3}},
+			stores: []fakeStorage{
+				{name: "S0", buckets: []Bucket{fakeBucket{numFields: 2}, fakeBucket{numFields: 3}}},
+			},
+			wantContains: []string{
+				"uint8[2] memory numTestsPerTestType",
+				"uint8[1] memory numFieldsPerStorage",
+				`return hex"0203";`,
+			},
+		},
+		{
+			name:   "More than 255 fields",
+			groups: []fakeGroup{{"FOO", 2}, {"BAR", 300}},
+			stores: []fakeStorage{
+				{name: "S0", buckets: []Bucket{fakeBucket{numFields: 2}, fakeBucket{numFields: 200}}},
+				{name: "S1", buckets: []Bucket{fakeBucket{numFields: 100}}},
+			},
+			wantContains: []string{
+				"uint16[2] memory numTestsPerTestType",
+				"uint16(2)",
+				"uint16(300)",
+				"uint8(202)",
+				"uint8[2] memory numFieldsPerStorage",
+				`return hex"02c8";`,
+				`return hex"64";`,
+			},
+		},
+		{
+			name:   "More than 255 fields per bucket",
+			groups: []fakeGroup{{"FOO", 70000}},
+			stores: []fakeStorage{
+				{name: "S0", buckets: []Bucket{fakeBucket{numFields: 300}, fakeBucket{numFields: 69700}}},
+			},
+			wantContains: []string{
+				"uint24[1] memory numTestsPerTestType",
+				"uint24[1] memory numFieldsPerStorage",
+				"numFieldsPerBucket.length / 3",
+				"shr(232, ",
+				`return hex"00012c011044";`,
+			},
+		},
+		{
+			name:    "No storages",
+			groups:  []fakeGroup{{"FOO", 1}},
+			wantErr: true,
+		},
+		{
+			name:   "Exceeding supported width",
+			groups: []fakeGroup{{"FOO", 1 << 33}},
+			stores: []fakeStorage{
+				{name: "S0", buckets: []Bucket{fakeBucket{numFields: 1 << 33}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := WriteSequentialStorageMapping("Test", tt.groups, tt.stores, nil, &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteSequentialStorageMapping(…) error %v, wantErr %t", err, tt.wantErr)
+			}
+
+			got := buf.String()
+			for _, want := range tt.wantContains {
+				if !strings.Contains(got, want) {
+					t.Errorf("WriteSequentialStorageMapping(…) does not contain %q", want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// BucketHash computes the keccak256 hash of the compressed data of a bucket.
+// This matches the `bucketHash` values exposed by the generated storage
+// contracts.
+func BucketHash(b Bucket) (common.Hash, error) {
+	d, err := b.Data()
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("%T.Data(): %w", b, err)
+	}
+	return crypto.Keccak256Hash(d), nil
+}
+
+// BucketHashes computes the hashes of all buckets in a storage.
+func BucketHashes(s BucketStorage) ([]common.Hash, error) {
+	hs, _, err := bucketHashesAndSizes(s)
+	return hs, err
+}
+
+// bucketHashesAndSizes computes the hashes and compressed sizes of all buckets
+// in a storage, computing the data of every bucket only once.
+func bucketHashesAndSizes(s BucketStorage) ([]common.Hash, []int, error) {
+	bs := s.Buckets()
+	hs := make([]common.Hash, len(bs))
+	sizes := make([]int, len(bs))
+	for i, b := range bs {
+		d, err := b.Data()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%T.Data() [bucket %d of %s]: %w", b, i, s.Name(), err)
+		}
+		hs[i] = crypto.Keccak256Hash(d)
+		sizes[i] = len(d)
+	}
+	return hs, sizes, nil
+}
+
+// StorageRoot computes the root hash of a storage, defined as the keccak256
+// hash of its tightly packed bucket hashes, i.e.
+// `keccak256(abi.encodePacked(bucketHash(0), ..., bucketHash(n-1)))`.
+func StorageRoot(s BucketStorage) (common.Hash, error) {
+	hs, err := BucketHashes(s)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return storageRootFromHashes(hs), nil
+}
+
+func storageRootFromHashes(hs []common.Hash) common.Hash {
+	bs := make([][]byte, len(hs))
+	for i, h := range hs {
+		bs[i] = h.Bytes()
+	}
+	return crypto.Keccak256Hash(bs...)
+}
+
+// A BundleManifest summarises the contents of a bundle of BucketStorages,
+// allowing deployed contracts to be audited against a local build.
+type BundleManifest struct {
+	Name     string            `json:"name"`
+	Storages []StorageManifest `json:"storages"`
+}
+
+// StorageManifest summarises the contents of a single BucketStorage.
+type StorageManifest struct {
+	Name      string           `json:"name"`
+	Root      common.Hash      `json:"root"`
+	Format    common.Hash      `json:"format"`
+	NumFields int              `json:"numFields"`
+	Size      int              `json:"size"`
+	Buckets   []BucketManifest `json:"buckets"`
+}
+
+// BucketManifest summarises a single Bucket.
+type BucketManifest struct {
+	Hash             common.Hash `json:"hash"`
+	Format           common.Hash `json:"format"`
+	NumFields        int         `json:"numFields"`
+	Size             int         `json:"size"`
+	UncompressedSize int         `json:"uncompressedSize"`
+}
+
+// NewBundleManifest computes the manifest of a bundle of BucketStorages.
+func NewBundleManifest[S BucketStorage](name string, stores []S) (*BundleManifest, error) {
+	m := &BundleManifest{
+		Name:     name,
+		Storages: make([]StorageManifest, len(stores)),
+	}
+
+	for i, s := range stores {
+		sm := StorageManifest{
+			Name:      s.Name(),
+			Format:    StorageFormatOf(s).Descriptor(),
+			NumFields: s.NumFields(),
+		}
+
+		hs, sizes, err := bucketHashesAndSizes(s)
+		if err != nil {
+			return nil, err
+		}
+		for j, b := range s.Buckets() {
+			sm.Size += sizes[j]
+			sm.Buckets = append(sm.Buckets, BucketManifest{
+				Hash:             hs[j],
+				Format:           BucketFormatOf(b).Descriptor(),
+				NumFields:        b.NumFields(),
+				Size:             sizes[j],
+				UncompressedSize: b.UncompressedSize(),
+			})
+		}
+		sm.Root = storageRootFromHashes(hs)
+
+		m.Storages[i] = sm
+	}
+
+	return m, nil
+}
+
+// WriteBundleManifest writes the manifest of a bundle of BucketStorages as
+// JSON.
+func WriteBundleManifest[S BucketStorage](name string, stores []S, w io.Writer) error {
+	m, err := NewBundleManifest(name, stores)
+	if err != nil {
+		return fmt.Errorf("NewBundleManifest(%q, …): %w", name, err)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(m); err != nil {
+		return fmt.Errorf("%T.Encode(%T): %w", enc, m, err)
+	}
+
+	return nil
+}
+
+// WriteBundleManifestToFile is a convenience wrapper to write the JSON created
+// by `WriteBundleManifest` to a file in fsys. A nil fsys writes to disk.
+func WriteBundleManifestToFile[S BucketStorage](name string, stores []S, fsys WritableFS, path string) error {
+	return writeFile(fsys, path, func(w io.Writer) error {
+		return WriteBundleManifest(name, stores, w)
+	})
+}
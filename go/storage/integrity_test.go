@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStorageRoot(t *testing.T) {
+	s := fakeStorage{
+		name: "Foo",
+		buckets: []Bucket{
+			fakeBucket{data: []byte{1, 2, 3}, numFields: 1},
+			fakeBucket{data: []byte{4, 5}, numFields: 2},
+		},
+	}
+
+	h0 := crypto.Keccak256([]byte{1, 2, 3})
+	h1 := crypto.Keccak256([]byte{4, 5})
+	want := crypto.Keccak256Hash(append(h0, h1...))
+
+	got, err := StorageRoot(s)
+	if err != nil {
+		t.Fatalf("StorageRoot(%+v) error %v", s, err)
+	}
+	if got != want {
+		t.Errorf("StorageRoot(%+v) = %v, want %v", s, got, want)
+	}
+}
+
+func TestNewBundleManifest(t *testing.T) {
+	s := fakeStorage{
+		name: "Foo",
+		buckets: []Bucket{
+			fakeBucket{data: []byte{1, 2, 3}, numFields: 1},
+			fakeBucket{data: []byte{4, 5}, numFields: 2},
+		},
+	}
+
+	root, err := StorageRoot(s)
+	if err != nil {
+		t.Fatalf("StorageRoot(%+v) error %v", s, err)
+	}
+
+	custom := BucketFormat{Flavour: FlavourCustom, Codec: CodecDeflate}.Descriptor()
+
+	want := &BundleManifest{
+		Name: "Bar",
+		Storages: []StorageManifest{
+			{
+				Name:      "Foo",
+				Root:      root,
+				Format:    custom,
+				NumFields: 3,
+				Size:      5,
+				Buckets: []BucketManifest{
+					{
+						Hash:             common.BytesToHash(crypto.Keccak256([]byte{1, 2, 3})),
+						Format:           custom,
+						NumFields:        1,
+						Size:             3,
+						UncompressedSize: 6,
+					},
+					{
+						Hash:             common.BytesToHash(crypto.Keccak256([]byte{4, 5})),
+						Format:           custom,
+						NumFields:        2,
+						Size:             2,
+						UncompressedSize: 4,
+					},
+				},
+			},
+		},
+	}
+
+	got, err := NewBundleManifest("Bar", []fakeStorage{s})
+	if err != nil {
+		t.Fatalf("NewBundleManifest(%q, …) error %v", "Bar", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("NewBundleManifest(%q, …) diff (-want +got):\n%v", "Bar", diff)
+	}
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// FieldType is the Solidity type as which the fields of a group are returned by
+// the generated storage manager.
+type FieldType int
+
+// Field types supported by the storage manager.
+const (
+	// FieldTypeBytes returns fields as raw `bytes`.
+	FieldTypeBytes FieldType = iota
+	// FieldTypeString returns fields as `string`.
+	FieldTypeString
+)
+
+// String returns the name of the Solidity type.
+func (t FieldType) String() string {
+	if t == FieldTypeString {
+		return "string"
+	}
+	return "bytes"
+}
+
+// A TypedFieldsGroup is a FieldsGroup that specifies the type of its fields.
+type TypedFieldsGroup interface {
+	FieldsGroup
+	FieldType() FieldType
+}
+
+// FieldTypeOf returns the type of the fields in a given group. Groups that do
+// not implement TypedFieldsGroup are assumed to contain raw bytes.
+func FieldTypeOf(g FieldsGroup) FieldType {
+	if t, ok := g.(TypedFieldsGroup); ok {
+		return t.FieldType()
+	}
+	return FieldTypeBytes
+}
+
+// ManagedGroup is a FieldsGroup as seen by the storage manager template.
+type ManagedGroup struct {
+	// Name is the name of the group.
+	Name string
+	// Type is the type of the fields in the group (see FieldTypeOf).
+	Type FieldType
+}
+
+// WriteStorageManager writes a contract holding the bundle deployed by the
+// corresponding storage deployer (see WriteStorageDeployer) and exposing a
+// typed `load<Group>(index)` function for each group. Fields are located using
+// the `<name>StorageMapping` library, which can be either sequential or table
+// based.
+// If all groups are of the same FieldType, the generic `load<name>(type,
+// index)` also returns this type, and `bytes` otherwise.
+// The names of the deployer and mapping are derived from the given config and
+// have to match the ones used to generate them.
+func WriteStorageManager[G FieldsGroup](name string, groups []G, cfg *GeneratorConfig, w io.Writer) error {
+	return writeStorageManager(name, groups, managerOptions{storageBundle: name}, cfg, w)
+}
+
+// WriteRevealStorageManager is analogous to WriteStorageManager for bundles
+// whose buckets are encrypted (see EncryptedBucket). The manager stores the
+// given commitment and exposes `reveal(secret)`, after which loaded buckets
+// are decrypted transparently; loads revert before.
+func WriteRevealStorageManager[G FieldsGroup](name string, groups []G, commitment common.Hash, cfg *GeneratorConfig, w io.Writer) error {
+	return writeStorageManager(name, groups, managerOptions{storageBundle: name, commitment: &commitment}, cfg, w)
+}
+
+// WriteStaticStorageManager is analogous to WriteStorageManager but resolves
+// the storages with the library written by WriteStorageAddresses instead of
+// holding a bundle. The resulting contract has no constructor arguments and
+// no state.
+func WriteStaticStorageManager[G FieldsGroup](name string, groups []G, cfg *GeneratorConfig, w io.Writer) error {
+	return writeStorageManager(name, groups, managerOptions{storageBundle: name, static: true}, cfg, w)
+}
+
+// WriteSharedStorageManager is analogous to WriteStorageManager but holds the
+// bundle deployed by the storage deployer of storageBundle, whose storages
+// are shared with other bundles (see WriteSharedGroupStorage).
+func WriteSharedStorageManager[G FieldsGroup](name, storageBundle string, groups []G, cfg *GeneratorConfig, w io.Writer) error {
+	return writeStorageManager(name, groups, managerOptions{storageBundle: storageBundle}, cfg, w)
+}
+
+// managerOptions are the variations of the generated storage manager.
+type managerOptions struct {
+	// storageBundle is the name of the bundle whose deployer holds the
+	// storages.
+	storageBundle string
+	// static resolves storages via the addresses library.
+	static bool
+	// commitment, if not nil, adds a reveal path for encrypted buckets.
+	commitment *common.Hash
+}
+
+func writeStorageManager[G FieldsGroup](name string, groups []G, opts managerOptions, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	gs := make([]ManagedGroup, len(groups))
+	for i, g := range groups {
+		gs[i] = ManagedGroup{Name: g.Name(), Type: FieldTypeOf(g)}
+	}
+
+	typ := FieldTypeBytes
+	if len(gs) > 0 {
+		typ = gs[0].Type
+		for _, g := range gs[1:] {
+			if g.Type != typ {
+				typ = FieldTypeBytes
+				break
+			}
+		}
+	}
+
+	return cfg.executeTemplate(StorageManagerTemplate, w, StorageManagerData{
+		Config:           cfg,
+		Name:             name,
+		StorageBundle:    opts.storageBundle,
+		Groups:           gs,
+		Type:             typ,
+		Static:           opts.static,
+		RevealCommitment: opts.commitment,
+	})
+}
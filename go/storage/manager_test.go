@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// fakeTypedGroup is a fakeGroup with a fixed field type.
+type fakeTypedGroup struct {
+	fakeGroup
+	typ FieldType
+}
+
+func (g fakeTypedGroup) FieldType() FieldType { return g.typ }
+
+func TestWriteStorageManager(t *testing.T) {
+	tests := []struct {
+		name         string
+		groups       []FieldsGroup
+		wantContains []string
+	}{
+		{
+			name: "Untyped",
+			groups: []FieldsGroup{
+				fakeGroup{"FOO", 2},
+				fakeGroup{"BAR", 3},
+			},
+			wantContains: []string{
+				"contract TestStorageManager {",
+				"function loadTest(TestType testType, uint256 index)\n        public\n        view\n        returns (bytes memory)",
+				"function loadFOO(uint256 index) public view returns (bytes memory) {\n        return bytes(_load(TestType.FOO, index));",
+				"function loadBAR(uint256 index) public view returns (bytes memory) {\n        return bytes(_load(TestType.BAR, index));",
+			},
+		},
+		{
+			name: "Strings",
+			groups: []FieldsGroup{
+				fakeTypedGroup{fakeGroup{"FOO", 2}, FieldTypeString},
+				fakeTypedGroup{fakeGroup{"BAR", 3}, FieldTypeString},
+			},
+			wantContains: []string{
+				"returns (string memory)\n    {\n        return string(_load(testType, index));",
+				"function loadFOO(uint256 index) public view returns (string memory) {",
+				"function loadBAR(uint256 index) public view returns (string memory) {",
+			},
+		},
+		{
+			name: "Mixed",
+			groups: []FieldsGroup{
+				fakeTypedGroup{fakeGroup{"FOO", 2}, FieldTypeString},
+				fakeGroup{"BAR", 3},
+			},
+			wantContains: []string{
+				"returns (bytes memory)\n    {\n        return bytes(_load(testType, index));",
+				"function loadFOO(uint256 index) public view returns (string memory) {",
+				"function loadBAR(uint256 index) public view returns (bytes memory) {",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteStorageManager("Test", tt.groups, nil, &buf); err != nil {
+				t.Fatalf("WriteStorageManager(…) error %v", err)
+			}
+
+			got := buf.String()
+			for _, want := range tt.wantContains {
+				if !strings.Contains(got, want) {
+					t.Errorf("WriteStorageManager(…) does not contain %q", want)
+				}
+			}
+		})
+	}
+}
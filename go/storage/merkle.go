@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// MerkleFieldLeaf computes the leaf of a field with a given index in a given
+// group, i.e. `keccak256(bytes.concat(keccak256(abi.encodePacked(
+// uint256(group), uint256(index), data))))`. This matches
+// `MerkleFieldLib.leaf`.
+func MerkleFieldLeaf(group, index int, data []byte) common.Hash {
+	inner := crypto.Keccak256(
+		math.U256Bytes(big.NewInt(int64(group))),
+		math.U256Bytes(big.NewInt(int64(index))),
+		data,
+	)
+	return crypto.Keccak256Hash(inner)
+}
+
+// hashMerklePair hashes two nodes in ascending order, as done by OpenZeppelin's
+// `MerkleProof`.
+func hashMerklePair(a, b common.Hash) common.Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return crypto.Keccak256Hash(a[:], b[:])
+}
+
+// VerifyMerkleFieldProof checks whether a field is part of the tree with the
+// given root. This matches `MerkleFieldLib.verify`.
+func VerifyMerkleFieldProof(root common.Hash, group, index int, data []byte, proof []common.Hash) bool {
+	node := MerkleFieldLeaf(group, index, data)
+	for _, p := range proof {
+		node = hashMerklePair(node, p)
+	}
+	return node == root
+}
+
+// A FieldsMerkleTree is a Merkle tree over the encoded fields of a bundle,
+// allowing the fields to be supplied in calldata and verified against the root
+// instead of being deployed in BucketStorages (see WriteMerkleFieldVerifier).
+// The leaves are ordered by group and index, and nodes without a sibling are
+// promoted to the next level unchanged.
+type FieldsMerkleTree struct {
+	groups []string
+	data   [][][]byte
+	// offsets[g] is the index of the leaf of the first field in group g.
+	offsets []int
+	// levels[0] are the leaves and the last level contains only the root.
+	levels [][]common.Hash
+}
+
+// NewFieldsMerkleTree computes the Merkle tree over the given fields, indexed
+// by group and field index in that group.
+func NewFieldsMerkleTree[G FieldsGroup](groups []G, fields [][]Field) (*FieldsMerkleTree, error) {
+	if len(fields) != len(groups) {
+		return nil, fmt.Errorf("got fields for %d groups, want %d", len(fields), len(groups))
+	}
+
+	t := &FieldsMerkleTree{
+		groups:  make([]string, len(groups)),
+		data:    make([][][]byte, len(groups)),
+		offsets: make([]int, len(groups)),
+	}
+
+	var leaves []common.Hash
+	for i, g := range groups {
+		if got, want := len(fields[i]), g.NumFields(); got != want {
+			return nil, fmt.Errorf("got %d fields for group %q, want %d", got, g.Name(), want)
+		}
+
+		t.groups[i] = g.Name()
+		t.offsets[i] = len(leaves)
+		t.data[i] = make([][]byte, len(fields[i]))
+		for j, f := range fields[i] {
+			d, err := f.Encode()
+			if err != nil {
+				return nil, fmt.Errorf("%T.Encode() [field %d of group %q]: %w", f, j, g.Name(), err)
+			}
+			t.data[i][j] = d
+			leaves = append(leaves, MerkleFieldLeaf(i, j, d))
+		}
+	}
+	if len(leaves) == 0 {
+		return nil, fmt.Errorf("tree does not contain any fields")
+	}
+
+	t.levels = [][]common.Hash{leaves}
+	for lvl := leaves; len(lvl) > 1; {
+		next := make([]common.Hash, (len(lvl)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(lvl) {
+				next[i] = hashMerklePair(lvl[2*i], lvl[2*i+1])
+			} else {
+				next[i] = lvl[2*i]
+			}
+		}
+		t.levels = append(t.levels, next)
+		lvl = next
+	}
+
+	return t, nil
+}
+
+// Root returns the root of the tree.
+func (t *FieldsMerkleTree) Root() common.Hash {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// NumFields returns the total number of fields in the tree.
+func (t *FieldsMerkleTree) NumFields() int {
+	return len(t.levels[0])
+}
+
+// Proof returns the proof for the field with a given index in a given group.
+func (t *FieldsMerkleTree) Proof(group, index int) ([]common.Hash, error) {
+	if group < 0 || group >= len(t.data) {
+		return nil, fmt.Errorf("group %d out of range [0, %d)", group, len(t.data))
+	}
+	if index < 0 || index >= len(t.data[group]) {
+		return nil, fmt.Errorf("index %d out of range [0, %d) for group %q", index, len(t.data[group]), t.groups[group])
+	}
+
+	var proof []common.Hash
+	pos := t.offsets[group] + index
+	for _, lvl := range t.levels[:len(t.levels)-1] {
+		if sib := pos ^ 1; sib < len(lvl) {
+			proof = append(proof, lvl[sib])
+		}
+		pos /= 2
+	}
+	return proof, nil
+}
+
+// MerkleFieldsProofs contains the data and proofs of all fields in a
+// FieldsMerkleTree, to be supplied in calldata to the generated verifier.
+type MerkleFieldsProofs struct {
+	Name   string              `json:"name"`
+	Root   common.Hash         `json:"root"`
+	Groups []MerkleGroupProofs `json:"groups"`
+}
+
+// MerkleGroupProofs contains the data and proofs of all fields in a group.
+type MerkleGroupProofs struct {
+	Name   string             `json:"name"`
+	Fields []MerkleFieldProof `json:"fields"`
+}
+
+// MerkleFieldProof contains the encoded data of a field and its proof.
+type MerkleFieldProof struct {
+	Data  hexutil.Bytes `json:"data"`
+	Proof []common.Hash `json:"proof"`
+}
+
+// Proofs returns the data and proofs of all fields in the tree.
+func (t *FieldsMerkleTree) Proofs(name string) (*MerkleFieldsProofs, error) {
+	ps := &MerkleFieldsProofs{
+		Name:   name,
+		Root:   t.Root(),
+		Groups: make([]MerkleGroupProofs, len(t.groups)),
+	}
+	for i, g := range t.groups {
+		gp := MerkleGroupProofs{
+			Name:   g,
+			Fields: make([]MerkleFieldProof, len(t.data[i])),
+		}
+		for j, d := range t.data[i] {
+			p, err := t.Proof(i, j)
+			if err != nil {
+				return nil, err
+			}
+			// The JSON encoding of a nil slice is null instead of [], which
+			// forge's vm.parseJson cannot decode as bytes32[].
+			if p == nil {
+				p = []common.Hash{}
+			}
+			gp.Fields[j] = MerkleFieldProof{Data: d, Proof: p}
+		}
+		ps.Groups[i] = gp
+	}
+	return ps, nil
+}
+
+// WriteProofsJSON writes the data and proofs of all fields in the tree as JSON.
+func (t *FieldsMerkleTree) WriteProofsJSON(name string, w io.Writer) error {
+	ps, err := t.Proofs(name)
+	if err != nil {
+		return fmt.Errorf("%T.Proofs(%q): %w", t, name, err)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(ps); err != nil {
+		return fmt.Errorf("%T.Encode(%T): %w", enc, ps, err)
+	}
+
+	return nil
+}
+
+// WriteMerkleFieldVerifier writes a library that verifies fields supplied in
+// calldata together with their proofs against the root of the given tree,
+// which is the only data stored on-chain. The groups must be the ones the tree
+// was computed from, as they define the type enum of the library.
+func WriteMerkleFieldVerifier[G FieldsGroup](name string, groups []G, t *FieldsMerkleTree, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	if len(groups) != len(t.groups) {
+		return fmt.Errorf("got %d groups, tree was computed from %d", len(groups), len(t.groups))
+	}
+	gs := make([]FieldsGroup, len(groups))
+	for i, g := range groups {
+		if g.Name() != t.groups[i] || g.NumFields() != len(t.data[i]) {
+			return fmt.Errorf("group %d (%q with %d fields) does not match tree (%q with %d fields)", i, g.Name(), g.NumFields(), t.groups[i], len(t.data[i]))
+		}
+		gs[i] = g
+	}
+
+	return cfg.executeTemplate(MerkleFieldVerifierTemplate, w, MerkleFieldVerifierData{
+		Config:       cfg,
+		Name:         name,
+		FieldsGroups: gs,
+		Root:         t.Root(),
+		NumFields:    t.NumFields(),
+	})
+}
+
+// WriteMerkleGroupStorage writes the contracts and proofs of a bundle whose
+// fields are supplied in calldata instead of being deployed in BucketStorages.
+// This writes the verifier library (see WriteMerkleFieldVerifier) to
+// `<outputDir>/<verifier name>.sol` and the proofs of all fields (see
+// FieldsMerkleTree.WriteProofsJSON) to `<outputDir>/<name>Proofs.json`.
+// Returns the paths of the written contracts.
+func WriteMerkleGroupStorage[G FieldsGroup](name string, groups []G, t *FieldsMerkleTree, cfg *GeneratorConfig, outputDir string) ([]string, error) {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := fileGenerator{fsys: cfg.Output}
+	if err := fs.writeSolFile(outputDir, cfg.VerifierName(name), func(f io.Writer) error {
+		return annotateNonNil(WriteMerkleFieldVerifier(name, groups, t, cfg, f), "storage.WriteMerkleFieldVerifier(%q, …)", name)
+	}); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(outputDir, name+"Proofs.json")
+	if err := writeFile(cfg.Output, path, func(w io.Writer) error {
+		return t.WriteProofsJSON(name, w)
+	}); err != nil {
+		return nil, fmt.Errorf("writing %q: %w", path, err)
+	}
+
+	return fs.created, nil
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeField is a Field with fixed data.
+type fakeField []byte
+
+func (f fakeField) Encode() ([]byte, error) { return f, nil }
+
+func fakeFields(groups []fakeGroup) [][]Field {
+	fields := make([][]Field, len(groups))
+	for i, g := range groups {
+		for j := 0; j < g.numFields; j++ {
+			fields[i] = append(fields[i], fakeField(fmt.Sprintf("%s-%d", g.name, j)))
+		}
+	}
+	return fields
+}
+
+func TestMerkleFieldLeaf(t *testing.T) {
+	// abi.encodePacked(uint256(1), uint256(2), "foo")
+	packed := make([]byte, 64)
+	packed[31], packed[63] = 1, 2
+	packed = append(packed, "foo"...)
+
+	want := crypto.Keccak256Hash(crypto.Keccak256(packed))
+	if got := MerkleFieldLeaf(1, 2, []byte("foo")); got != want {
+		t.Errorf("MerkleFieldLeaf(1, 2, %q) got %v; want %v", "foo", got, want)
+	}
+}
+
+func TestFieldsMerkleTree(t *testing.T) {
+	tests := []struct {
+		name       string
+		groups     []fakeGroup
+		wantDepths []int
+	}{
+		{
+			name:       "single field",
+			groups:     []fakeGroup{{"FOO", 1}},
+			wantDepths: []int{0},
+		},
+		{
+			name:       "balanced",
+			groups:     []fakeGroup{{"FOO", 2}, {"BAR", 2}},
+			wantDepths: []int{2, 2, 2, 2},
+		},
+		{
+			name:   "promoted nodes",
+			groups: []fakeGroup{{"FOO", 3}, {"BAR", 0}, {"BAZ", 2}},
+			// The fifth leaf has no sibling on the first two levels.
+			wantDepths: []int{3, 3, 3, 3, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := fakeFields(tt.groups)
+			mt, err := NewFieldsMerkleTree(tt.groups, fields)
+			if err != nil {
+				t.Fatalf("NewFieldsMerkleTree(…) error %v", err)
+			}
+
+			var depths []int
+			for g, fs := range fields {
+				for i, f := range fs {
+					proof, err := mt.Proof(g, i)
+					if err != nil {
+						t.Fatalf("%T.Proof(%d, %d) error %v", mt, g, i, err)
+					}
+					depths = append(depths, len(proof))
+
+					d, _ := f.Encode()
+					if !VerifyMerkleFieldProof(mt.Root(), g, i, d, proof) {
+						t.Errorf("VerifyMerkleFieldProof(…, %d, %d, …) got false; want true", g, i)
+					}
+					if VerifyMerkleFieldProof(mt.Root(), g, i, append(d, 0), proof) {
+						t.Errorf("VerifyMerkleFieldProof(…, %d, %d, [modified data], …) got true; want false", g, i)
+					}
+					if VerifyMerkleFieldProof(mt.Root(), g, i+1, d, proof) {
+						t.Errorf("VerifyMerkleFieldProof(…, %d, %d, [data of index %d], …) got true; want false", g, i+1, i)
+					}
+				}
+			}
+			if diff := cmp.Diff(tt.wantDepths, depths); diff != "" {
+				t.Errorf("proof lengths diff (-want +got):\n%s", diff)
+			}
+
+			if _, err := mt.Proof(len(fields), 0); err == nil {
+				t.Errorf("%T.Proof([group out of range], 0) got nil error; want error", mt)
+			}
+		})
+	}
+}
+
+func TestFieldsMerkleTreeErrors(t *testing.T) {
+	groups := []fakeGroup{{"FOO", 2}}
+
+	tests := []struct {
+		name         string
+		groups       []fakeGroup
+		fields       [][]Field
+		wantContains string
+	}{
+		{
+			name:         "missing group",
+			groups:       groups,
+			wantContains: "got fields for 0 groups, want 1",
+		},
+		{
+			name:         "missing field",
+			groups:       groups,
+			fields:       [][]Field{{fakeField("a")}},
+			wantContains: `got 1 fields for group "FOO", want 2`,
+		},
+		{
+			name:         "empty",
+			groups:       []fakeGroup{{"FOO", 0}},
+			fields:       [][]Field{nil},
+			wantContains: "does not contain any fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFieldsMerkleTree(tt.groups, tt.fields)
+			if err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("NewFieldsMerkleTree(…) got err %v; want containing %q", err, tt.wantContains)
+			}
+		})
+	}
+}
+
+func TestWriteMerkleGroupStorage(t *testing.T) {
+	groups := []fakeGroup{{"FOO", 2}, {"BAR", 1}}
+	mt, err := NewFieldsMerkleTree(groups, fakeFields(groups))
+	if err != nil {
+		t.Fatalf("NewFieldsMerkleTree(…) error %v", err)
+	}
+
+	mem := new(MemFS)
+	cfg := DefaultGeneratorConfig()
+	cfg.Output = mem
+	got, err := WriteMerkleGroupStorage("Test", groups, mt, cfg, "gen")
+	if err != nil {
+		t.Fatalf("WriteMerkleGroupStorage(…) error %v", err)
+	}
+	if diff := cmp.Diff([]string{"gen/TestFieldVerifier.sol"}, got); diff != "" {
+		t.Errorf("WriteMerkleGroupStorage(…) diff (-want +got):\n%s", diff)
+	}
+
+	verifier := string(mem.Files()["gen/TestFieldVerifier.sol"])
+	for _, want := range []string{
+		"library TestFieldVerifier {",
+		"bytes32 public constant FIELDS_ROOT = " + mt.Root().Hex() + ";",
+		"error InvalidTestProof(TestType, uint256 index);",
+	} {
+		if !strings.Contains(verifier, want) {
+			t.Errorf("WriteMerkleGroupStorage(…) verifier does not contain %q", want)
+		}
+	}
+
+	var proofs MerkleFieldsProofs
+	if err := json.Unmarshal(mem.Files()["gen/TestProofs.json"], &proofs); err != nil {
+		t.Fatalf("json.Unmarshal([proofs], %T) error %v", &proofs, err)
+	}
+	p, err := mt.Proof(0, 1)
+	if err != nil {
+		t.Fatalf("%T.Proof(0, 1) error %v", mt, err)
+	}
+	want := MerkleFieldProof{Data: []byte("FOO-1"), Proof: p}
+	if diff := cmp.Diff(want, proofs.Groups[0].Fields[1]); diff != "" {
+		t.Errorf("WriteMerkleGroupStorage(…) proof of field 1 of %q diff (-want +got):\n%s", "FOO", diff)
+	}
+	if proofs.Root != mt.Root() || proofs.Groups[1].Name != "BAR" {
+		t.Errorf("WriteMerkleGroupStorage(…) got proofs %+v; want root %v and groups [FOO BAR]", proofs, mt.Root())
+	}
+
+	if _, err := WriteMerkleGroupStorage("Test", []fakeGroup{{"FOO", 2}, {"BAZ", 1}}, mt, cfg, "gen"); err == nil {
+		t.Errorf("WriteMerkleGroupStorage([different groups], …) got nil error; want error")
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// A WritableFS is a filesystem that generated files are written to.
+type WritableFS interface {
+	// Create creates or truncates the file at a given path, creating any
+	// missing parent directories. The contents are only guaranteed to be
+	// persisted once the returned writer has been closed.
+	Create(path string) (io.WriteCloser, error)
+}
+
+// orDisk returns DiskFS if fsys is nil and fsys otherwise.
+func orDisk(fsys WritableFS) WritableFS {
+	if fsys == nil {
+		return DiskFS{}
+	}
+	return fsys
+}
+
+// DiskFS writes files to the local filesystem. Relative paths are resolved
+// against the working directory.
+type DiskFS struct{}
+
+// Create creates the file using os.Create.
+func (DiskFS) Create(p string) (io.WriteCloser, error) {
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("os.MkdirAll(%q): %w", dir, err)
+	}
+
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, fmt.Errorf("os.Create(%q): %w", p, err)
+	}
+	return f, nil
+}
+
+// MemFS collects written files in memory, e.g. for golden testing or to embed
+// the generation in other tools. The zero value is ready to use and safe for
+// concurrent use.
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// Create returns a writer whose contents are stored under the cleaned,
+// slash-separated path once it is closed.
+func (m *MemFS) Create(p string) (io.WriteCloser, error) {
+	return &memFile{fs: m, path: path.Clean(filepath.ToSlash(p))}, nil
+}
+
+// Files returns a copy of all files written so far, keyed by their cleaned,
+// slash-separated paths.
+func (m *MemFS) Files() map[string][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fs := make(map[string][]byte, len(m.files))
+	for p, d := range m.files {
+		fs[p] = append([]byte(nil), d...)
+	}
+	return fs
+}
+
+// memFile buffers the contents of a MemFS file until it is closed.
+type memFile struct {
+	bytes.Buffer
+	fs   *MemFS
+	path string
+}
+
+// Close stores the buffered contents in the MemFS.
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.fs.files == nil {
+		f.fs.files = make(map[string][]byte)
+	}
+	f.fs.files[f.path] = f.Bytes()
+	return nil
+}
+
+// writeFile creates a file at a given path in fsys and passes it to the
+// write() callback for generation. Any error returned by write() or while
+// closing the file will be propagated.
+func writeFile(fsys WritableFS, p string, write func(io.Writer) error) (retErr error) {
+	f, err := orDisk(fsys).Create(p)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := f.Close(); retErr == nil {
+			retErr = err
+		}
+	}()
+
+	return write(f)
+}
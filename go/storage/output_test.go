@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteGroupStorageMemFS(t *testing.T) {
+	mem := new(MemFS)
+	cfg := DefaultGeneratorConfig()
+	cfg.Output = mem
+
+	stores := []fakeStorage{
+		{name: cfg.StorageName("Test", 0), buckets: []Bucket{fakeBucket{numFields: 2}}},
+	}
+	groups := []fakeGroup{{"FOO", 2}}
+
+	created, err := WriteGroupStorage("Test", groups, stores, cfg, "gen")
+	if err != nil {
+		t.Fatalf("WriteGroupStorage(…) error %v", err)
+	}
+
+	want := []string{
+		"gen/TestStorageDeployer.sol",
+		"gen/TestStorageManager.sol",
+		"gen/TestStorageMapping.sol",
+		"gen/storage/TestBucketStorage0.sol",
+	}
+
+	files := mem.Files()
+	var got []string
+	for p := range files {
+		got = append(got, p)
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("%T.Files() keys diff (-want +got):\n%s", mem, diff)
+	}
+
+	for i, p := range created {
+		created[i] = filepath.ToSlash(p)
+	}
+	sort.Strings(created)
+	if diff := cmp.Diff(want, created); diff != "" {
+		t.Errorf("WriteGroupStorage(…) created files diff (-want +got):\n%s", diff)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBucketStorage(stores[0], cfg, &buf); err != nil {
+		t.Fatalf("WriteBucketStorage(…) error %v", err)
+	}
+	if diff := cmp.Diff(buf.String(), string(files["gen/storage/TestBucketStorage0.sol"])); diff != "" {
+		t.Errorf("in-memory storage contract diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiskFS(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "a", "b", "file.json")
+
+	const want = `{"foo":"bar"}`
+	err := writeFile(nil, p, func(w io.Writer) error {
+		_, err := w.Write([]byte(want))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("writeFile(nil, %q, …) error %v", p, err)
+	}
+
+	got, err := os.ReadFile(p)
+	if err != nil {
+		t.Fatalf("os.ReadFile(%q) error %v", p, err)
+	}
+	if string(got) != want {
+		t.Errorf("os.ReadFile(%q) got %q; want %q", p, got, want)
+	}
+}
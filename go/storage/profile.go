@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// A GasSchedule defines the gas charged for the operations involved in
+// deploying storage contracts.
+type GasSchedule struct {
+	// TxBase is the base cost of a transaction.
+	TxBase uint64
+	// Create is the base cost of the CREATE and CREATE2 operations.
+	Create uint64
+	// CodeDepositPerByte is the cost of storing a byte of deployed code.
+	CodeDepositPerByte uint64
+	// TxDataZeroByte and TxDataNonZeroByte are the costs of zero and non-zero
+	// bytes of transaction data.
+	TxDataZeroByte, TxDataNonZeroByte uint64
+	// InitCodeWord is the cost of each 32-byte word of creation code
+	// (EIP-3860).
+	InitCodeWord uint64
+	// Copy is the cost of copying a 32-byte word, e.g. with CODECOPY.
+	Copy uint64
+	// Memory and MemoryQuadCoeffDiv define the cost of expanding memory to n
+	// words, i.e. Memory*n + n*n/MemoryQuadCoeffDiv.
+	Memory, MemoryQuadCoeffDiv uint64
+	// TxDataCompression is the expected ratio of compressed to uncompressed
+	// transaction data for fee models charging for compressed data, e.g.
+	// rollups posting their transactions to L1. Zero is equivalent to 1, i.e.
+	// no compression.
+	TxDataCompression float64
+}
+
+// A ChainProfile describes the limits and gas schedule of a target chain.
+type ChainProfile struct {
+	// Name identifies the profile, e.g. in LookupProfile.
+	Name string
+	// MaxCodeSize is the maximum size of deployed code (EIP-170 on mainnet).
+	MaxCodeSize int
+	// MaxInitCodeSize is the maximum size of creation code (EIP-3860 on
+	// mainnet).
+	MaxInitCodeSize int
+	// BlockGasLimit is the gas limit of a block.
+	BlockGasLimit uint64
+	Gas           GasSchedule
+}
+
+// initCodeWordGas is the EIP-3860 charge per word of creation code, which is
+// not metered by the EVM implementation used for simulation.
+const initCodeWordGas = 2
+
+// mainnetGas is the gas schedule of Ethereum mainnet.
+var mainnetGas = GasSchedule{
+	TxBase:             params.TxGas,
+	Create:             params.CreateGas,
+	CodeDepositPerByte: params.CreateDataGas,
+	TxDataZeroByte:     params.TxDataZeroGas,
+	TxDataNonZeroByte:  params.TxDataNonZeroGasEIP2028,
+	InitCodeWord:       initCodeWordGas,
+	Copy:               params.CopyGas,
+	Memory:             params.MemoryGas,
+	MemoryQuadCoeffDiv: params.QuadCoeffDiv,
+}
+
+// Predefined chain profiles. The L2 profiles only differ from mainnet in their
+// block gas limits as the chains are EVM equivalent with respect to contract
+// deployment, including the EIP-170 and EIP-3860 code size limits. Block gas
+// limits are those at the time of writing, as set in the superchain-registry
+// (github.com/ethereum-optimism/superchain-registry) for OP Mainnet and
+// documented at docs.arbitrum.io for Arbitrum One; they may be raised by the
+// chains' operators, in which case a copy with an updated limit can be
+// registered under a new name.
+//
+// Fees charged outside of the gas schedule, like the L1 data fees of rollups,
+// are not modelled by a ChainProfile; setting GasSchedule.TxDataCompression
+// and the calldata costs in a custom profile only approximates them.
+var (
+	// ProfileMainnet is the profile of Ethereum mainnet, which is the default
+	// everywhere a profile is accepted.
+	ProfileMainnet = &ChainProfile{
+		Name:            "mainnet",
+		MaxCodeSize:     params.MaxCodeSize,
+		MaxInitCodeSize: 2 * params.MaxCodeSize,
+		BlockGasLimit:   30_000_000,
+		Gas:             mainnetGas,
+	}
+	// ProfileOptimism is the profile of OP Mainnet.
+	ProfileOptimism = &ChainProfile{
+		Name:            "optimism",
+		MaxCodeSize:     params.MaxCodeSize,
+		MaxInitCodeSize: 2 * params.MaxCodeSize,
+		BlockGasLimit:   30_000_000,
+		Gas:             mainnetGas,
+	}
+	// ProfileArbitrum is the profile of Arbitrum One.
+	ProfileArbitrum = &ChainProfile{
+		Name:            "arbitrum",
+		MaxCodeSize:     params.MaxCodeSize,
+		MaxInitCodeSize: 2 * params.MaxCodeSize,
+		BlockGasLimit:   32_000_000,
+		Gas:             mainnetGas,
+	}
+)
+
+var profiles = struct {
+	sync.RWMutex
+	byName map[string]*ChainProfile
+}{
+	byName: map[string]*ChainProfile{
+		ProfileMainnet.Name:  ProfileMainnet,
+		ProfileOptimism.Name: ProfileOptimism,
+		ProfileArbitrum.Name: ProfileArbitrum,
+	},
+}
+
+// RegisterProfile makes a custom profile available via LookupProfile.
+func RegisterProfile(p *ChainProfile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	profiles.Lock()
+	defer profiles.Unlock()
+	if _, ok := profiles.byName[p.Name]; ok {
+		return fmt.Errorf("profile %q already registered", p.Name)
+	}
+	profiles.byName[p.Name] = p
+	return nil
+}
+
+// unregisterProfile removes a profile added with RegisterProfile.
+func unregisterProfile(name string) {
+	profiles.Lock()
+	defer profiles.Unlock()
+	delete(profiles.byName, name)
+}
+
+// LookupProfile returns the predefined or registered profile with the given
+// name.
+func LookupProfile(name string) (*ChainProfile, error) {
+	profiles.RLock()
+	defer profiles.RUnlock()
+	if p, ok := profiles.byName[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown chain profile %q; available: %q", name, profileNames())
+}
+
+// profileNames returns the sorted names of all profiles. The caller must hold
+// the lock.
+func profileNames() []string {
+	var names []string
+	for n := range profiles.byName {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that the profile is complete.
+func (p *ChainProfile) Validate() error {
+	switch {
+	case p == nil:
+		return errors.New("nil chain profile")
+	case p.Name == "":
+		return errors.New("chain profile without name")
+	case p.MaxCodeSize <= 0 || p.MaxInitCodeSize <= 0:
+		return fmt.Errorf("chain profile %q: code size limits must be positive", p.Name)
+	case p.BlockGasLimit == 0:
+		return fmt.Errorf("chain profile %q: zero block gas limit", p.Name)
+	case p.Gas.MemoryQuadCoeffDiv == 0:
+		return fmt.Errorf("chain profile %q: zero memory quadratic coefficient divisor", p.Name)
+	case p.Gas.TxDataCompression < 0 || p.Gas.TxDataCompression > 1:
+		return fmt.Errorf("chain profile %q: transaction data compression %v out of range [0, 1]", p.Name, p.Gas.TxDataCompression)
+	}
+	return nil
+}
+
+// ErrCodeSizeExceeded is returned, possibly wrapped, if code exceeds the size
+// limits of a ChainProfile.
+var ErrCodeSizeExceeded = errors.New("code size limit exceeded")
+
+// ValidateSize checks the bytecode of a contract against the size limits of
+// the profile.
+func (p *ChainProfile) ValidateSize(name string, code *Bytecode) error {
+	if n := len(code.Deployed); n > p.MaxCodeSize {
+		return fmt.Errorf("%w: deployed code of %q is %d bytes; max %d on %s", ErrCodeSizeExceeded, name, n, p.MaxCodeSize, p.Name)
+	}
+	if n := len(code.Creation); n > p.MaxInitCodeSize {
+		return fmt.Errorf("%w: creation code of %q is %d bytes; max %d on %s", ErrCodeSizeExceeded, name, n, p.MaxInitCodeSize, p.Name)
+	}
+	return nil
+}
+
+// ValidateStorageSizes checks the bytecode assembled by StorageBytecode for
+// each storage against the size limits of the profile. This is only an
+// estimate of the size of the contracts compiled from the Solidity files
+// written by WriteBucketStorage, which additionally contain solc's dispatcher
+// and metadata; the compiled artifacts should be checked before deployment.
+func ValidateStorageSizes[S BucketStorage](stores []S, p *ChainProfile) error {
+	for _, s := range stores {
+		code, err := StorageBytecode(s)
+		if err != nil {
+			return err
+		}
+		if err := p.ValidateSize(s.Name(), code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// txDataGas returns the gas charged for transaction data, excluding the
+// transaction base cost.
+func (g GasSchedule) txDataGas(data []byte) uint64 {
+	var d uint64
+	for _, b := range data {
+		if b == 0 {
+			d += g.TxDataZeroByte
+		} else {
+			d += g.TxDataNonZeroByte
+		}
+	}
+	if c := g.TxDataCompression; c > 0 && c < 1 {
+		d = uint64(math.Ceil(float64(d) * c))
+	}
+	return d
+}
+
+// initCodeGas returns the gas charged for including creation code in
+// transaction data, excluding the transaction base cost.
+func (g GasSchedule) initCodeGas(code []byte) uint64 {
+	return g.txDataGas(code) + g.InitCodeWord*toWords(len(code))
+}
+
+// memoryGas returns the cost of expanding memory from zero to the given number
+// of words.
+func (g GasSchedule) memoryGas(words uint64) uint64 {
+	return g.Memory*words + words*words/g.MemoryQuadCoeffDiv
+}
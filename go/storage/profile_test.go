@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLookupProfile(t *testing.T) {
+	for _, want := range []*ChainProfile{ProfileMainnet, ProfileOptimism, ProfileArbitrum} {
+		got, err := LookupProfile(want.Name)
+		if err != nil {
+			t.Errorf("LookupProfile(%q) error %v", want.Name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("LookupProfile(%q) got %+v; want %+v", want.Name, got, want)
+		}
+		if err := got.Validate(); err != nil {
+			t.Errorf("%T(%q).Validate() error %v", got, got.Name, err)
+		}
+	}
+
+	if _, err := LookupProfile("unknown"); err == nil || !strings.Contains(err.Error(), "mainnet") {
+		t.Errorf("LookupProfile(%q) error %v; want listing available profiles", "unknown", err)
+	}
+
+	custom := *ProfileMainnet
+	custom.Name = "test-custom"
+	custom.MaxCodeSize = 48 * 1024
+	if err := RegisterProfile(&custom); err != nil {
+		t.Fatalf("RegisterProfile(%q) error %v", custom.Name, err)
+	}
+	t.Cleanup(func() { unregisterProfile(custom.Name) })
+	if got, err := LookupProfile(custom.Name); err != nil || got != &custom {
+		t.Errorf("LookupProfile(%q) after registration got (%+v, %v); want (%+v, nil)", custom.Name, got, err, &custom)
+	}
+	if err := RegisterProfile(&custom); err == nil {
+		t.Errorf("RegisterProfile(%q) twice got nil error; want error", custom.Name)
+	}
+}
+
+func TestChainProfileValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		modify       func(*ChainProfile)
+		wantContains string
+	}{
+		{
+			name:         "no name",
+			modify:       func(p *ChainProfile) { p.Name = "" },
+			wantContains: "without name",
+		},
+		{
+			name:         "zero code size",
+			modify:       func(p *ChainProfile) { p.MaxCodeSize = 0 },
+			wantContains: "code size limits",
+		},
+		{
+			name:         "zero block gas limit",
+			modify:       func(p *ChainProfile) { p.BlockGasLimit = 0 },
+			wantContains: "block gas limit",
+		},
+		{
+			name:         "compression out of range",
+			modify:       func(p *ChainProfile) { p.Gas.TxDataCompression = 1.5 },
+			wantContains: "out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := *ProfileMainnet
+			tt.modify(&p)
+
+			if err := p.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("%T.Validate() error %v; want containing %q", p, err, tt.wantContains)
+			}
+			if err := (&GeneratorConfig{Target: &p}).Validate(); err == nil {
+				t.Errorf("%T{Target: [invalid]}.Validate() got nil error; want error", GeneratorConfig{})
+			}
+		})
+	}
+}
+
+func TestValidateStorageSizes(t *testing.T) {
+	stores := []fakeStorage{
+		{name: "TestBucketStorage0", buckets: []Bucket{fakeBucket{data: []byte("foo"), numFields: 1}}},
+		{name: "TestBucketStorage1", buckets: []Bucket{fakeBucket{data: make([]byte, 500), numFields: 1}}},
+	}
+	code, err := StorageBytecode(stores[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		maxCode      int
+		maxInitCode  int
+		wantErr      bool
+		wantContains string
+	}{
+		{
+			name:        "within limits",
+			maxCode:     len(code.Deployed),
+			maxInitCode: len(code.Creation),
+		},
+		{
+			name:         "deployed code",
+			maxCode:      len(code.Deployed) - 1,
+			maxInitCode:  len(code.Creation),
+			wantErr:      true,
+			wantContains: `deployed code of "TestBucketStorage1"`,
+		},
+		{
+			name:         "creation code",
+			maxCode:      len(code.Deployed),
+			maxInitCode:  len(code.Creation) - 1,
+			wantErr:      true,
+			wantContains: `creation code of "TestBucketStorage1"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := *ProfileMainnet
+			p.MaxCodeSize = tt.maxCode
+			p.MaxInitCodeSize = tt.maxInitCode
+
+			err := ValidateStorageSizes(stores, &p)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("ValidateStorageSizes(…) error %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrCodeSizeExceeded) || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("ValidateStorageSizes(…) error %v; want %v containing %q", err, ErrCodeSizeExceeded, tt.wantContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/multierr"
+)
+
+// A SharedMapping is a logical bundle whose fields are located in storages
+// shared with other bundles (see WriteSharedGroupStorage).
+type SharedMapping struct {
+	Name   string
+	Groups []FieldsGroup
+	// Locations of the fields in the shared storages, indexed by group and
+	// field index in that group.
+	Locations [][]FieldLocation
+}
+
+// WriteSharedGroupStorage writes the contracts of multiple logical bundles
+// whose fields are packed into the same BucketStorages to a given output
+// directory, avoiding the overhead of deploying separate, possibly nearly
+// empty, storage contracts for every bundle. Returns the paths of the written
+// files.
+//
+// The storages and a single storage deployer are named after the given name.
+// For every mapping, a table storage mapping (see
+// WriteTableStorageMappingFromLocations) and a storage manager holding the
+// bundle of the shared deployer (see WriteSharedStorageManager) are written
+// under the mapping's name. The (type, index) lookups of each bundle are thus
+// unchanged, but no per-bundle deployers are written, so consumers deploying
+// the individual bundles have to deploy the shared storages instead. Each
+// manager reveals the buckets referenced by its mapping (see
+// RevealCommitmentOf), so mappings may be encrypted with different secrets or
+// not at all.
+func WriteSharedGroupStorage[S BucketStorage](name string, mappings []SharedMapping, stores []S, cfg *GeneratorConfig, outputDir string) ([]string, error) {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	commitments := make([]*common.Hash, len(mappings))
+	for i, m := range mappings {
+		if seen[m.Name] {
+			return nil, fmt.Errorf("duplicate mapping %q", m.Name)
+		}
+		seen[m.Name] = true
+
+		if err := validateSharedLocations(m, stores); err != nil {
+			return nil, fmt.Errorf("mapping %q: %w", m.Name, err)
+		}
+		if commitments[i], err = sharedRevealCommitment(m, stores); err != nil {
+			return nil, fmt.Errorf("mapping %q: %w", m.Name, err)
+		}
+	}
+
+	fs := fileGenerator{fsys: cfg.Output}
+	storageSubdir := "storage"
+
+	errs := []error{
+		fs.writeSolFile(outputDir, cfg.DeployerName(name), func(f io.Writer) error {
+			return annotateNonNil(WriteStorageDeployer(name, "./"+storageSubdir, stores, cfg, f), "storage.WriteStorageDeployer(%q, …)", name)
+		}),
+	}
+	for i, m := range mappings {
+		m, commitment := m, commitments[i]
+		errs = append(errs,
+			fs.writeSolFile(outputDir, cfg.MappingName(m.Name), func(f io.Writer) error {
+				return annotateNonNil(WriteTableStorageMappingFromLocations(m.Name, m.Groups, m.Locations, cfg, f), "storage.WriteTableStorageMappingFromLocations(%q, …)", m.Name)
+			}),
+			fs.writeSolFile(outputDir, cfg.ManagerName(m.Name), func(f io.Writer) error {
+				return annotateNonNil(writeStorageManager(m.Name, m.Groups, managerOptions{storageBundle: name, commitment: commitment}, cfg, f), "storage.writeStorageManager(%q, …)", m.Name)
+			}),
+		)
+	}
+	if err := multierr.Combine(errs...); err != nil {
+		return nil, err
+	}
+
+	for _, s := range stores {
+		if err := fs.writeSolFile(filepath.Join(outputDir, storageSubdir), s.Name(), func(f io.Writer) error {
+			return annotateNonNil(WriteBucketStorage(s, cfg, f), "storage.WriteBucketStorage(…)")
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return fs.created, nil
+}
+
+// sharedRevealCommitment returns the commitment to the secret with which the
+// buckets referenced by a mapping are encrypted (see RevealCommitmentOf),
+// ignoring the buckets of other mappings in the same storages. The locations
+// must have been validated.
+func sharedRevealCommitment[S BucketStorage](m SharedMapping, stores []S) (*common.Hash, error) {
+	var (
+		bs   []storedBucket
+		seen = make(map[[2]int]bool)
+	)
+	for _, locs := range m.Locations {
+		for _, l := range locs {
+			if k := [2]int{l.StorageID, l.BucketID}; !seen[k] {
+				seen[k] = true
+				s := stores[l.StorageID]
+				bs = append(bs, storedBucket{storage: s.Name(), index: l.BucketID, bucket: s.Buckets()[l.BucketID]})
+			}
+		}
+	}
+	return revealCommitment(bs)
+}
+
+// validateSharedLocations checks that all locations of a mapping point to
+// existing fields in the shared storages.
+func validateSharedLocations[S BucketStorage](m SharedMapping, stores []S) error {
+	if len(m.Locations) != len(m.Groups) {
+		return fmt.Errorf("got locations for %d groups, want %d", len(m.Locations), len(m.Groups))
+	}
+	for i, g := range m.Groups {
+		for j, l := range m.Locations[i] {
+			if l.StorageID < 0 || l.StorageID >= len(stores) {
+				return fmt.Errorf("field %d of group %q: storage %d out of range [0, %d)", j, g.Name(), l.StorageID, len(stores))
+			}
+			buckets := stores[l.StorageID].Buckets()
+			if l.BucketID < 0 || l.BucketID >= len(buckets) {
+				return fmt.Errorf("field %d of group %q: bucket %d out of range [0, %d)", j, g.Name(), l.BucketID, len(buckets))
+			}
+			if n := buckets[l.BucketID].NumFields(); l.FieldID < 0 || l.FieldID >= n {
+				return fmt.Errorf("field %d of group %q: field %d out of range [0, %d)", j, g.Name(), l.FieldID, n)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWriteSharedGroupStorage(t *testing.T) {
+	stores := []fakeStorage{
+		{name: "SharedBucketStorage0", buckets: []Bucket{fakeBucket{numFields: 2}, fakeBucket{numFields: 1}}},
+	}
+	mappings := []SharedMapping{
+		{
+			Name:      "Foo",
+			Groups:    []FieldsGroup{fakeGroup{"A", 2}},
+			Locations: [][]FieldLocation{{{BucketID: 0, FieldID: 0}, {BucketID: 0, FieldID: 1}}},
+		},
+		{
+			Name:      "Bar",
+			Groups:    []FieldsGroup{fakeGroup{"B", 1}},
+			Locations: [][]FieldLocation{{{BucketID: 1, FieldID: 0}}},
+		},
+	}
+
+	mem := new(MemFS)
+	cfg := DefaultGeneratorConfig()
+	cfg.Output = mem
+
+	created, err := WriteSharedGroupStorage("Shared", mappings, stores, cfg, "gen")
+	if err != nil {
+		t.Fatalf("WriteSharedGroupStorage(…) error %v", err)
+	}
+
+	want := []string{
+		"gen/BarStorageManager.sol",
+		"gen/BarStorageMapping.sol",
+		"gen/FooStorageManager.sol",
+		"gen/FooStorageMapping.sol",
+		"gen/SharedStorageDeployer.sol",
+		"gen/storage/SharedBucketStorage0.sol",
+	}
+	for i, p := range created {
+		created[i] = filepath.ToSlash(p)
+	}
+	sort.Strings(created)
+	if diff := cmp.Diff(want, created); diff != "" {
+		t.Errorf("WriteSharedGroupStorage(…) created files diff (-want +got):\n%s", diff)
+	}
+
+	files := mem.Files()
+	for _, name := range []string{"Foo", "Bar"} {
+		manager := string(files["gen/"+name+"StorageManager.sol"])
+		for _, want := range []string{"SharedStorageDeployer.Bundle memory bundle_", "contract " + name + "StorageManager"} {
+			if !strings.Contains(manager, want) {
+				t.Errorf("%sStorageManager does not contain %q; got:\n%s", name, want, manager)
+			}
+		}
+	}
+}
+
+func TestWriteSharedGroupStorageEncrypted(t *testing.T) {
+	s1, s2 := RevealSecret{1}, RevealSecret{2}
+	foo := SharedMapping{
+		Name:      "Foo",
+		Groups:    []FieldsGroup{fakeGroup{"A", 2}},
+		Locations: [][]FieldLocation{{{BucketID: 0, FieldID: 0}, {BucketID: 1, FieldID: 0}}},
+	}
+	bar := SharedMapping{
+		Name:      "Bar",
+		Groups:    []FieldsGroup{fakeGroup{"B", 1}},
+		Locations: [][]FieldLocation{{{BucketID: 2, FieldID: 0}}},
+	}
+	bucket := fakeBucket{data: []byte("foo"), numFields: 1}
+
+	tests := []struct {
+		name         string
+		buckets      []Bucket
+		want         map[string]*RevealSecret
+		wantContains string
+	}{
+		{
+			name:    "only Foo encrypted",
+			buckets: []Bucket{EncryptBucket(bucket, s1), EncryptBucket(bucket, s1), bucket},
+			want:    map[string]*RevealSecret{"Foo": &s1, "Bar": nil},
+		},
+		{
+			name:    "different secrets",
+			buckets: []Bucket{EncryptBucket(bucket, s1), EncryptBucket(bucket, s1), EncryptBucket(bucket, s2)},
+			want:    map[string]*RevealSecret{"Foo": &s1, "Bar": &s2},
+		},
+		{
+			name:         "Foo partially encrypted",
+			buckets:      []Bucket{EncryptBucket(bucket, s1), bucket, bucket},
+			wantContains: `mapping "Foo": 1 of 2 buckets are not encrypted`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := []fakeStorage{{name: "SharedBucketStorage0", buckets: tt.buckets}}
+			mem := new(MemFS)
+			cfg := DefaultGeneratorConfig()
+			cfg.Output = mem
+
+			_, err := WriteSharedGroupStorage("Shared", []SharedMapping{foo, bar}, stores, cfg, "gen")
+			if tt.wantContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+					t.Errorf("WriteSharedGroupStorage(…) got err %v; want containing %q", err, tt.wantContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WriteSharedGroupStorage(…) error %v", err)
+			}
+
+			for name, secret := range tt.want {
+				manager := string(mem.Files()["gen/"+name+"StorageManager.sol"])
+				if got := strings.Contains(manager, "function reveal(bytes32 secret)"); got != (secret != nil) {
+					t.Errorf("%sStorageManager has reveal function = %t; want %t", name, got, secret != nil)
+				}
+				if secret != nil && !strings.Contains(manager, secret.Commitment().Hex()) {
+					t.Errorf("%sStorageManager does not contain commitment %v", name, secret.Commitment())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteSharedGroupStorageErrors(t *testing.T) {
+	stores := []fakeStorage{
+		{name: "SharedBucketStorage0", buckets: []Bucket{fakeBucket{numFields: 2}}},
+	}
+	valid := SharedMapping{
+		Name:      "Foo",
+		Groups:    []FieldsGroup{fakeGroup{"A", 1}},
+		Locations: [][]FieldLocation{{{FieldID: 1}}},
+	}
+
+	tests := []struct {
+		name         string
+		mappings     []SharedMapping
+		wantContains string
+	}{
+		{
+			name:         "duplicate mapping",
+			mappings:     []SharedMapping{valid, valid},
+			wantContains: `duplicate mapping "Foo"`,
+		},
+		{
+			name: "missing locations",
+			mappings: []SharedMapping{{
+				Name:   "Foo",
+				Groups: []FieldsGroup{fakeGroup{"A", 1}},
+			}},
+			wantContains: "got locations for 0 groups",
+		},
+		{
+			name: "storage out of range",
+			mappings: []SharedMapping{{
+				Name:      "Foo",
+				Groups:    []FieldsGroup{fakeGroup{"A", 1}},
+				Locations: [][]FieldLocation{{{StorageID: 1}}},
+			}},
+			wantContains: "storage 1 out of range",
+		},
+		{
+			name: "field out of range",
+			mappings: []SharedMapping{{
+				Name:      "Foo",
+				Groups:    []FieldsGroup{fakeGroup{"A", 1}},
+				Locations: [][]FieldLocation{{{FieldID: 2}}},
+			}},
+			wantContains: "field 2 out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultGeneratorConfig()
+			cfg.Output = new(MemFS)
+			if _, err := WriteSharedGroupStorage("Shared", tt.mappings, stores, cfg, "gen"); err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("WriteSharedGroupStorage(…) got err %v; want containing %q", err, tt.wantContains)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// FieldLocation denotes the coordinates of a field inside a bundle of
+// BucketStorages.
+type FieldLocation struct {
+	StorageID, BucketID, FieldID int
+}
+
+// SequentialFieldLocations computes the locations of the fields in each group
+// under the assumption that fields have been packed into buckets and storages
+// sequentially in the order of the groups (see also
+// WriteSequentialStorageMapping).
+// The returned slice is indexed by group and field index in that group.
+func SequentialFieldLocations[G FieldsGroup, S BucketStorage](groups []G, stores []S) ([][]FieldLocation, error) {
+	var all []FieldLocation
+	for s, store := range stores {
+		for b, bucket := range store.Buckets() {
+			for f := 0; f < bucket.NumFields(); f++ {
+				all = append(all, FieldLocation{StorageID: s, BucketID: b, FieldID: f})
+			}
+		}
+	}
+
+	var numFields int
+	for _, g := range groups {
+		numFields += g.NumFields()
+	}
+	if numFields != len(all) {
+		return nil, fmt.Errorf("number of fields in groups (%d) does not match the number of fields in storages (%d)", numFields, len(all))
+	}
+
+	locs := make([][]FieldLocation, len(groups))
+	for i, g := range groups {
+		locs[i] = all[:g.NumFields()]
+		all = all[g.NumFields():]
+	}
+
+	return locs, nil
+}
+
+// PackedTable is a list of unsigned integers that are tightly packed
+// big-endian with a fixed number of bytes per entry.
+type PackedTable struct {
+	// EntryBytes is the number of bytes per entry.
+	EntryBytes int
+	// Data contains the packed entries.
+	Data []byte
+}
+
+func newPackedTable(entryBytes int, vals []uint64) (PackedTable, error) {
+	t := PackedTable{EntryBytes: entryBytes}
+	for _, v := range vals {
+		if bits.Len64(v) > 8*entryBytes {
+			return PackedTable{}, fmt.Errorf("value %d exceeds %d bytes", v, entryBytes)
+		}
+		for i := entryBytes - 1; i >= 0; i-- {
+			t.Data = append(t.Data, byte(v>>(8*i)))
+		}
+	}
+	return t, nil
+}
+
+// Shift is the number of bits that an entry has to be shifted to the right
+// after reading a full 32 byte word starting at the entry.
+func (t PackedTable) Shift() int {
+	return 256 - 8*t.EntryBytes
+}
+
+// EntryBits is the number of bits per entry.
+func (t PackedTable) EntryBits() int {
+	return 8 * t.EntryBytes
+}
+
+// EntriesPerWord is the number of entries in each of the words returned by
+// Words.
+func (t PackedTable) EntriesPerWord() int {
+	return 32 / t.EntryBytes
+}
+
+// Words splits the table into 32-byte words of EntriesPerWord entries each,
+// such that no entry spans two words. Unused trailing bytes are zero.
+func (t PackedTable) Words() [][]byte {
+	n := t.EntriesPerWord() * t.EntryBytes
+	var words [][]byte
+	for i := 0; i < len(t.Data); i += n {
+		end := i + n
+		if end > len(t.Data) {
+			end = len(t.Data)
+		}
+		w := make([]byte, 32)
+		copy(w, t.Data[i:end])
+		words = append(words, w)
+	}
+	return words
+}
+
+// WordSelection returns a Selection over Words, or nil if the table is empty.
+func (t PackedTable) WordSelection() *Selection[[]byte] {
+	return newSelection(t.Words())
+}
+
+// A Selection is a binary decision tree over a list of values, allowing
+// generated code to select a value by its index with O(log n) comparisons
+// against constants instead of copying all values into memory.
+type Selection[T any] struct {
+	// Leaf indicates that Value is the only value in the subtree.
+	Leaf  bool
+	Value T
+	// Mid is the index of the first value in Right. Values with lower indices
+	// are in Left.
+	Mid         int
+	Left, Right *Selection[T]
+}
+
+// newSelection returns a balanced Selection over the values, or nil if there
+// are none.
+func newSelection[T any](vals []T) *Selection[T] {
+	return newSubSelection(vals, 0)
+}
+
+// newSubSelection returns a Selection over the values starting at the given
+// offset in the full list.
+func newSubSelection[T any](vals []T, offset int) *Selection[T] {
+	switch len(vals) {
+	case 0:
+		return nil
+	case 1:
+		return &Selection[T]{Leaf: true, Value: vals[0]}
+	}
+	mid := len(vals) / 2
+	return &Selection[T]{
+		Mid:   offset + mid,
+		Left:  newSubSelection(vals[:mid], offset),
+		Right: newSubSelection(vals[mid:], offset+mid),
+	}
+}
+
+// bitsFor returns the number of bits needed to represent x (at least 1).
+func bitsFor(x int) int {
+	if x <= 1 {
+		return 1
+	}
+	return bits.Len(uint(x))
+}
+
+// bytesFor returns the number of bytes needed to hold a given number of bits.
+func bytesFor(nBits int) int {
+	return (nBits + 7) / 8
+}
+
+// TableMapping contains the precomputed lookup tables for a table storage
+// mapping.
+type TableMapping struct {
+	// Groups packs `(offset of the first field, number of fields)` for every
+	// group.
+	Groups         PackedTable
+	GroupSizeBits  int
+	Fields         PackedTable
+	FieldIDBits    int
+	BucketIDBits   int
+	StorageIDShift int
+}
+
+func newTableMapping[G FieldsGroup](groups []G, locs [][]FieldLocation) (*TableMapping, error) {
+	if len(groups) != len(locs) {
+		return nil, fmt.Errorf("got locations for %d groups, want %d", len(locs), len(groups))
+	}
+
+	var maxStorage, maxBucket, maxField, maxGroupSize, numFields int
+	for i, g := range groups {
+		if got, want := len(locs[i]), g.NumFields(); got != want {
+			return nil, fmt.Errorf("got %d locations for group %q, want %d", got, g.Name(), want)
+		}
+		if n := g.NumFields(); n > maxGroupSize {
+			maxGroupSize = n
+		}
+		numFields += g.NumFields()
+
+		for _, l := range locs[i] {
+			if l.StorageID < 0 || l.BucketID < 0 || l.FieldID < 0 {
+				return nil, fmt.Errorf("invalid location %+v in group %q", l, g.Name())
+			}
+			if l.StorageID > maxStorage {
+				maxStorage = l.StorageID
+			}
+			if l.BucketID > maxBucket {
+				maxBucket = l.BucketID
+			}
+			if l.FieldID > maxField {
+				maxField = l.FieldID
+			}
+		}
+	}
+
+	m := &TableMapping{
+		GroupSizeBits: bitsFor(maxGroupSize),
+		FieldIDBits:   bitsFor(maxField),
+		BucketIDBits:  bitsFor(maxBucket),
+	}
+	m.StorageIDShift = m.FieldIDBits + m.BucketIDBits
+
+	if n := m.StorageIDShift + bitsFor(maxStorage); n > 64 {
+		return nil, fmt.Errorf("field locations require %d bits, exceeding the supported 64", n)
+	}
+	if n := m.GroupSizeBits + bitsFor(numFields); n > 64 {
+		return nil, fmt.Errorf("group entries require %d bits, exceeding the supported 64", n)
+	}
+
+	var (
+		gs     []uint64
+		fs     []uint64
+		offset int
+	)
+	for i, g := range groups {
+		gs = append(gs, uint64(offset)<<m.GroupSizeBits|uint64(g.NumFields()))
+		offset += g.NumFields()
+
+		for _, l := range locs[i] {
+			fs = append(fs, uint64(l.StorageID)<<m.StorageIDShift|uint64(l.BucketID)<<m.FieldIDBits|uint64(l.FieldID))
+		}
+	}
+
+	var err error
+	m.Groups, err = newPackedTable(bytesFor(m.GroupSizeBits+bitsFor(numFields)), gs)
+	if err != nil {
+		return nil, fmt.Errorf("packing group table: %v", err)
+	}
+	m.Fields, err = newPackedTable(bytesFor(m.StorageIDShift+bitsFor(maxStorage)), fs)
+	if err != nil {
+		return nil, fmt.Errorf("packing field table: %v", err)
+	}
+
+	return m, nil
+}
+
+// WriteTableStorageMapping writes a storage mapping library translating between
+// groups of fields and storage coordinates. In contrast to
+// WriteSequentialStorageMapping, the coordinates of every field are
+// precomputed and packed into a lookup table, such that `locate` does not
+// need to iterate over groups, storages or buckets.
+// The mapping follows the same sequential association as
+// WriteSequentialStorageMapping.
+func WriteTableStorageMapping[G FieldsGroup, S BucketStorage](name string, groups []G, stores []S, cfg *GeneratorConfig, w io.Writer) error {
+	locs, err := SequentialFieldLocations(groups, stores)
+	if err != nil {
+		return fmt.Errorf("SequentialFieldLocations(…): %w", err)
+	}
+	return WriteTableStorageMappingFromLocations(name, groups, locs, cfg, w)
+}
+
+// WriteTableStorageMappingFromLocations writes a table storage mapping (see
+// WriteTableStorageMapping) for explicitly given field locations, indexed by
+// group and field index in that group.
+func WriteTableStorageMappingFromLocations[G FieldsGroup](name string, groups []G, locs [][]FieldLocation, cfg *GeneratorConfig, w io.Writer) error {
+	cfg, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	m, err := newTableMapping(groups, locs)
+	if err != nil {
+		return fmt.Errorf("computing lookup tables for %q: %w", name, err)
+	}
+
+	return cfg.executeTemplate(TableStorageMappingTemplate, w, TableStorageMappingData{
+		Config:       cfg,
+		Name:         name,
+		FieldsGroups: convertFieldsGroups(groups),
+		Table:        m,
+	})
+}
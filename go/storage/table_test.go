@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeGroup struct {
+	name      string
+	numFields int
+}
+
+func (g fakeGroup) Name() string   { return g.name }
+func (g fakeGroup) NumFields() int { return g.numFields }
+
+func TestSequentialFieldLocations(t *testing.T) {
+	stores := []fakeStorage{
+		{
+			name: "Foo0",
+			buckets: []Bucket{
+				fakeBucket{numFields: 2},
+				fakeBucket{numFields: 3},
+			},
+		},
+		{
+			name:    "Foo1",
+			buckets: []Bucket{fakeBucket{numFields: 1}},
+		},
+	}
+	groups := []fakeGroup{{"FOO", 2}, {"BAR", 3}, {"QUX", 1}}
+
+	got, err := SequentialFieldLocations(groups, stores)
+	if err != nil {
+		t.Fatalf("SequentialFieldLocations(%v, [stores]) error %v", groups, err)
+	}
+
+	want := [][]FieldLocation{
+		{{0, 0, 0}, {0, 0, 1}},
+		{{0, 1, 0}, {0, 1, 1}, {0, 1, 2}},
+		{{1, 0, 0}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SequentialFieldLocations(%v, [stores]) diff (-want +got):\n%v", groups, diff)
+	}
+
+	if _, err := SequentialFieldLocations(groups[:2], stores); err == nil {
+		t.Errorf("SequentialFieldLocations(%v, [stores]) with mismatching number of fields: got nil error", groups[:2])
+	}
+}
+
+func TestNewTableMapping(t *testing.T) {
+	groups := []fakeGroup{{"FOO", 2}, {"BAR", 300}}
+
+	locs := [][]FieldLocation{
+		{{0, 0, 0}, {2, 1, 0}},
+		make([]FieldLocation, 300),
+	}
+	for i := range locs[1] {
+		locs[1][i] = FieldLocation{StorageID: 1, BucketID: 0, FieldID: i}
+	}
+
+	got, err := newTableMapping(groups, locs)
+	if err != nil {
+		t.Fatalf("newTableMapping(%v, [locs]) error %v", groups, err)
+	}
+
+	if got.GroupSizeBits != 9 || got.FieldIDBits != 9 || got.BucketIDBits != 1 || got.StorageIDShift != 10 {
+		t.Errorf("newTableMapping(%v, [locs]) got bit widths (group size %d, field %d, bucket %d, storage shift %d), want (9, 9, 1, 10)", groups, got.GroupSizeBits, got.FieldIDBits, got.BucketIDBits, got.StorageIDShift)
+	}
+
+	// Offsets up to 302 need 9 bits + 9 bits for group sizes -> 3 bytes
+	if diff := cmp.Diff([]byte{0, 0, 2, 0, 0x05, 0x2c}, got.Groups.Data); diff != "" {
+		t.Errorf("newTableMapping(%v, [locs]) group table diff (-want +got):\n%v", groups, diff)
+	}
+
+	// Storage 2 needs 2 bits + 10 bits shift -> 2 bytes
+	if got.Fields.EntryBytes != 2 {
+		t.Errorf("newTableMapping(%v, [locs]) field entry bytes = %d, want 2", groups, got.Fields.EntryBytes)
+	}
+	if diff := cmp.Diff([]byte{0, 0, 0x0a, 0, 0x04, 0}, got.Fields.Data[:6]); diff != "" {
+		t.Errorf("newTableMapping(%v, [locs]) field table diff (-want +got):\n%v", groups, diff)
+	}
+}
+
+func TestPackedTableWords(t *testing.T) {
+	// 3-byte entries fit 10 times into a word, leaving 2 bytes unused.
+	var vals []uint64
+	for i := uint64(1); i <= 12; i++ {
+		vals = append(vals, i)
+	}
+	pt, err := newPackedTable(3, vals)
+	if err != nil {
+		t.Fatalf("newPackedTable(3, %v) error %v", vals, err)
+	}
+
+	want := [][]byte{make([]byte, 32), make([]byte, 32)}
+	for i := 0; i < 10; i++ {
+		want[0][3*i+2] = byte(i + 1)
+	}
+	want[1][2] = 11
+	want[1][5] = 12
+
+	if got := pt.EntriesPerWord(); got != 10 {
+		t.Errorf("%T.EntriesPerWord() got %d, want 10", pt, got)
+	}
+	if diff := cmp.Diff(want, pt.Words()); diff != "" {
+		t.Errorf("%T.Words() diff (-want +got):\n%s", pt, diff)
+	}
+}
+
+func TestSelection(t *testing.T) {
+	for n := 0; n <= 9; n++ {
+		vals := make([]int, n)
+		for i := range vals {
+			vals[i] = 10 * i
+		}
+		sel := newSelection(vals)
+		if n == 0 {
+			if sel != nil {
+				t.Errorf("newSelection(%v) got %+v, want nil", vals, sel)
+			}
+			continue
+		}
+
+		// Walks the tree as the generated code does.
+		for i, want := range vals {
+			s := sel
+			var depth int
+			for ; !s.Leaf; depth++ {
+				if i < s.Mid {
+					s = s.Left
+				} else {
+					s = s.Right
+				}
+			}
+			if s.Value != want {
+				t.Errorf("newSelection(%v) selects %d for index %d, want %d", vals, s.Value, i, want)
+			}
+			if max := bits.Len(uint(n - 1)); depth > max {
+				t.Errorf("newSelection(%v) selects index %d at depth %d, want at most %d", vals, i, depth, max)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/proofxyz/solidify/go/solfmt"
+)
+
+// Names of the templates used to generate contracts. These can be replaced
+// via GeneratorConfig.Templates by providing files with the same names.
+//
+// Each template is executed with the data type documented alongside it, which
+// constitutes a stable contract for custom templates. All templates are
+// parsed together with the HeaderTemplate partial and have access to the
+// default template functions (e.g. `hex`, `numFields` or `toLower`) and any
+// additional ones given by GeneratorConfig.Funcs.
+const (
+	// HeaderTemplate defines the "header" partial that is executed with the
+	// resolved *GeneratorConfig, e.g. `{{template "header" .Config}}`.
+	HeaderTemplate = "header.go.tmpl"
+	// BucketStorageTemplate is executed with BucketStorageData.
+	BucketStorageTemplate = "bucket-storage.go.tmpl"
+	// StorageDeployerTemplate is executed with StorageDeployerData.
+	StorageDeployerTemplate = "storage-deployer.go.tmpl"
+	// SequentialStorageMappingTemplate is executed with
+	// SequentialStorageMappingData.
+	SequentialStorageMappingTemplate = "sequential-storage-mapping.go.tmpl"
+	// TableStorageMappingTemplate is executed with TableStorageMappingData.
+	TableStorageMappingTemplate = "table-storage-mapping.go.tmpl"
+	// LabelledStorageMappingTemplate is executed with
+	// LabelledStorageMappingData.
+	LabelledStorageMappingTemplate = "labelled-storage-mapping.go.tmpl"
+	// FeaturesLibTemplate is executed with FeaturesLibData.
+	FeaturesLibTemplate = "features-lib.go.tmpl"
+	// StorageManagerTemplate is executed with StorageManagerData.
+	StorageManagerTemplate = "storage-manager.go.tmpl"
+	// StorageAddressesTemplate is executed with StorageAddressesData.
+	StorageAddressesTemplate = "storage-addresses.go.tmpl"
+	// DeployScriptTemplate is executed with DeployScriptData.
+	DeployScriptTemplate = "deploy-script.go.tmpl"
+	// MerkleFieldVerifierTemplate is executed with MerkleFieldVerifierData.
+	MerkleFieldVerifierTemplate = "merkle-field-verifier.go.tmpl"
+)
+
+// contractTemplates are the templates generating a contract file, as opposed
+// to partials.
+var contractTemplates = []string{
+	BucketStorageTemplate,
+	StorageDeployerTemplate,
+	SequentialStorageMappingTemplate,
+	TableStorageMappingTemplate,
+	LabelledStorageMappingTemplate,
+	FeaturesLibTemplate,
+	StorageManagerTemplate,
+	StorageAddressesTemplate,
+	DeployScriptTemplate,
+	MerkleFieldVerifierTemplate,
+}
+
+// BucketStorageData is the data passed to BucketStorageTemplate.
+type BucketStorageData struct {
+	Config *GeneratorConfig
+	Store  BucketStorage
+}
+
+// StorageDeployerData is the data passed to StorageDeployerTemplate.
+type StorageDeployerData struct {
+	Config *GeneratorConfig
+	Name   string
+	// StoragePath is the import path of the directory containing the storage
+	// contracts.
+	StoragePath string
+	Stores      []BucketStorage
+}
+
+// SequentialStorageMappingData is the data passed to
+// SequentialStorageMappingTemplate.
+type SequentialStorageMappingData struct {
+	Config       *GeneratorConfig
+	Name         string
+	FieldsGroups []FieldsGroup
+	Stores       []BucketStorage
+	Counts       *FieldCounts
+}
+
+// TableStorageMappingData is the data passed to TableStorageMappingTemplate.
+type TableStorageMappingData struct {
+	Config       *GeneratorConfig
+	Name         string
+	FieldsGroups []FieldsGroup
+	Table        *TableMapping
+}
+
+// LabelledStorageMappingData is the data passed to
+// LabelledStorageMappingTemplate.
+type LabelledStorageMappingData struct {
+	Config *GeneratorConfig
+	Name   string
+	Stores []BucketStorage
+	Table  *LabelledMapping
+}
+
+// FeaturesLibData is the data passed to FeaturesLibTemplate.
+type FeaturesLibData struct {
+	Config        *GeneratorConfig
+	FeatureGroups []FeatureGroup
+	MerkleRoot    []byte
+	NumTokens     int
+	// NumTokensBits is the width of the integer holding NumTokens.
+	NumTokensBits int
+}
+
+// StorageManagerData is the data passed to StorageManagerTemplate.
+type StorageManagerData struct {
+	Config *GeneratorConfig
+	Name   string
+	// StorageBundle is the name of the bundle whose deployer (or addresses
+	// library) holds the storages. It equals Name unless storages are shared
+	// between bundles, see WriteSharedStorageManager.
+	StorageBundle string
+	Groups        []ManagedGroup
+	// Type is the type returned by the generic loader, see
+	// WriteStorageManager.
+	Type FieldType
+	// Static indicates that storages are resolved via the addresses library
+	// instead of a deployed bundle, see WriteStaticStorageManager.
+	Static bool
+	// RevealCommitment, if not nil, is the commitment to the secret with which
+	// all buckets are encrypted, see WriteRevealStorageManager.
+	RevealCommitment *common.Hash
+}
+
+// MerkleFieldVerifierData is the data passed to MerkleFieldVerifierTemplate.
+type MerkleFieldVerifierData struct {
+	Config       *GeneratorConfig
+	Name         string
+	FieldsGroups []FieldsGroup
+	// Root is the root of the FieldsMerkleTree over all fields.
+	Root      common.Hash
+	NumFields int
+}
+
+var (
+	//go:embed templates/*.go.tmpl
+	embeddedTemplates embed.FS
+
+	// defaultTemplates are the parsed default templates keyed by name.
+	defaultTemplates = func() map[string]*template.Template {
+		ts, err := loadTemplates(nil, nil)
+		if err != nil {
+			panic(err)
+		}
+		return ts
+	}()
+)
+
+// loadTemplates parses all contract templates, preferring the ones in
+// overrides over the embedded defaults. The functions in funcs take precedence
+// over the default template functions.
+func loadTemplates(overrides fs.FS, funcs template.FuncMap) (map[string]*template.Template, error) {
+	if err := checkTemplateNames(overrides); err != nil {
+		return nil, err
+	}
+
+	read := func(name string) (string, error) {
+		if overrides != nil {
+			b, err := fs.ReadFile(overrides, name)
+			if err == nil {
+				return string(b), nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("fs.ReadFile([overrides], %q): %w", name, err)
+			}
+		}
+
+		b, err := embeddedTemplates.ReadFile(path.Join("templates", name))
+		if err != nil {
+			return "", fmt.Errorf("%T.ReadFile(%q): %w", embeddedTemplates, name, err)
+		}
+		return string(b), nil
+	}
+
+	header, err := read(HeaderTemplate)
+	if err != nil {
+		return nil, err
+	}
+	fm := addTemplateFuncs(tmplFuncsFeatures, funcs)
+
+	ts := make(map[string]*template.Template)
+	for _, name := range contractTemplates {
+		raw, err := read(name)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := template.New(name).Funcs(fm).Parse(header)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", HeaderTemplate, err)
+		}
+		if t.Lookup("header") == nil {
+			return nil, fmt.Errorf("%q does not define the %q partial", HeaderTemplate, "header")
+		}
+		if _, err := t.Parse(raw); err != nil {
+			return nil, fmt.Errorf("parsing %q: %w", name, err)
+		}
+		ts[name] = t
+	}
+
+	return ts, nil
+}
+
+// checkTemplateNames ensures that all templates in overrides replace a known
+// template, guarding against typos that would otherwise silently fall back to
+// the default.
+func checkTemplateNames(overrides fs.FS) error {
+	if overrides == nil {
+		return nil
+	}
+
+	known := map[string]bool{HeaderTemplate: true}
+	for _, n := range contractTemplates {
+		known[n] = true
+	}
+
+	entries, err := fs.ReadDir(overrides, ".")
+	if err != nil {
+		return fmt.Errorf("fs.ReadDir([overrides], %q): %w", ".", err)
+	}
+
+	var unknown []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".tmpl") {
+			continue
+		}
+		if !known[e.Name()] {
+			unknown = append(unknown, e.Name())
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown templates %q", unknown)
+	}
+
+	return nil
+}
+
+// executeTemplate executes the template with a given name using the templates
+// of a resolved config and writes the output in canonical format (see
+// solfmt.Format).
+func (c *GeneratorConfig) executeTemplate(name string, w io.Writer, data any) error {
+	ts := c.templates
+	if ts == nil {
+		ts = defaultTemplates
+	}
+
+	var buf bytes.Buffer
+	if err := ts[name].Execute(&buf, data); err != nil {
+		return err
+	}
+	src, err := solfmt.Format(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("solfmt.Format([output of %q]): %w", name, err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func convertFieldsGroups[G FieldsGroup](gs []G) []FieldsGroup {
+	gs2 := make([]FieldsGroup, len(gs))
+	for i, g := range gs {
+		gs2[i] = g
+	}
+	return gs2
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/proofxyz/solidify/go/solfmt"
+)
+
+func TestTemplateOverrides(t *testing.T) {
+	s := fakeStorage{name: "Foo", buckets: []Bucket{fakeBucket{numFields: 2}}}
+
+	tests := []struct {
+		name    string
+		cfg     *GeneratorConfig
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "Contract template",
+			cfg: &GeneratorConfig{
+				Templates: fstest.MapFS{
+					BucketStorageTemplate: {Data: []byte(`{{template "header" .Config}} {{.Store.Name}}/{{len .Store.Buckets}}`)},
+				},
+			},
+			// License and Copyright are omitted since they are empty.
+			want: "// GENERATED CODE - DO NOT EDIT\npragma solidity ^0.8.16; Foo/1\n",
+		},
+		{
+			name: "Header partial",
+			cfg: &GeneratorConfig{
+				Templates: fstest.MapFS{
+					HeaderTemplate:        {Data: []byte(`{{define "header"}}// {{.Pragma}}{{end}}`)},
+					BucketStorageTemplate: {Data: []byte(`{{template "header" .Config}}`)},
+				},
+			},
+			want: "// ^0.8.16\n",
+		},
+		{
+			name: "Extra funcs",
+			cfg: &GeneratorConfig{
+				Templates: fstest.MapFS{
+					BucketStorageTemplate: {Data: []byte(`{{shout .Store.Name}} {{toLower .Store.Name}}`)},
+				},
+				Funcs: template.FuncMap{
+					"shout":   strings.ToUpper,
+					"toLower": func(s string) string { return "lower:" + s },
+				},
+			},
+			want: "FOO lower:Foo\n",
+		},
+		{
+			name: "Unknown template",
+			cfg: &GeneratorConfig{
+				Templates: fstest.MapFS{
+					"bucket-storgae.go.tmpl": {Data: []byte(``)},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Parse error",
+			cfg: &GeneratorConfig{
+				Templates: fstest.MapFS{
+					StorageManagerTemplate: {Data: []byte(`{{.Name`)},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Undefined func",
+			cfg: &GeneratorConfig{
+				Templates: fstest.MapFS{
+					StorageManagerTemplate: {Data: []byte(`{{shout .Name}}`)},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Missing header partial",
+			cfg: &GeneratorConfig{
+				Templates: fstest.MapFS{
+					HeaderTemplate: {Data: []byte(`// header`)},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("%T.Validate() error %v, wantErr %t", tt.cfg, err, tt.wantErr)
+			}
+
+			var buf bytes.Buffer
+			err := WriteBucketStorage(s, tt.cfg, &buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WriteBucketStorage(…) error %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteBucketStorage(…) got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultOutputIsCanonical(t *testing.T) {
+	stores := []fakeStorage{
+		{name: "TestBucketStorage0", buckets: []Bucket{fakeBucket{numFields: 2}, fakeBucket{numFields: 1}}},
+		{name: "TestBucketStorage1", buckets: []Bucket{fakeBucket{numFields: 3}}},
+	}
+	groups := []fakeGroup{{"FOO", 2}, {"BAR", 4}}
+	plan, err := PlanDeployment(stores, DeploymentConfig{})
+	if err != nil {
+		t.Fatalf("PlanDeployment(…) error %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+	}{
+		{
+			name: "BucketStorage",
+			write: func(buf *bytes.Buffer) error {
+				return WriteBucketStorage(stores[0], nil, buf)
+			},
+		},
+		{
+			name: "StorageDeployer",
+			write: func(buf *bytes.Buffer) error {
+				return WriteStorageDeployer("Test", "./storage", stores, nil, buf)
+			},
+		},
+		{
+			name: "SequentialStorageMapping",
+			write: func(buf *bytes.Buffer) error {
+				return WriteSequentialStorageMapping("Test", groups, stores, nil, buf)
+			},
+		},
+		{
+			name: "TableStorageMapping",
+			write: func(buf *bytes.Buffer) error {
+				return WriteTableStorageMapping("Test", groups, stores, nil, buf)
+			},
+		},
+		{
+			name: "StorageManager",
+			write: func(buf *bytes.Buffer) error {
+				return WriteStorageManager("Test", groups, nil, buf)
+			},
+		},
+		{
+			name: "RevealStorageManager",
+			write: func(buf *bytes.Buffer) error {
+				return WriteRevealStorageManager("Test", groups, RevealSecret{1}.Commitment(), nil, buf)
+			},
+		},
+		{
+			name: "StaticStorageManager",
+			write: func(buf *bytes.Buffer) error {
+				return WriteStaticStorageManager("Test", groups, nil, buf)
+			},
+		},
+		{
+			name: "MerkleFieldVerifier",
+			write: func(buf *bytes.Buffer) error {
+				mt, err := NewFieldsMerkleTree(groups, fakeFields(groups))
+				if err != nil {
+					return err
+				}
+				return WriteMerkleFieldVerifier("Test", groups, mt, nil, buf)
+			},
+		},
+		{
+			name: "StorageAddresses",
+			write: func(buf *bytes.Buffer) error {
+				return WriteStorageAddresses("Test", plan, nil, buf)
+			},
+		},
+		{
+			name: "DeployScript",
+			write: func(buf *bytes.Buffer) error {
+				return WriteDeployScript("Test", plan, 0, "gen/artifacts", nil, buf)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf); err != nil {
+				t.Fatalf("Write%s(…) error %v", tt.name, err)
+			}
+
+			got, err := solfmt.Format(buf.Bytes())
+			if err != nil {
+				t.Fatalf("solfmt.Format([Write%s(…) output]) error %v", tt.name, err)
+			}
+			if diff := cmp.Diff(buf.String(), string(got)); diff != "" {
+				t.Errorf("Write%s(…) output is not canonical; diff (-want +got):\n%s", tt.name, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,430 @@
+// Package verify deploys storage contracts into an in-process EVM and checks
+// that every field can be retrieved as expected, without requiring a node or
+// hand-written forge tests.
+package verify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"path"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/proofxyz/solidify/go/deflate"
+	"github.com/proofxyz/solidify/go/storage"
+)
+
+// A SourceBucket is a storage.Bucket that exposes the fields it was built from.
+// The fields retrieved from deployed storages are compared against these.
+type SourceBucket interface {
+	storage.Bucket
+	Fields() []storage.Field
+}
+
+// A CodeSource returns the creation code of the contract deploying a given
+// storage.
+type CodeSource func(storage.BucketStorage) ([]byte, error)
+
+// Prebuilt is a CodeSource returning the bytecode assembled by
+// storage.StorageBytecode.
+func Prebuilt(s storage.BucketStorage) ([]byte, error) {
+	code, err := storage.StorageBytecode(s)
+	if err != nil {
+		return nil, err
+	}
+	return code.Creation, nil
+}
+
+// FoundryArtifacts returns a CodeSource reading the artifacts compiled by forge
+// (or written by storage.WriteStorageArtifacts) from
+// `<outDir>/<Name>.sol/<Name>.json` in fsys.
+func FoundryArtifacts(fsys fs.FS, outDir string) CodeSource {
+	return func(s storage.BucketStorage) ([]byte, error) {
+		return ReadArtifact(fsys, path.Join(outDir, s.Name()+".sol", s.Name()+".json"))
+	}
+}
+
+// HardhatArtifacts returns a CodeSource reading the artifacts compiled by
+// hardhat from `<artifactsDir>/<sourceDir>/<Name>.sol/<Name>.json` in fsys.
+func HardhatArtifacts(fsys fs.FS, artifactsDir, sourceDir string) CodeSource {
+	return func(s storage.BucketStorage) ([]byte, error) {
+		return ReadArtifact(fsys, path.Join(artifactsDir, sourceDir, s.Name()+".sol", s.Name()+".json"))
+	}
+}
+
+// ReadArtifact returns the creation code of a Foundry or Hardhat artifact,
+// which differ in whether the bytecode is nested in an object.
+func ReadArtifact(fsys fs.FS, p string) ([]byte, error) {
+	buf, err := fs.ReadFile(fsys, p)
+	if err != nil {
+		return nil, fmt.Errorf("fs.ReadFile(…, %q): %w", p, err)
+	}
+
+	var a struct {
+		Bytecode json.RawMessage `json:"bytecode"`
+	}
+	if err := json.Unmarshal(buf, &a); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal([%s]): %w", p, err)
+	}
+
+	var code hexutil.Bytes
+	if err := json.Unmarshal(a.Bytecode, &code); err != nil {
+		var nested struct {
+			Object hexutil.Bytes `json:"object"`
+		}
+		if err := json.Unmarshal(a.Bytecode, &nested); err != nil {
+			return nil, fmt.Errorf("artifact %q has no bytecode: %w", p, err)
+		}
+		code = nested.Object
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("artifact %q has empty bytecode", p)
+	}
+	return code, nil
+}
+
+// A Mismatch describes a field (or bucket or storage) that is not retrieved as
+// expected.
+type Mismatch struct {
+	Storage string
+	// Bucket is the index of the bucket in the storage, or -1 if the storage
+	// as a whole mismatches.
+	Bucket int
+	// Field is the index of the field in the bucket, or -1 if the bucket as a
+	// whole mismatches.
+	Field  int
+	Reason string
+	// Want and Got are the expected and retrieved values, if applicable.
+	Want, Got []byte
+}
+
+// String returns a single-line description of the mismatch.
+func (m Mismatch) String() string {
+	loc := m.Storage
+	if m.Bucket >= 0 {
+		loc += fmt.Sprintf(" bucket %d", m.Bucket)
+	}
+	if m.Field >= 0 {
+		loc += fmt.Sprintf(" field %d", m.Field)
+	}
+	return loc + ": " + m.Reason
+}
+
+// A Report summarises the verification of a bundle.
+type Report struct {
+	// Fields is the number of fields that were retrieved as expected.
+	Fields     int
+	Mismatches []Mismatch
+}
+
+// OK returns whether all fields were retrieved as expected.
+func (r *Report) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+// String returns one line per mismatch.
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, m := range r.Mismatches {
+		fmt.Fprintln(&b, m.String())
+	}
+	return b.String()
+}
+
+// Err returns nil if all fields were retrieved as expected, and an error
+// listing the mismatches otherwise.
+func (r *Report) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return fmt.Errorf("%d mismatches in deployed storages:\n%s", len(r.Mismatches), r.String())
+}
+
+// bucketStorageABI is the parsed storage.BucketStorageABI.
+var bucketStorageABI = func() abi.ABI {
+	a, err := abi.JSON(strings.NewReader(storage.BucketStorageABI))
+	if err != nil {
+		panic(err)
+	}
+	return a
+}()
+
+// Bundle deploys the contract of each storage, as returned by code, into an
+// in-process EVM. For every bucket, it calls `getBucket()`, inflates the data
+// and extracts every field in the same way as `IndexedBucketLib` and
+// `LabelledBucketLib`, comparing them to the fields of the Go-side
+// SourceBucket.
+//
+// Buckets that don't implement SourceBucket, or that are of custom flavour,
+// are only compared as a whole against their Go-side data. The deployed data
+// of encrypted buckets (see storage.EncryptedBucket) is compared against their
+// ciphertext, and their fields are then verified against those of the
+// unwrapped bucket. The returned error
+// is reserved for failures to run the verification, e.g. missing artifacts or
+// failing deployments, while mismatches are recorded in the Report.
+func Bundle[S storage.BucketStorage](stores []S, code CodeSource) (*Report, error) {
+	cfg := new(runtime.Config)
+	r := new(Report)
+
+	for _, s := range stores {
+		creation, err := code(s)
+		if err != nil {
+			return nil, fmt.Errorf("loading code of %q: %w", s.Name(), err)
+		}
+		_, addr, _, err := runtime.Create(creation, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("deploying %q: %w", s.Name(), err)
+		}
+
+		if err := verifyStorage(r, s, func(method string, args ...any) ([]any, error) {
+			in, err := bucketStorageABI.Pack(method, args...)
+			if err != nil {
+				return nil, fmt.Errorf("%T.Pack(%q, …): %w", bucketStorageABI, method, err)
+			}
+			ret, _, err := runtime.Call(addr, in, cfg)
+			if err != nil {
+				return nil, err
+			}
+			return bucketStorageABI.Unpack(method, ret)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// compressed mirrors the Compressed struct for ABI decoding.
+type compressed struct {
+	UncompressedSize *big.Int `abi:"uncompressedSize"`
+	Data             []byte   `abi:"data"`
+}
+
+// verifyStorage verifies all buckets of a deployed storage, recording
+// mismatches in r.
+func verifyStorage(r *Report, s storage.BucketStorage, call func(string, ...any) ([]any, error)) error {
+	mismatch := func(bucket, field int, reason string, want, got []byte) {
+		r.Mismatches = append(r.Mismatches, Mismatch{
+			Storage: s.Name(),
+			Bucket:  bucket,
+			Field:   field,
+			Reason:  reason,
+			Want:    want,
+			Got:     got,
+		})
+	}
+
+	out, err := call("numBuckets")
+	if err != nil {
+		return fmt.Errorf("%q.numBuckets(): %w", s.Name(), err)
+	}
+	if n := out[0].(*big.Int); n.Cmp(big.NewInt(int64(len(s.Buckets())))) != 0 {
+		mismatch(-1, -1, fmt.Sprintf("got %v buckets; want %d", n, len(s.Buckets())), nil, nil)
+		return nil
+	}
+
+	for i, b := range s.Buckets() {
+		out, err := call("getBucket", big.NewInt(int64(i)))
+		if err != nil {
+			mismatch(i, -1, fmt.Sprintf("getBucket(%d) failed: %v", i, err), nil, nil)
+			continue
+		}
+		got := *abi.ConvertType(out[0], new(compressed)).(*compressed)
+
+		if n := got.UncompressedSize; n.Cmp(big.NewInt(int64(b.UncompressedSize()))) != 0 {
+			mismatch(i, -1, fmt.Sprintf("got uncompressed size %v; want %d", n, b.UncompressedSize()), nil, nil)
+			continue
+		}
+
+		compressed := got.Data
+		if storage.BucketFormatOf(b).Codec == storage.CodecDeflateEncrypted {
+			want, err := b.Data()
+			if err != nil {
+				return fmt.Errorf("%T.Data(): %w", b, err)
+			}
+			if !bytes.Equal(compressed, want) {
+				mismatch(i, -1, "encrypted data differs", want, compressed)
+				continue
+			}
+			u, ok := b.(interface{ Unwrap() storage.Bucket })
+			if !ok {
+				r.Fields += b.NumFields()
+				continue
+			}
+			// Encryption is deterministic, so matching ciphertexts decrypt to
+			// the data of the unencrypted bucket.
+			b = u.Unwrap()
+			if compressed, err = b.Data(); err != nil {
+				return fmt.Errorf("%T.Data(): %w", b, err)
+			}
+		}
+
+		data, err := deflate.Inflate(&deflate.Compressed{Data: compressed, UncompressedSize: b.UncompressedSize()})
+		if err != nil {
+			mismatch(i, -1, fmt.Sprintf("inflating: %v", err), nil, nil)
+			continue
+		}
+		if len(data) != b.UncompressedSize() {
+			mismatch(i, -1, fmt.Sprintf("inflated to %d bytes; want %d", len(data), b.UncompressedSize()), nil, data)
+			continue
+		}
+
+		src, ok := b.(SourceBucket)
+		format := storage.BucketFormatOf(b)
+		if !ok || format.Flavour == storage.FlavourCustom {
+			want, err := inflateBucket(b)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(data, want) {
+				mismatch(i, -1, "bucket differs", want, data)
+				continue
+			}
+			r.Fields += b.NumFields()
+			continue
+		}
+
+		for j, f := range src.Fields() {
+			want, err := f.Encode()
+			if err != nil {
+				return fmt.Errorf("%T.Encode(): %w", f, err)
+			}
+			got, err := extractField(data, format, j, f)
+			if err != nil {
+				mismatch(i, j, err.Error(), want, nil)
+				continue
+			}
+			if !bytes.Equal(got, want) {
+				mismatch(i, j, "field differs", want, got)
+				continue
+			}
+			r.Fields++
+		}
+	}
+
+	return nil
+}
+
+// inflateBucket returns the uncompressed Go-side data of a bucket.
+func inflateBucket(b storage.Bucket) ([]byte, error) {
+	d, err := b.Data()
+	if err != nil {
+		return nil, fmt.Errorf("%T.Data(): %w", b, err)
+	}
+	data, err := deflate.Inflate(&deflate.Compressed{Data: d, UncompressedSize: b.UncompressedSize()})
+	if err != nil {
+		return nil, fmt.Errorf("deflate.Inflate([data of %T]): %w", b, err)
+	}
+	return data, nil
+}
+
+// extractField retrieves the field with a given index from inflated bucket
+// data, mirroring the solidify libraries of the bucket's flavour.
+func extractField(data []byte, format storage.BucketFormat, idx int, f storage.Field) ([]byte, error) {
+	switch format.Flavour {
+	case storage.FlavourIndexed:
+		return indexedField(data, int(format.OffsetBits/8), idx)
+	case storage.FlavourLabelled:
+		lf, ok := f.(storage.LabelledField)
+		if !ok {
+			return nil, fmt.Errorf("field %T in labelled bucket has no label", f)
+		}
+		return labelledField(data, int(format.LabelBits/8), int(format.FieldSize), lf.Label())
+	default:
+		return nil, fmt.Errorf("unsupported flavour %d", format.Flavour)
+	}
+}
+
+// uintAt returns the big-endian unsigned integer of a given width at an offset.
+func uintAt(data []byte, off, width int) (int, error) {
+	if width < 1 || width > 8 || off+width > len(data) {
+		return 0, fmt.Errorf("cannot read %d bytes at offset %d of %d", width, off, len(data))
+	}
+	var buf [8]byte
+	copy(buf[8-width:], data[off:off+width])
+	return int(binary.BigEndian.Uint64(buf[:])), nil
+}
+
+// indexedField mirrors `IndexedBucketLib.getField()`.
+func indexedField(data []byte, width, idx int) ([]byte, error) {
+	first, err := uintAt(data, 0, width)
+	if err != nil {
+		return nil, err
+	}
+	if n := first / width; idx >= n {
+		return nil, fmt.Errorf("field index %d out of bounds for %d fields", idx, n)
+	}
+
+	start, err := uintAt(data, idx*width, width)
+	if err != nil {
+		return nil, err
+	}
+	end := len(data)
+	if next := (idx + 1) * width; next < first {
+		if end, err = uintAt(data, next, width); err != nil {
+			return nil, err
+		}
+	}
+	if start > end || end > len(data) {
+		return nil, fmt.Errorf("invalid field bounds [%d, %d) in %d bytes", start, end, len(data))
+	}
+	return data[start:end], nil
+}
+
+// labelledField mirrors `LabelledBucketLib.findFieldByLabel()`, including its
+// binary search over the labels. Buckets with unsorted labels may therefore
+// fail to return a field that a linear scan would find, as on-chain.
+func labelledField(data []byte, width, size int, label uint16) ([]byte, error) {
+	chunk := width + size
+	if chunk == 0 || len(data)%chunk != 0 || len(data) == 0 {
+		return nil, fmt.Errorf("bucket of %d bytes cannot be divided into %d-byte fields", len(data), chunk)
+	}
+	labelAt := func(i int) (int, error) {
+		return uintAt(data, i*chunk, width)
+	}
+	field := func(i int) []byte {
+		return data[i*chunk+width : (i+1)*chunk]
+	}
+
+	ia, ib := 0, len(data)/chunk-1
+	a, err := labelAt(ia)
+	if err != nil {
+		return nil, err
+	}
+	if a == int(label) {
+		return field(ia), nil
+	}
+	b, err := labelAt(ib)
+	if err != nil {
+		return nil, err
+	}
+	if b == int(label) {
+		return field(ib), nil
+	}
+	if int(label) < a || b < int(label) {
+		return nil, fmt.Errorf("label %d outside of binary search bounds [%d, %d]", label, a, b)
+	}
+
+	for ib-ia >= 2 {
+		im := (ia + ib) >> 1
+		m, err := labelAt(im)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case m == int(label):
+			return field(im), nil
+		case m < int(label):
+			ia = im
+		default:
+			ib = im
+		}
+	}
+	return nil, fmt.Errorf("label %d not found", label)
+}
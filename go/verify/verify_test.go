@@ -0,0 +1,210 @@
+package verify
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/proofxyz/solidify/go/aggregators"
+	"github.com/proofxyz/solidify/go/storage"
+)
+
+type fakeField string
+
+func (f fakeField) Encode() ([]byte, error) { return []byte(f), nil }
+
+type fakeLabelledField struct {
+	label uint16
+	data  string
+}
+
+func (f fakeLabelledField) Encode() ([]byte, error) { return []byte(f.data), nil }
+func (f fakeLabelledField) Label() uint16           { return f.label }
+
+// bundle returns a storage containing an indexed and a labelled bucket with
+// the given fields.
+func bundle(t *testing.T, indexed []string, labelled []fakeLabelledField) []*aggregators.BucketStorage {
+	t.Helper()
+
+	ib := new(aggregators.IndexedBucket)
+	for _, f := range indexed {
+		if err := ib.AddField(fakeField(f)); err != nil {
+			t.Fatalf("%T.AddField(%q) error %v", ib, f, err)
+		}
+	}
+	lb := new(aggregators.LabelledBucket)
+	for _, f := range labelled {
+		if err := lb.AddField(f); err != nil {
+			t.Fatalf("%T.AddField(%v) error %v", lb, f, err)
+		}
+	}
+
+	s := aggregators.NewBucketStorage("TestBucketStorage0")
+	s.AddBucket(ib)
+	s.AddBucket(lb)
+	return []*aggregators.BucketStorage{s}
+}
+
+var (
+	indexed  = []string{"foo", "", "bar", "bazqux"}
+	labelled = []fakeLabelledField{{1, "aa"}, {5, "bb"}, {7, "cc"}}
+)
+
+func TestBundle(t *testing.T) {
+	stores := bundle(t, indexed, labelled)
+
+	r, err := Bundle(stores, Prebuilt)
+	if err != nil {
+		t.Fatalf("Bundle(…, Prebuilt) error %v", err)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Bundle(…, Prebuilt) %v", err)
+	}
+	if got, want := r.Fields, len(indexed)+len(labelled); got != want {
+		t.Errorf("Bundle(…, Prebuilt) verified %d fields; want %d", got, want)
+	}
+}
+
+func TestBundleEncrypted(t *testing.T) {
+	secret := storage.RevealSecret{1, 2, 3}
+	encrypt := func(stores []*aggregators.BucketStorage, s storage.RevealSecret) []*aggregators.BucketStorage {
+		enc := aggregators.NewBucketStorage(stores[0].Name())
+		for _, b := range stores[0].Buckets() {
+			enc.AddBucket(storage.EncryptBucket(b, s))
+		}
+		return []*aggregators.BucketStorage{enc}
+	}
+	stores := encrypt(bundle(t, indexed, labelled), secret)
+
+	r, err := Bundle(stores, Prebuilt)
+	if err != nil {
+		t.Fatalf("Bundle([encrypted], Prebuilt) error %v", err)
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Bundle([encrypted], Prebuilt) %v", err)
+	}
+	if got, want := r.Fields, len(indexed)+len(labelled); got != want {
+		t.Errorf("Bundle([encrypted], Prebuilt) verified %d fields; want %d", got, want)
+	}
+
+	// The same data encrypted with a different secret.
+	deployed := encrypt(bundle(t, indexed, labelled), storage.RevealSecret{4, 5, 6})
+	r, err = Bundle(stores, func(storage.BucketStorage) ([]byte, error) {
+		return Prebuilt(deployed[0])
+	})
+	if err != nil {
+		t.Fatalf("Bundle([encrypted], …) error %v", err)
+	}
+	var got []string
+	for _, m := range r.Mismatches {
+		got = append(got, m.Reason)
+	}
+	if diff := cmp.Diff([]string{"encrypted data differs", "encrypted data differs"}, got); diff != "" {
+		t.Errorf("Bundle([encrypted with other secret], …) mismatch reasons diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestBundleMismatches(t *testing.T) {
+	stores := bundle(t, indexed, labelled)
+
+	// Deploy different data than the source to provoke mismatches.
+	deployed := bundle(t,
+		[]string{"foo", "", "baz", "bazqux"},
+		[]fakeLabelledField{{1, "aa"}, {6, "bb"}, {7, "dd"}},
+	)
+	code := func(storage.BucketStorage) ([]byte, error) {
+		return Prebuilt(deployed[0])
+	}
+
+	r, err := Bundle(stores, code)
+	if err != nil {
+		t.Fatalf("Bundle(…) error %v", err)
+	}
+
+	type loc struct{ bucket, field int }
+	var got []loc
+	for _, m := range r.Mismatches {
+		got = append(got, loc{m.Bucket, m.Field})
+	}
+	want := []loc{{0, 2}, {1, 1}, {1, 2}}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(loc{})); diff != "" {
+		t.Errorf("Bundle(…) mismatches diff (-want +got):\n%s\n%v", diff, r)
+	}
+	if got, want := r.Fields, 4; got != want {
+		t.Errorf("Bundle(…) verified %d fields; want %d", got, want)
+	}
+	if err := r.Err(); err == nil || !strings.Contains(err.Error(), "TestBucketStorage0 bucket 1 field 1: label 5 not found") {
+		t.Errorf("%T.Err() got %v; want error describing missing label", r, err)
+	}
+}
+
+func TestArtifacts(t *testing.T) {
+	stores := bundle(t, indexed, labelled)
+
+	mem := new(storage.MemFS)
+	if _, err := storage.WriteStorageArtifacts(stores, storage.FoundryArtifacts, "src", mem, "out"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.WriteStorageArtifacts(stores, storage.HardhatArtifacts, "src", mem, "artifacts"); err != nil {
+		t.Fatal(err)
+	}
+	fsys := make(fstest.MapFS)
+	for p, b := range mem.Files() {
+		fsys[p] = &fstest.MapFile{Data: b}
+	}
+
+	tests := []struct {
+		name string
+		code CodeSource
+	}{
+		{"Foundry", FoundryArtifacts(fsys, "out")},
+		{"Hardhat", HardhatArtifacts(fsys, "artifacts", "src")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Bundle(stores, tt.code)
+			if err != nil {
+				t.Fatalf("Bundle(…) error %v", err)
+			}
+			if err := r.Err(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	if _, err := Bundle(stores, FoundryArtifacts(fsys, "missing")); err == nil {
+		t.Errorf("Bundle(…, FoundryArtifacts([fs], %q)) got nil error; want error", "missing")
+	}
+}
+
+func TestLabelledFieldBinarySearch(t *testing.T) {
+	// Labels [1, 9, 5, 7] with 1-byte payloads; 5 and 9 would only be found
+	// by a linear scan since the labels are not sorted.
+	data := []byte{0, 1, 'a', 0, 9, 'b', 0, 5, 'c', 0, 7, 'd'}
+
+	tests := []struct {
+		label        uint16
+		want         string
+		wantContains string
+	}{
+		{label: 1, want: "a"},
+		{label: 7, want: "d"},
+		{label: 5, wantContains: "label 5 not found"},
+		{label: 9, wantContains: "outside of binary search bounds [1, 7]"},
+	}
+
+	for _, tt := range tests {
+		got, err := labelledField(data, 2, 1, tt.label)
+		if tt.wantContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantContains) {
+				t.Errorf("labelledField(…, %d) got (%q, %v); want error containing %q", tt.label, got, err, tt.wantContains)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.want {
+			t.Errorf("labelledField(…, %d) got (%q, %v); want (%q, nil)", tt.label, got, err, tt.want)
+		}
+	}
+}
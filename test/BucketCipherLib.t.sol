@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+// Copyright 2022 PROOF Holdings Inc
+pragma solidity ^0.8.15;
+
+import "forge-std/Test.sol";
+
+import {BucketCipherLib} from "solidify-contracts/BucketCipherLib.sol";
+import {Compressed} from "solidify-contracts/Compressed.sol";
+
+contract BucketCipherLibTest is Test {
+    using BucketCipherLib for Compressed;
+
+    bytes32 internal constant SECRET =
+        0x0102030000000000000000000000000000000000000000000000000000000000;
+
+    // Generated with `storage.RevealSecret.Encrypt` in the Go toolchain.
+    bytes internal constant PLAIN =
+        "solidify encrypts buckets until revealed!";
+    bytes internal constant ENCRYPTED =
+        hex"c218f5affd4c0d9db484162cc1d215b2c7547c12dc8b2b0348f263d10a0f9cf4f887403c07c8b908e823e64a8ef1c8bcb62f2ccd7b66632303e1b0277d348b3252e8fb428f19503599";
+
+    function testCommitment() public {
+        assertEq(
+            BucketCipherLib.commitment(SECRET),
+            0x56124931ab59c7e57be4479a70532e29a620a6fa6ee371fdcf2edd845a8945df
+        );
+    }
+
+    function testDecryptGoVector() public {
+        Compressed memory dec =
+            Compressed({uncompressedSize: 42, data: ENCRYPTED}).decrypt(SECRET);
+
+        assertEq(dec.uncompressedSize, 42);
+        assertEq(dec.data, PLAIN);
+    }
+
+    function testDecrypt(bytes32 secret, bytes32 nonce, bytes memory plain)
+        public
+    {
+        bytes memory enc = abi.encodePacked(nonce);
+        for (uint256 i; i < plain.length; ++i) {
+            bytes32 key = keccak256(abi.encodePacked(secret, nonce, i / 32));
+            enc = abi.encodePacked(enc, plain[i] ^ key[i % 32]);
+        }
+
+        bytes memory dec =
+            Compressed({uncompressedSize: 0, data: enc}).decrypt(secret).data;
+        assertEq(dec, plain);
+        assertEq(keccak256(dec), keccak256(plain));
+    }
+
+    function testDecryptInvalidCiphertext() public {
+        vm.expectRevert(BucketCipherLib.InvalidCiphertext.selector);
+        this.decrypt(hex"0102");
+    }
+
+    function decrypt(bytes calldata data) external pure returns (bytes memory) {
+        return Compressed({uncompressedSize: 0, data: data}).decrypt(SECRET).data;
+    }
+}
@@ -14,6 +14,7 @@ import {
     FieldCoordinates
 } from "solidify-contracts/BucketStorageLib.sol";
 import {IndexedBucketLib} from "solidify-contracts/IndexedBucketLib.sol";
+import {BucketFormatLib} from "solidify-contracts/BucketFormatLib.sol";
 
 contract StubBucketStorage0 is IBucketStorage {
     function numBuckets() external pure returns (uint256) {
@@ -36,6 +37,30 @@ contract StubBucketStorage0 is IBucketStorage {
         pure
         returns (Compressed memory)
     {}
+
+    function getBuckets(uint256[] calldata bucketIndices)
+        external
+        pure
+        returns (Compressed[] memory)
+    {}
+
+    function bucketHash(uint256 bucketIndex) external pure returns (bytes32) {}
+
+    function storageRoot() external pure returns (bytes32) {}
+
+    function bucketFormat(uint256 bucketIndex)
+        external
+        pure
+        returns (bytes32)
+    {}
+
+    function storageFormat() external pure returns (bytes32) {}
+
+    function supportsInterface(bytes4 interfaceId)
+        external
+        pure
+        returns (bool)
+    {}
 }
 
 contract StubBucketStorage1 is IBucketStorage {
@@ -59,6 +84,88 @@ contract StubBucketStorage1 is IBucketStorage {
         pure
         returns (Compressed memory)
     {}
+
+    function getBuckets(uint256[] calldata bucketIndices)
+        external
+        pure
+        returns (Compressed[] memory)
+    {}
+
+    function bucketHash(uint256 bucketIndex) external pure returns (bytes32) {}
+
+    function storageRoot() external pure returns (bytes32) {}
+
+    function bucketFormat(uint256 bucketIndex)
+        external
+        pure
+        returns (bytes32)
+    {}
+
+    function storageFormat() external pure returns (bytes32) {}
+
+    function supportsInterface(bytes4 interfaceId)
+        external
+        pure
+        returns (bool)
+    {}
+}
+
+contract StubEncryptedBucketStorage is IBucketStorage {
+    // Generated with `storage.RevealSecret.Encrypt` in the Go toolchain, see
+    // also `BucketCipherLibTest`.
+    bytes internal constant ENCRYPTED =
+        hex"c218f5affd4c0d9db484162cc1d215b2c7547c12dc8b2b0348f263d10a0f9cf4f887403c07c8b908e823e64a8ef1c8bcb62f2ccd7b66632303e1b0277d348b3252e8fb428f19503599";
+
+    function numBuckets() external pure returns (uint256) {
+        return 1;
+    }
+
+    function numFields() external pure returns (uint256) {
+        return 1;
+    }
+
+    function numFieldsPerBucket() external pure returns (uint256[] memory) {
+        uint256[] memory ret = new uint[](1);
+        ret[0] = 1;
+        return ret;
+    }
+
+    function getBucket(uint256) public pure returns (Compressed memory) {
+        return Compressed({uncompressedSize: 42, data: ENCRYPTED});
+    }
+
+    function getBuckets(uint256[] calldata bucketIndices)
+        external
+        pure
+        returns (Compressed[] memory buckets)
+    {
+        buckets = new Compressed[](bucketIndices.length);
+        for (uint256 i; i < bucketIndices.length; ++i) {
+            buckets[i] = getBucket(bucketIndices[i]);
+        }
+    }
+
+    function bucketHash(uint256 bucketIndex) external pure returns (bytes32) {}
+
+    function storageRoot() external pure returns (bytes32) {}
+
+    function bucketFormat(uint256) public pure returns (bytes32) {
+        return bytes32(
+            uint256(BucketFormatLib.LIBRARY_VERSION) << 248
+                | uint256(BucketFormatLib.FLAVOUR_INDEXED) << 240
+                | uint256(BucketFormatLib.CODEC_DEFLATE_ENCRYPTED) << 232
+        );
+    }
+
+    function storageFormat() external pure returns (bytes32) {
+        return bucketFormat(0);
+    }
+
+    function supportsInterface(bytes4 interfaceId)
+        external
+        pure
+        returns (bool)
+    {}
 }
 
 contract BucketStorageLibTest is Test {
@@ -135,3 +242,70 @@ contract BucketStorageLibTest is Test {
         _testLocateByFieldGroupAndIndex(3, 0, 1, 1, 4);
     }
 }
+
+contract BucketStorageLibEncryptedTest is Test {
+    using BucketStorageLib for IBucketStorage[];
+
+    bytes32 internal constant SECRET =
+        0x0102030000000000000000000000000000000000000000000000000000000000;
+    bytes32 internal constant COMMITMENT =
+        0x56124931ab59c7e57be4479a70532e29a620a6fa6ee371fdcf2edd845a8945df;
+
+    IBucketStorage[] public bundle;
+
+    constructor() {
+        bundle.push(new StubEncryptedBucketStorage());
+    }
+
+    function _coords() internal pure returns (BucketCoordinates memory) {
+        return BucketCoordinates({storageId: 0, bucketId: 0});
+    }
+
+    function testLoadCompressedRevealed() public {
+        Compressed memory data =
+            bundle.loadCompressedRevealed(_coords(), SECRET, COMMITMENT);
+        assertEq(data.uncompressedSize, 42);
+        assertEq(data.data, "solidify encrypts buckets until revealed!");
+    }
+
+    function testCannotLoadRevealedWithWrongSecret() public {
+        bytes32 secret = bytes32(uint256(SECRET) + 1);
+        vm.expectRevert(
+            abi.encodeWithSelector(
+                BucketStorageLib.InvalidRevealSecret.selector,
+                keccak256(abi.encodePacked(secret)),
+                COMMITMENT
+            )
+        );
+        this.loadCompressedRevealed(secret);
+    }
+
+    function testCheckFormat() public view {
+        BucketStorageLib.checkFormat(
+            bundle[0].bucketFormat(0), BucketFormatLib.FLAVOUR_INDEXED
+        );
+    }
+
+    function testCannotLoadEncryptedChecked() public {
+        vm.expectRevert(
+            abi.encodeWithSelector(
+                BucketStorageLib.EncryptedBucket.selector, _coords()
+            )
+        );
+        this.loadUncompressedChecked();
+    }
+
+    function loadCompressedRevealed(bytes32 secret)
+        external
+        view
+        returns (Compressed memory)
+    {
+        return bundle.loadCompressedRevealed(_coords(), secret, COMMITMENT);
+    }
+
+    function loadUncompressedChecked() external view returns (bytes memory) {
+        return bundle.loadUncompressedChecked(
+            _coords(), BucketFormatLib.FLAVOUR_INDEXED
+        );
+    }
+}
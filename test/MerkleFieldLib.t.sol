@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: MIT
+// Copyright 2022 PROOF Holdings Inc
+pragma solidity ^0.8.15;
+
+import "forge-std/Test.sol";
+
+import {MerkleFieldLib} from "solidify-contracts/MerkleFieldLib.sol";
+
+contract MerkleFieldLibTest is Test {
+    // Generated with `storage.FieldsMerkleTree` in the Go toolchain over the
+    // fields [["FOO-0", "FOO-1"], ["BAR-0"]].
+    bytes32 internal constant ROOT =
+        0xfc02a955fc67382b935abdcc0c59b154af20ebaab62d9c768d0cc157995b1660;
+
+    function testVerifyGoVector() public {
+        bytes32[] memory proof = new bytes32[](2);
+        proof[0] =
+            0x9a307a802060050cf8ee325235696839428edf38e49f0183849c3aed2315d4b3;
+        proof[1] =
+            0xa866455299246dce4810012c4f1043476a7ce7786a07e5c09e10ca28123e199a;
+        assertTrue(this.verify(ROOT, 0, 1, "FOO-1", proof));
+        assertFalse(this.verify(ROOT, 0, 1, "FOO-0", proof));
+        assertFalse(this.verify(ROOT, 0, 0, "FOO-1", proof));
+
+        // The last leaf has no sibling on the first level.
+        proof = new bytes32[](1);
+        proof[0] =
+            0x839178d89894a51f072302396166461f0e075bcc9f8aeaa03cf81df2f27d8e49;
+        assertTrue(this.verify(ROOT, 1, 0, "BAR-0", proof));
+        assertFalse(this.verify(ROOT, 0, 2, "BAR-0", proof));
+    }
+
+    function testSingleLeaf(uint256 group, uint256 index, bytes memory data)
+        public
+    {
+        bytes32 root = this.leaf(group, index, data);
+        assertTrue(this.verify(root, group, index, data, new bytes32[](0)));
+    }
+
+    function testSortedPairs(
+        bytes memory a,
+        bytes memory b,
+        bytes memory c
+    ) public {
+        bytes32[3] memory leaves =
+            [this.leaf(0, 0, a), this.leaf(0, 1, b), this.leaf(0, 2, c)];
+        bytes32 root = _hashPair(_hashPair(leaves[0], leaves[1]), leaves[2]);
+
+        bytes32[] memory proof = new bytes32[](2);
+        proof[0] = leaves[0];
+        proof[1] = leaves[2];
+        assertTrue(this.verify(root, 0, 1, b, proof));
+
+        proof = new bytes32[](1);
+        proof[0] = _hashPair(leaves[0], leaves[1]);
+        assertTrue(this.verify(root, 0, 2, c, proof));
+    }
+
+    function _hashPair(bytes32 a, bytes32 b) internal pure returns (bytes32) {
+        return a < b
+            ? keccak256(abi.encodePacked(a, b))
+            : keccak256(abi.encodePacked(b, a));
+    }
+
+    function leaf(uint256 group, uint256 index, bytes calldata data)
+        external
+        pure
+        returns (bytes32)
+    {
+        return MerkleFieldLib.leaf(group, index, data);
+    }
+
+    function verify(
+        bytes32 root,
+        uint256 group,
+        uint256 index,
+        bytes calldata data,
+        bytes32[] calldata proof
+    ) external pure returns (bool) {
+        return MerkleFieldLib.verify(root, group, index, data, proof);
+    }
+}
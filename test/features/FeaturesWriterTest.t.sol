@@ -14,6 +14,7 @@ import {
     BucketCoordinates
 } from "solidify-contracts/BucketStorageLib.sol";
 import {LabelledBucketLib} from "solidify-contracts/LabelledBucketLib.sol";
+import {BucketFormatLib} from "solidify-contracts/BucketFormatLib.sol";
 
 import {Features, FeatureType, FeaturesLib} from "./gen/Features.sol";
 import {FeaturesStorageDeployer} from "./gen/FeaturesStorageDeployer.sol";
@@ -87,6 +88,36 @@ contract FeaturesWriterTest is Test {
         assertEq(_loadMapped(7), Features({foo: 0, bar: 3, qux: 1}));
     }
 
+    function testCannotLocateLabelOutsideOfBuckets() public {
+        vm.expectRevert(
+            abi.encodeWithSelector(
+                FeaturesStorageMapping.LabelNotFound.selector, 8
+            )
+        );
+        FeaturesStorageMapping.locate(8);
+    }
+
+    function testCannotLoadLabelInGap() public {
+        // Token 3 lies within the range of the second bucket but is not
+        // stored.
+        vm.expectRevert(
+            abi.encodeWithSelector(LabelledBucketLib.LabelNotFound.selector, 3)
+        );
+        _loadMapped(3);
+    }
+
+    function testFormat() public {
+        for (uint256 i; i < bundle.length; ++i) {
+            BucketStorageLib.checkStorage(
+                bundle[i], BucketFormatLib.FLAVOUR_LABELLED
+            );
+
+            bytes32 format = bundle[i].storageFormat();
+            assertEq(BucketFormatLib.labelBits(format), 16);
+            assertEq(BucketFormatLib.fieldSize(format), 3);
+        }
+    }
+
     function testDebugJson() public {
         assertEq(loader.getFeatures(0), Features({foo: 0, bar: 1, qux: 1}));
         assertEq(loader.getFeatures(1), Features({foo: 2, bar: 3, qux: 0}));
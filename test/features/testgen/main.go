@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -9,7 +10,10 @@ import (
 	"github.com/proofxyz/solidify/go/types"
 )
 
+var forgeFmt = flag.Bool("forgeFmt", false, "Additionally format the generated contracts with `forge fmt`.")
+
 func main() {
+	flag.Parse()
 	if err := run(); err != nil {
 		fmt.Fprint(os.Stderr, err)
 		os.Exit(1)
@@ -64,21 +68,23 @@ func run() error {
 		return fmt.Errorf("utils.GroupIntoLabelledBuckets(%T, %d): %w", tokens, maxFeaturesBucketSize, err)
 	}
 
-	ss, err := aggregators.GroupIntoStorages(buckets, -1, 2, "Features")
+	ss, err := aggregators.GroupIntoStorages(buckets, -1, 2, "Features", nil)
 	if err != nil {
 		return fmt.Errorf("utils.GroupIntoStorages(%T, %v, %v, %q): %w", buckets, -1, 2, "Features", err)
 	}
 
-	fNames, err := storage.WriteFeaturesContracts(gs, ss, mt, genDst)
+	fNames, err := storage.WriteFeaturesContracts(gs, ss, mt, nil, genDst)
 	if err != nil {
 		return fmt.Errorf("storage.WriteFeaturesContracts(%q, %T, %T, %q): %w", "Group", gs, ss, genDst, err)
 	}
 
-	if err := storage.FormatSol(fNames); err != nil {
-		return fmt.Errorf("utils.FormatSol(%v): %w", fNames, err)
+	if *forgeFmt {
+		if err := storage.FormatSol(fNames); err != nil {
+			return fmt.Errorf("utils.FormatSol(%v): %w", fNames, err)
+		}
 	}
 
-	if err := storage.WriteFeaturesJSONToFile(gs, tokens, featuresJSON); err != nil {
+	if err := storage.WriteFeaturesJSONToFile(gs, tokens, nil, featuresJSON); err != nil {
 		return fmt.Errorf("utils.WriteAllFeaturesJSON(%T, %T, %q): %w", gs, tokens, featuresJSON, err)
 	}
 
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MIT
+// Copyright 2022 PROOF Holdings Inc
+pragma solidity >=0.8.16 <0.9.0;
+
+import {IBucketStorage} from "solidify-contracts/IBucketStorage.sol";
+import {
+    InflateLibWrapper,
+    Compressed
+} from "solidify-contracts/InflateLibWrapper.sol";
+import {IndexedBucketLib} from "solidify-contracts/IndexedBucketLib.sol";
+import {LabelledBucketLib} from "solidify-contracts/LabelledBucketLib.sol";
+
+/**
+ * @notice Measures the gas spent on the individual steps of a field lookup.
+ * @dev Not intended for deployment. Used by the Go gas profiler, which executes
+ * the compiled contract in an in-process EVM (see `go/gasprofile`).
+ * @dev Every function returns the length of the loaded data so that the
+ * optimizer can not drop the measured steps.
+ */
+contract BucketGasProbe {
+    using InflateLibWrapper for Compressed;
+    using IndexedBucketLib for bytes;
+    using LabelledBucketLib for bytes;
+
+    /**
+     * @notice Measures retrieving and inflating a bucket.
+     * @return length The length of the inflated bucket.
+     */
+    function profileInflate(IBucketStorage store, uint256 bucketId)
+        external
+        view
+        returns (uint256 getBucketGas, uint256 inflateGas, uint256 length)
+    {
+        bytes memory data;
+        (getBucketGas, inflateGas, data) = _load(store, bucketId);
+        length = data.length;
+    }
+
+    /**
+     * @notice Measures retrieving a field from an indexed bucket.
+     * @return length The length of the extracted field.
+     */
+    function profileIndexed(
+        IBucketStorage store,
+        uint256 bucketId,
+        uint256 fieldId
+    )
+        external
+        view
+        returns (
+            uint256 getBucketGas,
+            uint256 inflateGas,
+            uint256 extractGas,
+            uint256 length
+        )
+    {
+        bytes memory data;
+        (getBucketGas, inflateGas, data) = _load(store, bucketId);
+
+        uint256 g = gasleft();
+        length = data.getField(fieldId).length;
+        extractGas = g - gasleft();
+    }
+
+    /**
+     * @notice Measures retrieving a field from a labelled bucket.
+     * @return length The length of the extracted field.
+     */
+    function profileLabelled(
+        IBucketStorage store,
+        uint256 bucketId,
+        uint16 label,
+        uint256 fieldLength
+    )
+        external
+        view
+        returns (
+            uint256 getBucketGas,
+            uint256 inflateGas,
+            uint256 extractGas,
+            uint256 length
+        )
+    {
+        bytes memory data;
+        (getBucketGas, inflateGas, data) = _load(store, bucketId);
+
+        uint256 g = gasleft();
+        length = data.findFieldByLabel(label, fieldLength).length;
+        extractGas = g - gasleft();
+    }
+
+    /**
+     * @notice Retrieves and inflates a bucket, measuring both steps.
+     */
+    function _load(IBucketStorage store, uint256 bucketId)
+        private
+        view
+        returns (uint256 getBucketGas, uint256 inflateGas, bytes memory data)
+    {
+        uint256 g = gasleft();
+        Compressed memory bucket = store.getBucket(bucketId);
+        getBucketGas = g - gasleft();
+
+        g = gasleft();
+        data = bucket.inflate();
+        inflateGas = g - gasleft();
+    }
+}
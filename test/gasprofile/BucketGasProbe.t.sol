@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: MIT
+// Copyright 2022 PROOF Holdings Inc
+pragma solidity ^0.8.15;
+
+import "forge-std/Test.sol";
+
+import {IBucketStorage} from "solidify-contracts/IBucketStorage.sol";
+
+import {GroupStorageStorageDeployer} from
+    "../indexed/gen/GroupStorageStorageDeployer.sol";
+import {FeaturesLib} from "../features/gen/Features.sol";
+import {FeaturesStorageDeployer} from
+    "../features/gen/FeaturesStorageDeployer.sol";
+import {BucketGasProbe} from "./BucketGasProbe.sol";
+
+contract BucketGasProbeTest is Test {
+    BucketGasProbe public probe;
+    IBucketStorage[] public indexed;
+    IBucketStorage[] public labelled;
+
+    constructor() {
+        probe = new BucketGasProbe();
+        indexed = GroupStorageStorageDeployer.deployAsDynamic();
+        labelled = FeaturesStorageDeployer.deployAsDynamic();
+    }
+
+    function testProfileInflate() public {
+        (uint256 getBucketGas, uint256 inflateGas, uint256 length) =
+            probe.profileInflate(indexed[0], 0);
+        assertGt(getBucketGas, 0);
+        assertGt(inflateGas, 0);
+        assertGt(length, 0);
+    }
+
+    function testProfileIndexed() public {
+        (
+            uint256 getBucketGas,
+            uint256 inflateGas,
+            uint256 extractGas,
+            uint256 length
+        ) = probe.profileIndexed(indexed[0], 1, 2);
+        assertGt(getBucketGas, 0);
+        assertGt(inflateGas, 0);
+        assertGt(extractGas, 0);
+        // "bar2"
+        assertEq(length, 4);
+    }
+
+    function testProfileLabelled() public {
+        (
+            uint256 getBucketGas,
+            uint256 inflateGas,
+            uint256 extractGas,
+            uint256 length
+        ) = probe.profileLabelled(
+            labelled[0], 1, 6, FeaturesLib.FEATURES_LENGTH
+        );
+        assertGt(getBucketGas, 0);
+        assertGt(inflateGas, 0);
+        assertGt(extractGas, 0);
+        assertEq(length, FeaturesLib.FEATURES_LENGTH);
+    }
+}
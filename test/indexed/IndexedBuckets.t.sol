@@ -11,14 +11,23 @@ import {
     GroupStorageType,
     GroupStorageStorageMapping
 } from "./gen/GroupStorageStorageMapping.sol";
+import {GroupStorageStorageManager} from
+    "./gen/GroupStorageStorageManager.sol";
+import {
+    GroupTableType,
+    GroupTableStorageMapping
+} from "./gen/GroupTableStorageMapping.sol";
 
-import {IBucketStorage} from "solidify-contracts/IBucketStorage.sol";
+import {
+    IBucketStorage, Compressed
+} from "solidify-contracts/IBucketStorage.sol";
 import {
     BucketStorageLib,
     BucketCoordinates,
     FieldCoordinates
 } from "solidify-contracts/BucketStorageLib.sol";
 import {IndexedBucketLib} from "solidify-contracts/IndexedBucketLib.sol";
+import {BucketFormatLib} from "solidify-contracts/BucketFormatLib.sol";
 
 contract IndexedBucketsTest is Test {
     using BucketStorageLib for IBucketStorage[];
@@ -83,4 +92,188 @@ contract IndexedBucketsTest is Test {
         assertEq(_loadMapped(GroupStorageType.BAR, 2), "bar2");
         assertEq(_loadMapped(GroupStorageType.QUX, 0), "qux0");
     }
+
+    function _coords(uint256 storageId, uint256 bucketId, uint256 fieldId)
+        internal
+        pure
+        returns (FieldCoordinates memory)
+    {
+        return FieldCoordinates({
+            bucket: BucketCoordinates({storageId: storageId, bucketId: bucketId}),
+            fieldId: fieldId
+        });
+    }
+
+    function testBatchFieldAccess() public {
+        FieldCoordinates[] memory coords = new FieldCoordinates[](5);
+        coords[0] = _coords(1, 0, 0);
+        coords[1] = _coords(0, 1, 2);
+        coords[2] = _coords(0, 0, 1);
+        coords[3] = _coords(0, 1, 0);
+        coords[4] = _coords(0, 1, 2);
+
+        bytes[] memory fields = bundle.loadIndexedFields(coords);
+
+        assertEq(fields.length, 5);
+        assertEq(string(fields[0]), "qux0");
+        assertEq(string(fields[1]), "bar2");
+        assertEq(string(fields[2]), "foo1");
+        assertEq(string(fields[3]), "bar0");
+        assertEq(string(fields[4]), "bar2");
+    }
+
+    function testBatchBucketAccess() public {
+        uint256[] memory idxs = new uint256[](2);
+        idxs[0] = 1;
+        idxs[1] = 0;
+
+        Compressed[] memory buckets = bundle[0].getBuckets(idxs);
+
+        assertEq(buckets.length, 2);
+        assertEq(buckets[0].data, bundle[0].getBucket(1).data);
+        assertEq(buckets[1].data, bundle[0].getBucket(0).data);
+    }
+
+    string internal constant MANIFEST_PATH =
+        "test/indexed/gen/GroupStorageManifest.json";
+
+    function testStorageIntegrity() public {
+        string memory manifest = vm.readFile(MANIFEST_PATH);
+
+        for (uint256 i; i < bundle.length; ++i) {
+            bytes32 root = abi.decode(
+                vm.parseJson(
+                    manifest,
+                    string.concat(".storages[", vm.toString(i), "].root")
+                ),
+                (bytes32)
+            );
+            assertEq(bundle[i].storageRoot(), root);
+            BucketStorageLib.verifyStorage(bundle[i], root);
+
+            for (uint256 j; j < bundle[i].numBuckets(); ++j) {
+                bytes32 hash = abi.decode(
+                    vm.parseJson(
+                        manifest,
+                        string.concat(
+                            ".storages[",
+                            vm.toString(i),
+                            "].buckets[",
+                            vm.toString(j),
+                            "].hash"
+                        )
+                    ),
+                    (bytes32)
+                );
+                assertEq(bundle[i].bucketHash(j), hash);
+                bundle.loadUncompressedVerified(
+                    BucketCoordinates({storageId: i, bucketId: j}), hash
+                );
+            }
+        }
+    }
+
+    function testCannotLoadWithWrongHash() public {
+        BucketCoordinates memory coords =
+            BucketCoordinates({storageId: 0, bucketId: 1});
+        bytes32 got = keccak256(bundle.loadCompressed(coords).data);
+
+        vm.expectRevert(
+            abi.encodeWithSelector(
+                BucketStorageLib.BucketHashMismatch.selector,
+                coords,
+                got,
+                bytes32(0)
+            )
+        );
+        bundle.loadUncompressedVerified(coords, bytes32(0));
+    }
+
+    function testStorageManager() public {
+        GroupStorageStorageManager manager = new GroupStorageStorageManager(
+            GroupStorageStorageDeployer.deployAsStatic()
+        );
+
+        assertEq(manager.loadFOO(0), "foo0");
+        assertEq(manager.loadFOO(1), "foo1");
+        assertEq(manager.loadBAR(0), "bar0");
+        assertEq(manager.loadBAR(1), "bar1");
+        assertEq(manager.loadBAR(2), "bar2");
+        assertEq(manager.loadQUX(0), "qux0");
+        assertEq(manager.loadGroupStorage(GroupStorageType.BAR, 1), "bar1");
+    }
+
+    function _loadTableMapped(GroupTableType typ, uint256 index)
+        internal
+        view
+        returns (string memory)
+    {
+        GroupTableStorageMapping.StorageCoordinates memory coords =
+            GroupTableStorageMapping.locate(typ, index);
+
+        return string(
+            bundle.loadUncompressed(coords.bucket).getField(coords.fieldId)
+        );
+    }
+
+    function testTableMapping() public {
+        assertEq(_loadTableMapped(GroupTableType.FOO, 0), "foo0");
+        assertEq(_loadTableMapped(GroupTableType.FOO, 1), "foo1");
+        assertEq(_loadTableMapped(GroupTableType.BAR, 0), "bar0");
+        assertEq(_loadTableMapped(GroupTableType.BAR, 1), "bar1");
+        assertEq(_loadTableMapped(GroupTableType.BAR, 2), "bar2");
+        assertEq(_loadTableMapped(GroupTableType.QUX, 0), "qux0");
+    }
+
+    function testCannotTableMapInvalidIndex() public {
+        vm.expectRevert(
+            abi.encodeWithSelector(
+                GroupTableStorageMapping.InvalidGroupTableIndex.selector,
+                GroupTableType.BAR
+            )
+        );
+        GroupTableStorageMapping.locate(GroupTableType.BAR, 3);
+    }
+
+    function testFormat() public {
+        for (uint256 i; i < bundle.length; ++i) {
+            assertTrue(
+                bundle[i].supportsInterface(type(IBucketStorage).interfaceId)
+            );
+            BucketStorageLib.checkStorage(
+                bundle[i], BucketFormatLib.FLAVOUR_INDEXED
+            );
+
+            bytes32 format = bundle[i].storageFormat();
+            assertEq(format, bundle[i].bucketFormat(0));
+            assertEq(
+                BucketFormatLib.version(format),
+                GroupStorageStorageMapping.SOLIDIFY_VERSION
+            );
+            assertEq(BucketFormatLib.offsetBits(format), 16);
+        }
+
+        assertEq(
+            bundle.loadUncompressedChecked(
+                BucketCoordinates({storageId: 0, bucketId: 1}),
+                BucketFormatLib.FLAVOUR_INDEXED
+            ).getField(2),
+            "bar2"
+        );
+    }
+
+    function testCannotLoadIncompatibleFormat() public {
+        BucketCoordinates memory coords =
+            BucketCoordinates({storageId: 0, bucketId: 0});
+
+        vm.expectRevert(
+            abi.encodeWithSelector(
+                BucketStorageLib.IncompatibleFormat.selector,
+                bundle[0].bucketFormat(0)
+            )
+        );
+        bundle.loadUncompressedChecked(
+            coords, BucketFormatLib.FLAVOUR_LABELLED
+        );
+    }
 }
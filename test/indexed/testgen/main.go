@@ -1,15 +1,21 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 
 	"github.com/proofxyz/solidify/go/aggregators"
 	"github.com/proofxyz/solidify/go/storage"
 	"github.com/proofxyz/solidify/go/types"
 )
 
+var forgeFmt = flag.Bool("forgeFmt", false, "Additionally format the generated contracts with `forge fmt`.")
+
 func main() {
+	flag.Parse()
 	if err := run(); err != nil {
 		fmt.Fprint(os.Stderr, err)
 		os.Exit(1)
@@ -31,6 +37,10 @@ func (t testDataGroup) NumFields() int {
 	return len(t.values)
 }
 
+func (t testDataGroup) FieldType() storage.FieldType {
+	return storage.FieldTypeString
+}
+
 func run() error {
 	gs := []testDataGroup{
 		{
@@ -73,14 +83,45 @@ func run() error {
 		return err
 	}
 
-	fNames, err := storage.WriteGroupStorage("GroupStorage", gs, ss, genDst)
+	fNames, err := storage.WriteGroupStorage("GroupStorage", gs, ss, nil, genDst)
 	if err != nil {
 		return fmt.Errorf("storage.WriteGroupStorage(%q, %T, %T, %q): %w", "Group", gs, ss, genDst, err)
 	}
 
-	if err := storage.FormatSol(fNames); err != nil {
-		return fmt.Errorf("storage.FormatSol(%v): %w", fNames, err)
+	// The manifest allows the tests to check the on-chain hashes against the
+	// ones computed in Go.
+	manifestPath := filepath.Join(genDst, "GroupStorageManifest.json")
+	if err := storage.WriteBundleManifestToFile("GroupStorage", ss, nil, manifestPath); err != nil {
+		return fmt.Errorf("storage.WriteBundleManifestToFile(%q, %T, nil, %q): %w", "GroupStorage", ss, manifestPath, err)
+	}
+
+	tablePath := filepath.Join(genDst, "GroupTableStorageMapping.sol")
+	if err := writeFile(tablePath, func(w io.Writer) error {
+		return storage.WriteTableStorageMapping("GroupTable", gs, ss, nil, w)
+	}); err != nil {
+		return fmt.Errorf("storage.WriteTableStorageMapping(%q, %T, %T, [%s]): %w", "GroupTable", gs, ss, tablePath, err)
+	}
+	fNames = append(fNames, tablePath)
+
+	if *forgeFmt {
+		if err := storage.FormatSol(fNames); err != nil {
+			return fmt.Errorf("storage.FormatSol(%v): %w", fNames, err)
+		}
 	}
 
 	return nil
 }
+
+func writeFile(path string, write func(io.Writer) error) (retErr error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("os.Create(%q): %w", path, err)
+	}
+	defer func() {
+		if err := f.Close(); retErr == nil {
+			retErr = err
+		}
+	}()
+
+	return write(f)
+}